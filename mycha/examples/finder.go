@@ -5,17 +5,28 @@ import (
 	"fmt"
 
 
+	"mycha/examples/internal"
 	"mycha/helper/log"
+	"mycha/module"
+	"mycha/module/remote"
     sched "mycha/scheduler"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 var (
 	firstURL string
 	domains string
 	depth uint
+	maxRedirects uint
 	dirPath string
+	idleWindow time.Duration
+	adminAddr string
+	adminToken string
+	workers string
+	workerTimeout time.Duration
 )
 
 var logger = log.DLogger()
@@ -28,8 +39,21 @@ func init() {
 			"Please using comma-separated multiple domains.")
 	flag.UintVar(&depth, "depth", 3,
 		"The depth for crawling.")
+	flag.UintVar(&maxRedirects, "redirects", 10,
+		"一次下载中最多跟随的跳转次数.")
 	flag.StringVar(&dirPath, "dir", "./pictures",
 		"The path which you want to save the image files.")
+	flag.DurationVar(&idleWindow, "idle", 10*time.Second,
+		"爬虫持续空闲多久之后自动结束.")
+	flag.StringVar(&adminAddr, "admin", "",
+		"管理接口的监听地址，如 127.0.0.1:8090，为空时不启动.")
+	flag.StringVar(&adminToken, "admin-token", os.Getenv("MYCHA_ADMIN_TOKEN"),
+		"管理接口控制命令的访问令牌，默认取环境变量MYCHA_ADMIN_TOKEN，为空时只接受本机的控制命令.")
+	flag.StringVar(&workers, "workers", "",
+		"工作节点的地址，多个地址用逗号分隔，如 10.0.0.5:8081. "+
+			"工作节点上的组件会和本地组件一起被调度.")
+	flag.DurationVar(&workerTimeout, "worker-timeout", remote.DEFAULT_TIMEOUT,
+		"调用工作节点上的组件的超时时间.")
 }
 
 
@@ -59,6 +83,10 @@ func main() {
 	requestArgs := sched.RequestArgs{
 		AcceptedDomains:acceptedDomains,
 		MaxDepth:uint32(depth),
+		MaxRedirects: uint32(maxRedirects),
+		Idle: sched.IdleArgs{
+			Window: uint32(idleWindow / time.Millisecond),
+		},
 	}
 	//数据的实例的配置??
 	dataArgs := sched.DataArgs{
@@ -72,10 +100,64 @@ func main() {
 		ErrorMaxBufferNumber: 1,
 	}
 
-	//downloaders,err :=
-
-
-
+	//组件的实例
+	downloaders, err := internal.GetDownloaders(1, nil)
+	if err != nil {
+		logger.Fatalf("创建下载器时出错: %s", err)
+	}
+	analyzers, err := internal.GetAnalyzers(1, nil)
+	if err != nil {
+		logger.Fatalf("创建分析器时出错: %s", err)
+	}
+	pipelines, err := internal.GetPipelines(1, dirPath, nil)
+	if err != nil {
+		logger.Fatalf("创建条目处理管道时出错: %s", err)
+	}
+	for _, addr := range strings.Split(workers, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		modules, err := remote.Discover(addr, &http.Client{Timeout: workerTimeout},
+			module.CalculateScoreSimple)
+		if err != nil {
+			logger.Fatalf("获取工作节点 %s 上的组件时出错: %s", addr, err)
+		}
+		downloaders = append(downloaders, modules.Downloaders...)
+		analyzers = append(analyzers, modules.Analyzers...)
+		pipelines = append(pipelines, modules.Pipelines...)
+	}
+	moduleArgs := sched.ModuleArgs{
+		Downloaders: downloaders,
+		Analyzers:   analyzers,
+		Pipelines:   pipelines,
+	}
+	//初始化调度器
+	err = scheduler.Init(requestArgs, dataArgs, moduleArgs)
+	if err != nil {
+		logger.Fatalf("初始化调度器时出错: %s", err)
+	}
+	if adminAddr != "" {
+		sched.ServeAdmin(scheduler, adminAddr, sched.AdminArgs{Token: adminToken})
+	}
+	go func() {
+		for err := range scheduler.ErrorChan() {
+			logger.Errorf("爬取时出错: %s", err)
+		}
+	}()
+	//启动调度器
+	firstHTTPReq, err := http.NewRequest("GET", firstURL, nil)
+	if err != nil {
+		logger.Fatalln(err)
+		return
+	}
+	err = scheduler.Start(firstHTTPReq)
+	if err != nil {
+		logger.Fatalf("启动调度器时出错: %s", err)
+	}
+	//持续空闲一段时间后调度器会自动停止
+	<-scheduler.Done()
+	logger.Infof("爬取结束，摘要: %s", scheduler.FinalSummary())
 }
 
 
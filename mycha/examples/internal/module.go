@@ -5,21 +5,31 @@ import (
 	"mycha/module/local/analyzer"
 	"mycha/module/local/downloader"
 	"mycha/module/local/pipline"
+	"net"
 )
 
 var snGen = module.NewSNGenertor(1,0) //生产一个序列号
 
-func GetDownloaders(number uint32) ([]module.Downloader,error) {
+// GetDownloaders 用于获取下载器列表。
+// 参数maddr代表组件的网络地址，会被编入MID，本地使用时可以为nil。
+func GetDownloaders(number uint32, maddr net.Addr) ([]module.Downloader,error) {
 	downloaders := []module.Downloader{}
 	if number == 0 {
 		return downloaders,nil
 	}
 	for i:= uint32(0);i<number; i++ {
-		mid,err := module.GenMID(module.TYPE_DOWNLOADER,snGen.Get(),nil)
+		mid,err := module.GenMID(module.TYPE_DOWNLOADER,snGen.Get(),maddr)
 		if err != nil {
 			return downloaders,err
 		}
-		d,err := downloader.New(mid,genHTTPClient(),module.CalculateScoreSimple)
+		// 只需要网页和图片，其他的内容在下载后即被丢弃。
+		options := downloader.Options{AllowedContentTypes: []string{"text/html", "image/*"}}
+		d,err := downloader.NewWithOptions(mid,genHTTPClient(),options,module.CalculateScoreSimple)
+		if err != nil {
+			return downloaders,err
+		}
+		// 跟随页面中的meta refresh和JavaScript跳转。
+		d,err = downloader.NewRenderer(d,downloader.RenderOptions{})
 		if err != nil {
 			return downloaders,err
 		}
@@ -29,14 +39,14 @@ func GetDownloaders(number uint32) ([]module.Downloader,error) {
 }
 
 // GetAnalyzers 用于获取分析器列表。
-func GetAnalyzers(number uint8) ([]module.Analyzer, error) {
+func GetAnalyzers(number uint8, maddr net.Addr) ([]module.Analyzer, error) {
 	analyzers := []module.Analyzer{}
 	if number == 0 {
 		return analyzers, nil
 	}
 	for i := uint8(0); i < number; i++ {
 		mid, err := module.GenMID(
-			module.TYPE_ANALYZER, snGen.Get(), nil)
+			module.TYPE_ANALYZER, snGen.Get(), maddr)
 		if err != nil {
 			return analyzers, err
 		}
@@ -50,14 +60,14 @@ func GetAnalyzers(number uint8) ([]module.Analyzer, error) {
 }
 
 // GetPipelines 用于获取条目处理管道列表。
-func GetPipelines(number uint8, dirPath string) ([]module.Pipeline, error) {
+func GetPipelines(number uint8, dirPath string, maddr net.Addr) ([]module.Pipeline, error) {
 	pipelines := []module.Pipeline{}
 	if number == 0 {
 		return pipelines, nil
 	}
 	for i := uint8(0); i < number; i++ {
 		mid, err := module.GenMID(
-			module.TYPE_PIPELINE, snGen.Get(), nil)
+			module.TYPE_PIPELINE, snGen.Get(), maddr)
 		if err != nil {
 			return pipelines, err
 		}
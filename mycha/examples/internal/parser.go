@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mycha/module"
+	"mycha/tool/links"
+	"path"
+	"strings"
+)
+
+// genResponseParsers 用于生成响应解析器。
+func genResponseParsers() []module.ParseResponse {
+	// 图片的链接也需要被下载，之后由parseForImg生成条目。
+	extractor, err := links.New(links.Rules{
+		Tags:  []string{"a", "img"},
+		Attrs: []string{"href", "src"},
+	})
+	if err != nil {
+		logger.Fatalf("创建链接提取器时出错: %s", err)
+	}
+	return []module.ParseResponse{extractor.Parse, parseForImg}
+}
+
+// parseForImg 用于解析图片响应并生成条目。
+func parseForImg(resp *module.Response) ([]module.Data, []error) {
+	httpResp := resp.HTTPResp()
+	if httpResp.StatusCode != 200 {
+		err := fmt.Errorf("不支持的状态码 %d (URL: %s)",
+			httpResp.StatusCode, httpResp.Request.URL)
+		return nil, []error{err}
+	}
+	mediaType := resp.MIMEType()
+	if !strings.HasPrefix(mediaType, "image/") {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, []error{err}
+	}
+	item := module.Item{
+		"data": data,
+		"name": path.Base(httpResp.Request.URL.Path),
+		"ext":  strings.TrimPrefix(mediaType, "image/"),
+	}
+	return []module.Data{item}, nil
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"fmt"
+	"mycha/module"
+	"mycha/tool/export"
+	"os"
+	"path/filepath"
+)
+
+// genItemProcessors 用于生成条目处理器。
+// 图片会被保存到dirPath中，图片的信息会以JSON Lines格式记录在index.jsonl中。
+func genItemProcessors(dirPath string) []module.ProcessItem {
+	absDirPath, err := checkDirPath(dirPath)
+	if err != nil {
+		logger.Fatalf("检查目录时出错: %s", err)
+	}
+	// 多个条目处理管道会共用同一个索引文件。
+	exporter, err := export.NewJSONLines(export.Options{
+		Path:    filepath.Join(absDirPath, "index.jsonl"),
+		MaxSize: 64 << 20,
+	})
+	if err != nil {
+		logger.Fatalf("创建条目导出器时出错: %s", err)
+	}
+	saveImage := func(item module.Item) (module.Item, error) {
+		return processImage(absDirPath, item)
+	}
+	return []module.ProcessItem{saveImage, exporter.Process}
+}
+
+// processImage 用于把条目中的图片保存到文件，并用文件路径替换图片数据。
+func processImage(dirPath string, item module.Item) (module.Item, error) {
+	data, ok := item["data"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("不正确的图片数据类型: %T", item["data"])
+	}
+	name, _ := item["name"].(string)
+	if name == "" || name == "/" || name == "." {
+		return nil, fmt.Errorf("空的图片名称")
+	}
+	if ext, _ := item["ext"].(string); ext != "" && filepath.Ext(name) == "" {
+		name += "." + ext
+	}
+	filePath := filepath.Join(dirPath, name)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("图片已经存在: %s", filePath)
+		}
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return nil, err
+	}
+	result := module.Item{}
+	for key, value := range item {
+		if key != "data" {
+			result[key] = value
+		}
+	}
+	result["path"] = filePath
+	result["size"] = len(data)
+	return result, nil
+}
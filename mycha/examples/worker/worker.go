@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"mycha/examples/internal"
+	"mycha/helper/log"
+	"mycha/module"
+	"mycha/module/remote"
+)
+
+var (
+	listenAddr  string
+	downloaders uint
+	analyzers   uint
+	pipelines   uint
+	dirPath     string
+)
+
+var logger = log.DLogger()
+
+func init() {
+	flag.StringVar(&listenAddr, "addr", "127.0.0.1:8081",
+		"工作节点的监听地址，必须是IP加端口，调度器会通过这个地址调用组件.")
+	flag.UintVar(&downloaders, "downloaders", 1,
+		"托管的下载器数量.")
+	flag.UintVar(&analyzers, "analyzers", 1,
+		"托管的分析器数量.")
+	flag.UintVar(&pipelines, "pipelines", 1,
+		"托管的条目处理管道数量.")
+	flag.StringVar(&dirPath, "dir", "./pictures",
+		"The path which you want to save the image files.")
+}
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\tworker [flags] \n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = Usage
+	flag.Parse()
+	host, portStr, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		logger.Fatalf("错误的监听地址 %q: %s", listenAddr, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		logger.Fatalf("错误的监听端口 %q: %s", portStr, err)
+	}
+	//组件的MID中带有工作节点的地址
+	maddr, err := module.NewAddr("http", host, uint32(port))
+	if err != nil {
+		logger.Fatalf("错误的监听地址 %q: %s", listenAddr, err)
+	}
+	var modules []module.Module
+	ds, err := internal.GetDownloaders(uint32(downloaders), maddr)
+	if err != nil {
+		logger.Fatalf("创建下载器时出错: %s", err)
+	}
+	for _, d := range ds {
+		modules = append(modules, d)
+	}
+	as, err := internal.GetAnalyzers(uint8(analyzers), maddr)
+	if err != nil {
+		logger.Fatalf("创建分析器时出错: %s", err)
+	}
+	for _, a := range as {
+		modules = append(modules, a)
+	}
+	ps, err := internal.GetPipelines(uint8(pipelines), dirPath, maddr)
+	if err != nil {
+		logger.Fatalf("创建条目处理管道时出错: %s", err)
+	}
+	for _, p := range ps {
+		modules = append(modules, p)
+	}
+	handler, err := remote.NewHandler(modules...)
+	if err != nil {
+		logger.Fatalf("创建工作节点时出错: %s", err)
+	}
+	for _, m := range modules {
+		logger.Infof("托管组件: %s", m.ID())
+	}
+	logger.Infof("工作节点启动于 %s", listenAddr)
+	if err = http.ListenAndServe(listenAddr, handler); err != nil {
+		logger.Fatalf("工作节点出错: %s", err)
+	}
+}
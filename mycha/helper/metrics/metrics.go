@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标的类型。
+const (
+	TYPE_COUNTER   = "counter"
+	TYPE_GAUGE     = "gauge"
+	TYPE_HISTOGRAM = "histogram"
+)
+
+// Label 代表指标的一个标签。
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample 代表指标的一个采样值。
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// WriteMetric 用于以Prometheus文本格式写入一个指标及其全部采样值。
+func WriteMetric(w io.Writer, name string, help string, metricType string, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, name, help, metricType)
+	for _, sample := range samples {
+		writeSample(bw, name, sample.Labels, sample.Value)
+	}
+	return bw.Flush()
+}
+
+// writeHeader 用于写入指标的HELP和TYPE行。
+func writeHeader(w *bufio.Writer, name string, help string, metricType string) {
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// writeSample 用于写入一行采样值。
+func writeSample(w *bufio.Writer, name string, labels []Label, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label.Name)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabelValue(label.Value))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+// formatFloat 用于按Prometheus的要求格式化数值。
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Histogram 代表按区间统计观测值分布的直方图。
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	lock    sync.Mutex
+	// counts 代表落入各区间的观测值数量，不含比它小的区间。
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram 用于创建一个直方图。
+// 参数buckets代表各区间的上界，会被排序，+Inf区间会自动加上。
+func NewHistogram(name string, help string, buckets []float64) *Histogram {
+	sorted := make([]float64, 0, len(buckets))
+	for _, bound := range buckets {
+		if !math.IsInf(bound, 1) {
+			sorted = append(sorted, bound)
+		}
+	}
+	sort.Float64s(sorted)
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: sorted,
+		counts:  make([]uint64, len(sorted)+1),
+	}
+}
+
+// Name 用于获取直方图的名称。
+func (h *Histogram) Name() string {
+	return h.name
+}
+
+// Observe 用于记录一个观测值。
+func (h *Histogram) Observe(value float64) {
+	index := sort.SearchFloat64s(h.buckets, value)
+	h.lock.Lock()
+	h.counts[index]++
+	h.sum += value
+	h.count++
+	h.lock.Unlock()
+}
+
+// Count 用于获取观测值的总数。
+func (h *Histogram) Count() uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.count
+}
+
+// Write 用于以Prometheus文本格式写入直方图。
+func (h *Histogram) Write(w io.Writer) error {
+	h.lock.Lock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	sum, count := h.sum, h.count
+	h.lock.Unlock()
+
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, h.name, h.help, TYPE_HISTOGRAM)
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += counts[i]
+		writeSample(bw, h.name+"_bucket",
+			[]Label{{Name: "le", Value: formatFloat(bound)}}, float64(cumulative))
+	}
+	writeSample(bw, h.name+"_bucket",
+		[]Label{{Name: "le", Value: "+Inf"}}, float64(count))
+	writeSample(bw, h.name+"_sum", nil, sum)
+	writeSample(bw, h.name+"_count", nil, float64(count))
+	return bw.Flush()
+}
+
+// ObserveBody 用于包装一个响应体，在读到末尾或被关闭时把已读取的字节数记录到直方图中，且只记录一次。
+func (h *Histogram) ObserveBody(body io.ReadCloser) io.ReadCloser {
+	return &countingBody{ReadCloser: body, histogram: h}
+}
+
+// countingBody 代表会统计已读取字节数的响应体。
+type countingBody struct {
+	io.ReadCloser
+	histogram *Histogram
+	n         int64
+	once      sync.Once
+}
+
+func (body *countingBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.n += int64(n)
+	if err == io.EOF {
+		body.observe()
+	}
+	return n, err
+}
+
+func (body *countingBody) Close() error {
+	body.observe()
+	return body.ReadCloser.Close()
+}
+
+func (body *countingBody) observe() {
+	body.once.Do(func() {
+		body.histogram.Observe(float64(body.n))
+	})
+}
+
+// NewDownloadLatency 用于创建下载耗时的直方图，单位为秒。
+func NewDownloadLatency() *Histogram {
+	return NewHistogram(
+		"mycha_download_latency_seconds",
+		"Latency of downloader requests in seconds.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30})
+}
+
+// NewDownloadBytes 用于创建下载的响应体大小的直方图，单位为字节。
+func NewDownloadBytes() *Histogram {
+	return NewHistogram(
+		"mycha_download_bytes",
+		"Size of downloaded response bodies in bytes after decoding.",
+		[]float64{1 << 10, 8 << 10, 32 << 10, 128 << 10, 512 << 10, 2 << 20, 8 << 20, 32 << 20})
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetric(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteMetric(&buf, "mycha_test_total", "Test\ncounter.", TYPE_COUNTER,
+		[]Sample{
+			{Labels: []Label{{Name: "mid", Value: `D1|"x"`}}, Value: 3},
+			{Value: 1.5},
+		})
+	if err != nil {
+		t.Fatalf("An error occurs when writing metric: %s", err)
+	}
+	expected := "# HELP mycha_test_total Test\\ncounter.\n" +
+		"# TYPE mycha_test_total counter\n" +
+		"mycha_test_total{mid=\"D1|\\\"x\\\"\"} 3\n" +
+		"mycha_test_total 1.5\n"
+	if buf.String() != expected {
+		t.Fatalf("Inconsistent metric text: expected:\n%s\nactual:\n%s",
+			expected, buf.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("mycha_test_seconds", "", []float64{1, 0.5})
+	for _, v := range []float64{0.1, 0.5, 0.7, 3} {
+		h.Observe(v)
+	}
+	if h.Count() != 4 {
+		t.Fatalf("Inconsistent count: expected: %d, actual: %d", 4, h.Count())
+	}
+	var buf bytes.Buffer
+	if err := h.Write(&buf); err != nil {
+		t.Fatalf("An error occurs when writing histogram: %s", err)
+	}
+	expectedLines := []string{
+		"# TYPE mycha_test_seconds histogram",
+		`mycha_test_seconds_bucket{le="0.5"} 2`,
+		`mycha_test_seconds_bucket{le="1"} 3`,
+		`mycha_test_seconds_bucket{le="+Inf"} 4`,
+		"mycha_test_seconds_sum 4.3",
+		"mycha_test_seconds_count 4",
+	}
+	actual := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(actual) != len(expectedLines) {
+		t.Fatalf("Inconsistent line number: expected: %d, actual: %d\n%s",
+			len(expectedLines), len(actual), buf.String())
+	}
+	for i, line := range expectedLines {
+		if actual[i] != line {
+			t.Fatalf("Inconsistent line %d: expected: %s, actual: %s", i, line, actual[i])
+		}
+	}
+}
+
+func TestHistogramObserveBody(t *testing.T) {
+	h := NewHistogram("mycha_test_bytes", "", []float64{10})
+	body := h.ObserveBody(ioutil.NopCloser(strings.NewReader("hello world")))
+	if _, err := ioutil.ReadAll(body); err != nil {
+		t.Fatalf("An error occurs when reading body: %s", err)
+	}
+	// 读到末尾后再关闭不会重复记录。
+	body.Close()
+	if h.Count() != 1 {
+		t.Fatalf("Inconsistent count: expected: %d, actual: %d", 1, h.Count())
+	}
+	// 没有读完就关闭时记录已读取的字节数。
+	body = h.ObserveBody(ioutil.NopCloser(strings.NewReader("hello world")))
+	body.Read(make([]byte, 5))
+	body.Close()
+	var buf bytes.Buffer
+	h.Write(&buf)
+	if !strings.Contains(buf.String(), "mycha_test_bytes_sum 16\n") ||
+		!strings.Contains(buf.String(), "mycha_test_bytes_count 2\n") {
+		t.Fatalf("Inconsistent histogram:\n%s", buf.String())
+	}
+}
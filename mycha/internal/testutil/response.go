@@ -0,0 +1,30 @@
+// Package testutil 提供各个包的测试共用的辅助函数，只应在测试中导入。
+package testutil
+
+import (
+	"io/ioutil"
+	"mycha/module"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// NewResponse 用于生成测试用的响应。
+// 响应对应的请求的链接为url、深度为depth，可以通过响应的Request方法获取。
+// 参数contentType为空时，响应头中不带内容类型。
+func NewResponse(t testing.TB, url string, depth uint32, body string, contentType string) *module.Response {
+	httpReq, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating the request: %s", err)
+	}
+	httpResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    httpReq,
+	}
+	if contentType != "" {
+		httpResp.Header.Set("Content-Type", contentType)
+	}
+	return module.NewResponseFor(httpResp, module.NewRequest(httpReq, depth))
+}
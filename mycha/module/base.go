@@ -1,6 +1,5 @@
 package module
 
-import "net/http"
 
 type Counts struct {
 	//调用次数
@@ -20,6 +19,8 @@ type SummaryStruct struct {
 	Accepted uint32 `json:"accepted"`
 	Completed uint32 `json:"completed"`
 	Handling uint32 `json:"handling"`
+	//被注册器选中的次数 由调度器在生成摘要时填写
+	Selected uint64 `json:"selected"`
 	Extra interface{} `json:"extra,omitempty"`
 }
 //统一的组件接口
@@ -52,8 +53,8 @@ type Analyzer interface {
 	//分析函数
 	Analyze(resp *Response) ([]Data,[]error)
 }
-//函数列表类型
-type ParseResponse func(httpResq *http.Response,respDepth uint32) ([]Data,[]error)
+//响应解析函数的类型 可以通过响应获取产生它的请求及其元数据
+type ParseResponse func(resp *Response) ([]Data,[]error)
 
 //处理条目通道
 type Pipeline interface {
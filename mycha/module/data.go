@@ -12,6 +12,20 @@ type Data interface {
 type Request struct {
 	httpReq *http.Request
 	depth uint32
+	//已经重试的次数 首次下载时为0
+	attempt uint32
+	//优先级 数值越大越优先
+	priority int
+	//元数据 会随请求流转到响应和响应解析函数
+	meta map[string]interface{}
+	//处理响应时使用的解析函数的名称 为空时由分析器决定
+	callback string
+	//随请求一起传递的条目 用于跨页面组装条目
+	item Item
+	//产生该请求的响应所对应的请求 首个请求的为nil
+	parent *Request
+	//下载过程中检查跳转的函数 为nil时由下载器决定
+	redirectPolicy RedirectPolicy
 }
 
 
@@ -36,11 +50,132 @@ func (req *Request) Valid() bool {
 	return req.httpReq != nil && req.httpReq.URL != nil
 }
 
+// Priority 用于获取请求的优先级。
+func (req *Request) Priority() int {
+	return req.priority
+}
+
+// SetPriority 用于设置请求的优先级，数值越大越优先。
+// 只有在请求缓冲池按优先级排序时才会生效。
+func (req *Request) SetPriority(priority int) {
+	req.priority = priority
+}
+
+// Attempt 用于获取请求已经重试的次数。
+func (req *Request) Attempt() uint32 {
+	return req.attempt
+}
+
+// SetAttempt 用于设置请求已经重试的次数，比如从检查点还原请求时。
+func (req *Request) SetAttempt(attempt uint32) {
+	req.attempt = attempt
+}
+
+// Retry 用于生成一个重试次数加1的新请求。
+// 若原HTTP请求的请求体可以重新获取，新请求会带有新的请求体。
+// 新请求有自己的元数据副本，修改它不会影响原请求。
+func (req *Request) Retry() (*Request, error) {
+	httpReq := req.httpReq.Clone(req.httpReq.Context())
+	if req.httpReq.GetBody != nil {
+		body, err := req.httpReq.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Body = body
+	}
+	return &Request{
+		httpReq:        httpReq,
+		depth:          req.depth,
+		attempt:        req.attempt + 1,
+		priority:       req.priority,
+		meta:           copyMeta(req.meta),
+		callback:       req.callback,
+		item:           req.item,
+		parent:         req.parent,
+		redirectPolicy: req.redirectPolicy,
+	}, nil
+}
+
+// Meta 用于获取请求的元数据的副本，没有元数据时返回nil。
+// 修改返回的映射不会影响请求，需要修改时请使用SetMeta。
+// 请求需要保存到检查点或发往远程组件时，元数据中的值需要能被编码为JSON。
+func (req *Request) Meta() map[string]interface{} {
+	return copyMeta(req.meta)
+}
+
+// copyMeta 用于复制元数据，映射中的值本身不会被复制。
+func copyMeta(meta map[string]interface{}) map[string]interface{} {
+	if meta == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(meta))
+	for key, value := range meta {
+		copied[key] = value
+	}
+	return copied
+}
+
+// MetaValue 用于获取元数据中某个键对应的值。
+func (req *Request) MetaValue(key string) (interface{}, bool) {
+	value, ok := req.meta[key]
+	return value, ok
+}
+
+// SetMeta 用于设置元数据中某个键对应的值。
+func (req *Request) SetMeta(key string, value interface{}) {
+	if req.meta == nil {
+		req.meta = map[string]interface{}{}
+	}
+	req.meta[key] = value
+}
+
+// Callback 用于获取处理响应时使用的解析函数的名称。
+func (req *Request) Callback() string {
+	return req.callback
+}
+
+// SetCallback 用于设置处理响应时使用的解析函数的名称。
+func (req *Request) SetCallback(callback string) {
+	req.callback = callback
+}
+
+// Item 用于获取随请求一起传递的条目。
+func (req *Request) Item() Item {
+	return req.item
+}
+
+// SetItem 用于设置随请求一起传递的条目，
+// 比如列表页上已经解析出的字段，在详情页的解析函数中补全后再输出。
+func (req *Request) SetItem(item Item) {
+	req.item = item
+}
+
+// Parent 用于获取产生该请求的响应所对应的请求。
+func (req *Request) Parent() *Request {
+	return req.parent
+}
+
+// Referer 用于获取请求的来源页面的链接。
+func (req *Request) Referer() string {
+	if req.httpReq == nil {
+		return ""
+	}
+	return req.httpReq.Referer()
+}
+
 
 //自己封装的一个响对象
 type Response struct {
 	httpResp *http.Response
 	depth uint32
+	//产生该响应的请求
+	req *Request
+	// sniffed 代表对响应内容的嗅探结果，在第一次需要时生成。
+	sniffed *sniffResult
+	// text 代表转换为UTF-8的响应内容，在第一次调用Text时生成。
+	text *string
+	// redirects 代表得到该响应之前经过的跳转。
+	redirects []Redirect
 }
 
 
@@ -49,6 +184,84 @@ func NewResponse(httpResp *http.Response, depth uint32) *Response {
 	return &Response{httpResp: httpResp, depth: depth}
 }
 
+// NewResponseFor 用于创建给定请求的响应，响应会带上请求的深度和元数据。
+func NewResponseFor(httpResp *http.Response, req *Request) *Response {
+	return &Response{httpResp: httpResp, depth: req.Depth(), req: req}
+}
+
+// Request 用于获取产生该响应的请求，未知时返回nil。
+func (resp *Response) Request() *Request {
+	return resp.req
+}
+
+// Meta 用于获取产生该响应的请求的元数据的副本。
+func (resp *Response) Meta() map[string]interface{} {
+	if resp.req == nil {
+		return nil
+	}
+	return resp.req.Meta()
+}
+
+// MetaValue 用于获取产生该响应的请求的元数据中某个键对应的值。
+func (resp *Response) MetaValue(key string) (interface{}, bool) {
+	if resp.req == nil {
+		return nil, false
+	}
+	return resp.req.MetaValue(key)
+}
+
+// Callback 用于获取产生该响应的请求指定的解析函数的名称。
+func (resp *Response) Callback() string {
+	if resp.req == nil {
+		return ""
+	}
+	return resp.req.Callback()
+}
+
+// Item 用于获取随产生该响应的请求一起传递的条目。
+func (resp *Response) Item() Item {
+	if resp.req == nil {
+		return nil
+	}
+	return resp.req.Item()
+}
+
+// Follow 用于根据该响应页面上的链接生成下一层的请求。
+func (resp *Response) Follow(httpReq *http.Request) *Request {
+	return resp.Adopt(NewRequest(httpReq, 0))
+}
+
+// Adopt 用于把解析该响应得到的请求挂到该响应之下。
+// 返回的请求的深度为响应的深度加1，并以产生该响应的请求为父请求，
+// 没有Referer头时会设为该响应的链接。请求自带的元数据等信息会被保留，
+// 元数据会被复制，之后修改返回的请求的元数据不会影响给定的请求。
+func (resp *Response) Adopt(req *Request) *Request {
+	child := *req
+	child.meta = copyMeta(req.meta)
+	child.depth = resp.depth + 1
+	if child.parent == nil {
+		child.parent = resp.req
+	}
+	if child.httpReq != nil && child.httpReq.Header.Get("Referer") == "" {
+		if referer := resp.url(); referer != "" {
+			child.httpReq = child.httpReq.Clone(child.httpReq.Context())
+			child.httpReq.Header.Set("Referer", referer)
+		}
+	}
+	return &child
+}
+
+// url 用于获取响应的链接。
+func (resp *Response) url() string {
+	if resp.httpResp != nil && resp.httpResp.Request != nil && resp.httpResp.Request.URL != nil {
+		return resp.httpResp.Request.URL.String()
+	}
+	if resp.req != nil && resp.req.httpReq != nil && resp.req.httpReq.URL != nil {
+		return resp.req.httpReq.URL.String()
+	}
+	return ""
+}
+
 // HTTPResp 用于获取HTTP响应。
 func (resp *Response) HTTPResp() *http.Response {
 	return resp.httpResp
@@ -69,7 +282,22 @@ func (resp *Response) Valid() bool {
 type Item map[string]interface{}
 
 
+// ITEM_KIND_KEY 代表条目中存放条目种类的键。
+const ITEM_KIND_KEY = "_kind"
+
 // Valid 用于判断条目是否有效。
 func (item Item) Valid() bool {
 	return item != nil
 }
+
+// Kind 用于获取条目的种类，没有设置时返回空字符串。
+// 条目处理管道可以根据种类选择不同的条目处理器。
+func (item Item) Kind() string {
+	kind, _ := item[ITEM_KIND_KEY].(string)
+	return kind
+}
+
+// SetKind 用于设置条目的种类。
+func (item Item) SetKind(kind string) {
+	item[ITEM_KIND_KEY] = kind
+}
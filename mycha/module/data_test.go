@@ -0,0 +1,118 @@
+package module
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseAdopt(t *testing.T) {
+	listReq, _ := http.NewRequest(http.MethodGet, "http://example.com/list", nil)
+	parent := NewRequest(listReq, 1)
+	parent.SetMeta("page", 1)
+	httpResp := &http.Response{Request: listReq, Body: ioutil.NopCloser(strings.NewReader(""))}
+	resp := NewResponseFor(httpResp, parent)
+	if page, ok := resp.MetaValue("page"); !ok || page != 1 {
+		t.Fatalf("Inconsistent meta value of response: expected: %v, actual: %v", 1, page)
+	}
+
+	detailReq, _ := http.NewRequest(http.MethodGet, "http://example.com/detail", nil)
+	req := NewRequest(detailReq, 0)
+	req.SetCallback("detail")
+	req.SetItem(Item{"title": "x"})
+	req.SetMeta("from", "list")
+	child := resp.Adopt(req)
+	if child.Depth() != 2 {
+		t.Fatalf("Inconsistent depth: expected: %d, actual: %d", 2, child.Depth())
+	}
+	if child.Parent() != parent {
+		t.Fatalf("Inconsistent parent: expected: %p, actual: %p", parent, child.Parent())
+	}
+	if child.Referer() != "http://example.com/list" {
+		t.Fatalf("Inconsistent referer: expected: %s, actual: %s",
+			"http://example.com/list", child.Referer())
+	}
+	if child.Callback() != "detail" || child.Item()["title"] != "x" {
+		t.Fatalf("Inconsistent callback or item: callback: %s, item: %v", child.Callback(), child.Item())
+	}
+	if from, _ := child.MetaValue("from"); from != "list" {
+		t.Fatalf("Inconsistent meta value: expected: %s, actual: %v", "list", from)
+	}
+	// 采用后的请求有自己的元数据。
+	child.SetMeta("from", "detail")
+	child.Meta()["extra"] = 1
+	if from, _ := req.MetaValue("from"); from != "list" || len(child.Meta()) != 1 {
+		t.Fatalf("The meta is shared: original: %v, adopted: %v", req.Meta(), child.Meta())
+	}
+	// 给定的请求不应被修改。
+	if req.Depth() != 0 || req.Parent() != nil || req.Referer() != "" {
+		t.Fatalf("The adopted request is modified: depth: %d, parent: %p, referer: %s",
+			req.Depth(), req.Parent(), req.Referer())
+	}
+
+	// 已有的Referer头和父请求会被保留。
+	detailReq, _ = http.NewRequest(http.MethodGet, "http://example.com/detail", nil)
+	detailReq.Header.Set("Referer", "http://example.com/")
+	other := NewRequest(listReq, 0)
+	req = resp.Adopt(NewRequest(detailReq, 0))
+	req = NewResponseFor(httpResp, other).Adopt(req)
+	if req.Referer() != "http://example.com/" || req.Parent() != parent {
+		t.Fatalf("Inconsistent referer or parent: referer: %s, parent: %p", req.Referer(), req.Parent())
+	}
+
+	followed := resp.Follow(detailReq)
+	if followed.Depth() != 2 || followed.Parent() != parent || followed.Callback() != "" {
+		t.Fatalf("Inconsistent followed request: depth: %d, parent: %p, callback: %s",
+			followed.Depth(), followed.Parent(), followed.Callback())
+	}
+}
+
+func TestRequestRetry(t *testing.T) {
+	httpReq, _ := http.NewRequest(http.MethodPost, "http://example.com/form", strings.NewReader("payload"))
+	req := NewRequest(httpReq, 3)
+	req.SetPriority(5)
+	req.SetCallback("form")
+	req.SetMeta("k", "v")
+	ioutil.ReadAll(httpReq.Body)
+
+	retried, err := req.Retry()
+	if err != nil {
+		t.Fatalf("An error occurs when retrying request: %s", err)
+	}
+	if retried.Attempt() != 1 || req.Attempt() != 0 {
+		t.Fatalf("Inconsistent attempt: expected: (%d, %d), actual: (%d, %d)",
+			1, 0, retried.Attempt(), req.Attempt())
+	}
+	if retried.Depth() != 3 || retried.Priority() != 5 || retried.Callback() != "form" {
+		t.Fatalf("Inconsistent retried request: depth: %d, priority: %d, callback: %s",
+			retried.Depth(), retried.Priority(), retried.Callback())
+	}
+	if v, _ := retried.MetaValue("k"); v != "v" {
+		t.Fatalf("Inconsistent meta value: expected: %s, actual: %v", "v", v)
+	}
+	retried.SetMeta("k", "retried")
+	if v, _ := req.MetaValue("k"); v != "v" {
+		t.Fatalf("The meta is shared with the retried request: %v", v)
+	}
+	if retried.HTTPReq() == httpReq {
+		t.Fatalf("The retried request shares the HTTP request with the original")
+	}
+	// 原请求体已经被读完，新请求需要带有新的请求体。
+	body, _ := ioutil.ReadAll(retried.HTTPReq().Body)
+	if string(body) != "payload" {
+		t.Fatalf("Inconsistent body: expected: %q, actual: %q", "payload", body)
+	}
+	if again, _ := retried.Retry(); again.Attempt() != 2 {
+		t.Fatalf("Inconsistent attempt: expected: %d, actual: %d", 2, again.Attempt())
+	}
+
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return nil, errors.New("no body")
+	}
+	if _, err := req.Retry(); err == nil {
+		t.Fatalf("No error when the body could not be got again")
+	}
+}
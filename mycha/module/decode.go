@@ -0,0 +1,182 @@
+package module
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// sniffLen 代表嗅探媒体类型和字符集时最多读取的字节数。
+const sniffLen = 1024
+
+// sniffResult 代表对响应内容的嗅探结果。
+type sniffResult struct {
+	mimeType string
+	charset  string
+	encoding encoding.Encoding
+}
+
+// MIMEType 用于获取响应内容的媒体类型，比如text/html。
+// 响应头中没有内容类型时，会根据内容的开头部分嗅探。
+func (resp *Response) MIMEType() string {
+	return resp.sniff().mimeType
+}
+
+// Charset 用于获取响应内容的字符集名称，比如utf-8和gbk，内容不是文本时返回空字符串。
+// 字符集依次根据BOM、响应头和HTML中的<meta>确定，
+// 都没有时，内容的开头部分为合法的UTF-8则为utf-8，否则为windows-1252。
+func (resp *Response) Charset() string {
+	return resp.sniff().charset
+}
+
+// IsText 用于判断响应内容是否为文本，包括HTML、XML、JSON和JavaScript等。
+func (resp *Response) IsText() bool {
+	return isTextType(resp.MIMEType())
+}
+
+// Text 用于获取转换为UTF-8的响应内容。
+// 它会读取响应体，结果会被缓存，之后再次调用时直接返回。
+func (resp *Response) Text() (string, error) {
+	if resp.text != nil {
+		return *resp.text, nil
+	}
+	r, err := resp.TextReader()
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	resp.text = &text
+	return text, nil
+}
+
+// TextReader 用于获取一个读取转换为UTF-8的响应内容的读取器，
+// 适合不需要把全部内容放到内存中的情况。它会读取响应体。
+func (resp *Response) TextReader() (io.Reader, error) {
+	if resp.text != nil {
+		return strings.NewReader(*resp.text), nil
+	}
+	if resp.httpResp == nil || resp.httpResp.Body == nil {
+		return nil, fmt.Errorf("empty response body")
+	}
+	result := resp.sniff()
+	if result.encoding == nil {
+		return nil, fmt.Errorf("not text content: %s", result.mimeType)
+	}
+	// BOMOverride会去掉BOM，并在BOM与检测到的字符集不同时以BOM为准。
+	decoder := unicode.BOMOverride(result.encoding.NewDecoder())
+	return transform.NewReader(resp.httpResp.Body, decoder), nil
+}
+
+// sniff 用于嗅探响应内容的媒体类型和字符集，结果会被缓存。
+// 需要读取内容时，只读取开头的部分，响应体仍然可以完整地读取。
+func (resp *Response) sniff() *sniffResult {
+	if resp.sniffed != nil {
+		return resp.sniffed
+	}
+	result := &sniffResult{}
+	resp.sniffed = result
+	if resp.httpResp == nil {
+		return result
+	}
+	contentType := resp.httpResp.Header.Get("Content-Type")
+	head := resp.peek(sniffLen)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		result.mimeType = strings.ToLower(mediaType)
+	} else if len(head) > 0 {
+		result.mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+	if !isTextType(result.mimeType) {
+		return result
+	}
+	var certain bool
+	result.encoding, result.charset, certain = charset.DetermineEncoding(head, contentType)
+	if !certain && result.charset == "windows-1252" && isASCII(trimTruncated(head)) {
+		// 开头的部分都是ASCII字符时无法判断，这时UTF-8的可能性远大于windows-1252。
+		result.encoding, result.charset = unicode.UTF8, "utf-8"
+	}
+	return result
+}
+
+// peek 用于读取响应体开头的最多n个字节，并把响应体还原为未读取的状态。
+func (resp *Response) peek(n int) []byte {
+	body := resp.httpResp.Body
+	if body == nil {
+		return nil
+	}
+	head := make([]byte, n)
+	read, err := io.ReadFull(body, head)
+	head = head[:read]
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		// 把错误留给之后读取响应体的一方。
+		resp.httpResp.Body = readCloser{io.MultiReader(bytes.NewReader(head), errReader{err}), body}
+		return head
+	}
+	resp.httpResp.Body = readCloser{io.MultiReader(bytes.NewReader(head), body), body}
+	return head
+}
+
+// trimTruncated 用于去掉被截断的内容末尾可能不完整的UTF-8字符。
+func trimTruncated(head []byte) []byte {
+	if len(head) < sniffLen {
+		return head
+	}
+	for i := 1; i < utf8.UTFMax && head[len(head)-1] >= utf8.RuneSelf; i++ {
+		head = head[:len(head)-1]
+	}
+	return head
+}
+
+// isASCII 用于判断数据是否只包含ASCII字符。
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isTextType 用于判断媒体类型是否为文本。
+func isTextType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-javascript", "application/ecmascript":
+		return true
+	}
+	return false
+}
+
+// readCloser 用于组合读取器和关闭器。
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader 代表总是返回错误的读取器。
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,115 @@
+package module
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// newTextResponse 用于生成测试用的响应，参数contentType为空时响应头中不带内容类型。
+func newTextResponse(body string, contentType string) *Response {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return NewResponse(&http.Response{
+		Header: header,
+		Body:   ioutil.NopCloser(strings.NewReader(body)),
+	}, 0)
+}
+
+// gbk 用于把字符串编码为GBK。
+func gbk(t *testing.T, s string) string {
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(s)
+	if err != nil {
+		t.Fatalf("An error occurs when encoding %q in GBK: %s", s, err)
+	}
+	return encoded
+}
+
+func TestResponseDecode(t *testing.T) {
+	longASCII := strings.Repeat("a", sniffLen-1) + "世界"
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		mimeType    string
+		charset     string
+		text        string
+	}{
+		{"utf-8 BOM", "\xef\xbb\xbfhello 世界", "text/plain",
+			"text/plain", "utf-8", "hello 世界"},
+		// BOM比响应头中的字符集优先。
+		{"BOM over header", "\xef\xbb\xbf世界", "text/plain; charset=gbk",
+			"text/plain", "utf-8", "世界"},
+		{"gbk header", gbk(t, "你好，世界"), "text/plain; charset=GBK",
+			"text/plain", "gbk", "你好，世界"},
+		{"gb2312 meta", `<html><head><meta charset="gb2312"></head><body>` + gbk(t, "中文") + `</body></html>`,
+			"text/html", "text/html", "gbk",
+			`<html><head><meta charset="gb2312"></head><body>中文</body></html>`},
+		{"undeclared utf-8", "<p>中文</p>", "text/html", "text/html", "utf-8", "<p>中文</p>"},
+		// 嗅探的范围内只有ASCII字符，且最后一个字符被截断。
+		{"ascii head", longASCII, "text/plain", "text/plain", "utf-8", longASCII},
+		{"sniffed html", "<html><p>x</p></html>", "",
+			"text/html", "utf-8", "<html><p>x</p></html>"},
+		{"json", `{"a":"é"}`, "application/json", "application/json", "utf-8", `{"a":"é"}`},
+	}
+	for _, c := range cases {
+		resp := newTextResponse(c.body, c.contentType)
+		if resp.MIMEType() != c.mimeType || resp.Charset() != c.charset || !resp.IsText() {
+			t.Fatalf("Inconsistent result of %s: expected: (%s, %s), actual: (%s, %s, text: %v)",
+				c.name, c.mimeType, c.charset, resp.MIMEType(), resp.Charset(), resp.IsText())
+		}
+		text, err := resp.Text()
+		if err != nil {
+			t.Fatalf("An error occurs when decoding %s: %s", c.name, err)
+		}
+		if text != c.text {
+			t.Fatalf("Inconsistent text of %s: expected: %q, actual: %q", c.name, c.text, text)
+		}
+		// 结果会被缓存。
+		if again, _ := resp.Text(); again != text {
+			t.Fatalf("Inconsistent cached text of %s: expected: %q, actual: %q", c.name, text, again)
+		}
+	}
+}
+
+func TestResponseSniffKeepsBody(t *testing.T) {
+	body := strings.Repeat("<p>x</p>", sniffLen)
+	resp := newTextResponse(body, "")
+	if resp.MIMEType() != "text/html" {
+		t.Fatalf("Inconsistent MIME type: expected: %s, actual: %s", "text/html", resp.MIMEType())
+	}
+	// 嗅探之后响应体仍然可以完整地读取。
+	b, _ := ioutil.ReadAll(resp.HTTPResp().Body)
+	if string(b) != body {
+		t.Fatalf("Inconsistent body length after sniffing: expected: %d, actual: %d", len(body), len(b))
+	}
+}
+
+func TestResponseDecodeNonText(t *testing.T) {
+	resp := newTextResponse("\x89PNG\r\n\x1a\n0000", "")
+	if resp.MIMEType() != "image/png" || resp.IsText() || resp.Charset() != "" {
+		t.Fatalf("Inconsistent result of PNG: (%s, %s, text: %v)",
+			resp.MIMEType(), resp.Charset(), resp.IsText())
+	}
+	if _, err := resp.Text(); err == nil {
+		t.Fatalf("No error when decoding a PNG as text")
+	}
+
+	readErr := errors.New("connection reset")
+	resp = NewResponse(&http.Response{
+		Header: http.Header{"Content-Type": {"text/plain"}},
+		Body:   ioutil.NopCloser(errReader{readErr}),
+	}, 0)
+	if _, err := resp.Text(); err != readErr {
+		t.Fatalf("Inconsistent error: expected: %v, actual: %v", readErr, err)
+	}
+	if _, err := NewResponse(&http.Response{}, 0).Text(); err == nil {
+		t.Fatalf("No error when decoding a response without body")
+	}
+}
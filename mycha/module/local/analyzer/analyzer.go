@@ -3,40 +3,81 @@ package analyzer
 import (
 	"fmt"
 
-	"gopcp.v2/chapter6/webcrawler/module"
-	"gopcp.v2/chapter6/webcrawler/module/stub"
-	"gopcp.v2/chapter6/webcrawler/toolkit/reader"
-	"gopcp.v2/helper/log"
+	"mycha/helper/log"
+	"mycha/module"
+	"mycha/module/stub"
+	"mycha/tool/reader"
 )
 
 // logger 代表日志记录器。
 var logger = log.DLogger()
 
 // New 用于创建一个分析器实例。
+// 每个响应都会交给所有的响应解析函数处理。
 func New(
 	mid module.MID,
 	respParsers []module.ParseResponse,
 	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
+	if respParsers == nil {
+		return nil, genParameterError("nil response parsers")
+	}
+	routes := make([]Route, len(respParsers))
+	for i, parser := range respParsers {
+		routes[i] = Route{Parser: parser}
+	}
+	return NewWithRoutes(mid, routes, scoreCalculator)
+}
+
+// NewWithRoutes 用于创建一个按路由规则分派响应的分析器实例。
+// 响应体会按reader.DefaultStreamOptions()读取。
+func NewWithRoutes(
+	mid module.MID,
+	routes []Route,
+	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
+	return NewWithBodyOptions(mid, routes, reader.DefaultStreamOptions(), scoreCalculator)
+}
+
+// NewWithBodyOptions 用于创建一个按路由规则分派响应的分析器实例。
+// 参数bodyOptions决定响应体的大小上限，以及超过多大时会被转存到临时文件中。
+func NewWithBodyOptions(
+	mid module.MID,
+	routes []Route,
+	bodyOptions reader.StreamOptions,
+	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
 	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
 	if err != nil {
 		return nil, err
 	}
-	if respParsers == nil {
+	if routes == nil {
 		return nil, genParameterError("nil response parsers")
 	}
-	if len(respParsers) == 0 {
+	if len(routes) == 0 {
 		return nil, genParameterError("empty response parser list")
 	}
-	var innerParsers []module.ParseResponse
-	for i, parser := range respParsers {
-		if parser == nil {
-			return nil, genParameterError(fmt.Sprintf("nil response parser[%d]", i))
+	if bodyOptions.MaxBodySize < 0 || bodyOptions.MemoryLimit < 0 {
+		return nil, genParameterError("negative body size limit")
+	}
+	var innerRoutes []*route
+	routeMap := map[string]*route{}
+	for i, r := range routes {
+		compiled, err := newRoute(i, r)
+		if err != nil {
+			return nil, err
+		}
+		if compiled.name != "" {
+			if _, ok := routeMap[compiled.name]; ok {
+				return nil, genParameterError(
+					fmt.Sprintf("duplicate response parser name: %s", compiled.name))
+			}
+			routeMap[compiled.name] = compiled
 		}
-		innerParsers = append(innerParsers, parser)
+		innerRoutes = append(innerRoutes, compiled)
 	}
 	return &myAnalyzer{
 		ModuleInternal: moduleBase,
-		respParsers:    innerParsers,
+		routes:         innerRoutes,
+		routeMap:       routeMap,
+		bodyOptions:    bodyOptions,
 	}, nil
 }
 
@@ -44,13 +85,19 @@ func New(
 type myAnalyzer struct {
 	// stub.ModuleInternal 代表组件基础实例。
 	stub.ModuleInternal
-	// respParsers 代表响应解析器列表。
-	respParsers []module.ParseResponse
+	// routes 代表响应解析函数的路由规则列表。
+	routes []*route
+	// routeMap 代表有名称的路由规则，键为名称。
+	routeMap map[string]*route
+	// bodyOptions 代表读取响应体的选项。
+	bodyOptions reader.StreamOptions
 }
 
 func (analyzer *myAnalyzer) RespParsers() []module.ParseResponse {
-	parsers := make([]module.ParseResponse, len(analyzer.respParsers))
-	copy(parsers, analyzer.respParsers)
+	parsers := make([]module.ParseResponse, len(analyzer.routes))
+	for i, r := range analyzer.routes {
+		parsers[i] = r.parser
+	}
 	return parsers
 }
 
@@ -83,6 +130,14 @@ func (analyzer *myAnalyzer) Analyze(
 		return
 	}
 	analyzer.ModuleInternal.IncrAcceptedCount()
+	routes, err := analyzer.selectRoutes(resp)
+	if err != nil {
+		if httpResp.Body != nil {
+			httpResp.Body.Close()
+		}
+		errorList = append(errorList, err)
+		return
+	}
 	respDepth := resp.Depth()
 	logger.Infof("Parse the response (URL: %s, depth: %d)... \n",
 		reqURL, respDepth)
@@ -91,21 +146,23 @@ func (analyzer *myAnalyzer) Analyze(
 	if httpResp.Body != nil {
 		defer httpResp.Body.Close()
 	}
-	multipleReader, err := reader.NewMultipleReader(httpResp.Body)
+	// 响应体只在被解析函数读取时才会被读出，较大时会被转存到临时文件中。
+	multipleReader, err := reader.NewStreamMultipleReader(httpResp.Body, analyzer.bodyOptions)
 	if err != nil {
 		errorList = append(errorList, genError(err.Error()))
 		return
 	}
+	defer multipleReader.Close()
 	dataList = []module.Data{}
-	for _, respParser := range analyzer.respParsers {
+	for _, r := range routes {
 		httpResp.Body = multipleReader.Reader()
-		pDataList, pErrorList := respParser(httpResp, respDepth)
+		pDataList, pErrorList := r.parser(resp)
 		if pDataList != nil {
 			for _, pData := range pDataList {
 				if pData == nil {
 					continue
 				}
-				dataList = appendDataList(dataList, pData, respDepth)
+				dataList = appendDataList(dataList, pData, resp)
 			}
 		}
 		if pErrorList != nil {
@@ -124,7 +181,8 @@ func (analyzer *myAnalyzer) Analyze(
 }
 
 // appendDataList 用于添加请求值或条目值到列表。
-func appendDataList(dataList []module.Data, data module.Data, respDepth uint32) []module.Data {
+// 请求会被挂到响应之下，深度不对时会被修正，元数据则保持不变。
+func appendDataList(dataList []module.Data, data module.Data, resp *module.Response) []module.Data {
 	if data == nil {
 		return dataList
 	}
@@ -132,9 +190,8 @@ func appendDataList(dataList []module.Data, data module.Data, respDepth uint32)
 	if !ok {
 		return append(dataList, data)
 	}
-	newDepth := respDepth + 1
-	if req.Depth() != newDepth {
-		req = module.NewRequest(req.HTTPReq(), newDepth)
+	if req.Depth() != resp.Depth()+1 || req.Parent() == nil {
+		req = resp.Adopt(req)
 	}
 	return append(dataList, req)
 }
\ No newline at end of file
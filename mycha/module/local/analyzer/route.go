@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"fmt"
+	"mycha/module"
+	"regexp"
+	"strings"
+)
+
+// Route 代表响应解析函数的路由规则。
+//
+// 请求通过SetCallback指定了解析函数的名称时，只有同名的解析函数会被使用，
+// 找不到同名的解析函数会被视为错误。
+// 否则会使用所有条件都满足的解析函数，其中未设置的条件视为满足。
+// 设置了名称但没有设置任何条件的解析函数只会在请求指定其名称时使用。
+type Route struct {
+	// Name 代表解析函数的名称，在同一个分析器中不能重复。
+	Name string
+	// URLPattern 代表响应的链接需要匹配的正则表达式。
+	URLPattern string
+	// ContentTypes 代表响应的内容类型需要是其中之一，
+	// 可以用"text/*"这样的形式匹配一类内容类型。
+	// 响应头中没有内容类型时，以嗅探到的内容类型为准。
+	ContentTypes []string
+	// Parser 代表响应解析函数。
+	Parser module.ParseResponse
+}
+
+// route 代表编译后的路由规则。
+type route struct {
+	name         string
+	urlRegexp    *regexp.Regexp
+	contentTypes []string
+	parser       module.ParseResponse
+}
+
+// newRoute 用于检查并编译路由规则。
+func newRoute(index int, r Route) (*route, error) {
+	if r.Parser == nil {
+		return nil, genParameterError(fmt.Sprintf("nil response parser[%d]", index))
+	}
+	compiled := &route{name: r.Name, parser: r.Parser}
+	if r.URLPattern != "" {
+		urlRegexp, err := regexp.Compile(r.URLPattern)
+		if err != nil {
+			return nil, genParameterError(
+				fmt.Sprintf("invalid URL pattern of response parser[%d]: %s", index, err))
+		}
+		compiled.urlRegexp = urlRegexp
+	}
+	for _, contentType := range r.ContentTypes {
+		contentType = strings.ToLower(strings.TrimSpace(contentType))
+		if contentType == "" {
+			continue
+		}
+		compiled.contentTypes = append(compiled.contentTypes, contentType)
+	}
+	return compiled, nil
+}
+
+// conditional 用于判断路由规则是否设置了条件。
+func (r *route) conditional() bool {
+	return r.urlRegexp != nil || len(r.contentTypes) > 0
+}
+
+// match 用于判断未指定解析函数名称的响应是否适用该路由规则。
+func (r *route) match(resp *module.Response) bool {
+	if r.name != "" && !r.conditional() {
+		return false
+	}
+	httpResp := resp.HTTPResp()
+	if r.urlRegexp != nil && !r.urlRegexp.MatchString(httpResp.Request.URL.String()) {
+		return false
+	}
+	if len(r.contentTypes) > 0 {
+		mediaType := resp.MIMEType()
+		if mediaType == "" {
+			return false
+		}
+		for _, contentType := range r.contentTypes {
+			if matchContentType(contentType, mediaType) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchContentType 用于判断内容类型是否匹配，支持"text/*"这样的形式。
+func matchContentType(pattern, mediaType string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, pattern[:len(pattern)-1])
+	}
+	return pattern == mediaType
+}
+
+// selectRoutes 用于选出处理响应时需要使用的路由规则。
+// 请求指定的名称在分析器中不存在时会返回错误，即使分析器中没有任何有名称的路由规则。
+func (analyzer *myAnalyzer) selectRoutes(resp *module.Response) ([]*route, error) {
+	if name := resp.Callback(); name != "" {
+		r, ok := analyzer.routeMap[name]
+		if !ok {
+			return nil, genError(fmt.Sprintf("unknown response parser: %s", name))
+		}
+		return []*route{r}, nil
+	}
+	var routes []*route
+	for _, r := range analyzer.routes {
+		if r.match(resp) {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
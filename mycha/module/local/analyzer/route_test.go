@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"io/ioutil"
+	"mycha/internal/testutil"
+	"mycha/module"
+	"strings"
+	"testing"
+)
+
+// newTestResponse 用于生成测试用的响应，参数callback为请求指定的解析函数的名称。
+func newTestResponse(t *testing.T, url, contentType, callback string) *module.Response {
+	resp := testutil.NewResponse(t, url, 0, "body", contentType)
+	resp.Request().SetCallback(callback)
+	return resp
+}
+
+// recordParser 用于生成会记录调用的解析函数，记录的内容为名称和读到的响应体。
+func recordParser(name string, hits *[]string) module.ParseResponse {
+	return func(resp *module.Response) ([]module.Data, []error) {
+		b, _ := ioutil.ReadAll(resp.HTTPResp().Body)
+		*hits = append(*hits, name+":"+string(b))
+		return nil, nil
+	}
+}
+
+func TestAnalyzerRoutes(t *testing.T) {
+	var hits []string
+	analyzer, err := NewWithRoutes("A1", []Route{
+		{Name: "detail", Parser: recordParser("detail", &hits)},
+		{Name: "list", URLPattern: `/list`, Parser: recordParser("list", &hits)},
+		{ContentTypes: []string{"text/*"}, Parser: recordParser("text", &hits)},
+		{Parser: recordParser("all", &hits)},
+	}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	cases := []struct {
+		url, contentType, callback string
+		hits                       []string
+		errs                       int
+	}{
+		// 有名称但没有条件的解析函数只在请求指定其名称时使用。
+		{"http://example.com/list", "text/html; charset=utf-8", "",
+			[]string{"list:body", "text:body", "all:body"}, 0},
+		{"http://example.com/a.png", "image/png", "", []string{"all:body"}, 0},
+		{"http://example.com/list", "text/html", "detail", []string{"detail:body"}, 0},
+		{"http://example.com/list", "text/html", "unknown", nil, 1},
+	}
+	for i, c := range cases {
+		hits = nil
+		_, errs := analyzer.Analyze(newTestResponse(t, c.url, c.contentType, c.callback))
+		if len(errs) != c.errs {
+			t.Fatalf("Inconsistent number of errors[%d]: expected: %d, actual: %d (%v)",
+				i, c.errs, len(errs), errs)
+		}
+		if strings.Join(hits, ",") != strings.Join(c.hits, ",") {
+			t.Fatalf("Inconsistent parsers[%d]: expected: %v, actual: %v", i, c.hits, hits)
+		}
+	}
+}
+
+func TestAnalyzerUnknownCallback(t *testing.T) {
+	var hits []string
+	// 没有任何有名称的路由规则时，请求指定的名称也不能被忽略。
+	analyzer, err := New("A1", []module.ParseResponse{recordParser("all", &hits)}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	_, errs := analyzer.Analyze(newTestResponse(t, "http://example.com/", "text/html", "detail"))
+	if len(errs) != 1 || len(hits) != 0 {
+		t.Fatalf("Inconsistent result of unknown callback: errors: %v, parsers: %v", errs, hits)
+	}
+	if completed := analyzer.Completed(); completed != 0 {
+		t.Fatalf("Inconsistent completed count: expected: %d, actual: %d", 0, completed)
+	}
+}
+
+func TestNewWithRoutes(t *testing.T) {
+	parser := recordParser("", new([]string))
+	for i, routes := range [][]Route{
+		{},
+		{{Name: "a"}},
+		{{Name: "a", Parser: parser}, {Name: "a", Parser: parser}},
+		{{URLPattern: `(`, Parser: parser}},
+	} {
+		if _, err := NewWithRoutes("A1", routes, nil); err == nil {
+			t.Fatalf("No error when creating an analyzer with routes[%d]: %+v", i, routes)
+		}
+	}
+}
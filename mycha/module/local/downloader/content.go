@@ -0,0 +1,184 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding 代表下载器支持的内容编码。
+const acceptEncoding = "gzip, deflate, br"
+
+// sniffLen 代表嗅探内容编码和媒体类型时最多预读的字节数。
+const sniffLen = 512
+
+// Options 代表下载器的选项。
+type Options struct {
+	// AllowedContentTypes 代表允许的内容类型，
+	// 可以用"text/*"这样的形式匹配一类内容类型，为空时不限制。
+	// 内容类型不在其中的响应会被丢弃，不会交给分析器。
+	AllowedContentTypes []string
+}
+
+// contentTypeAllowed 用于判断媒体类型是否被允许。
+func (options Options) contentTypeAllowed(mediaType string) bool {
+	if len(options.AllowedContentTypes) == 0 {
+		return true
+	}
+	for _, pattern := range options.AllowedContentTypes {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mediaType, pattern[:len(pattern)-1]) {
+				return true
+			}
+		} else if pattern == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBody 用于按内容编码解压响应体，并在没有内容类型时嗅探媒体类型。
+// 标明了deflate或br时，先尝试按标明的编码解压内容的开头部分，
+// 只有解压失败且内容像未压缩的文本时，才视为标错了内容编码。
+// gzip格式有魔数，所以标为gzip但内容未压缩，或者没有标明但内容是gzip格式时，都以内容为准。
+// 返回解压后的媒体类型。
+func decodeBody(httpResp *http.Response) (string, error) {
+	raw := httpResp.Body
+	br := bufio.NewReaderSize(raw, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	contentType := httpResp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	encoding := strings.ToLower(strings.TrimSpace(httpResp.Header.Get("Content-Encoding")))
+
+	var decoded io.Reader = br
+	var err error
+	switch {
+	case isGzip(head) && (encoding != "" || !isArchiveType(mediaType)):
+		// 没有标明内容编码时，压缩文件本身的下载不应被解压。
+		decoded, err = gzip.NewReader(br)
+	case encoding == "" || encoding == "identity" || len(head) == 0:
+		// 未压缩。
+	case encoding == "gzip" || encoding == "x-gzip":
+		// 标为gzip但不是gzip格式，视为未压缩。
+	case encoding == "deflate" || encoding == "br":
+		if canDecode(encoding, head) {
+			decoded, err = newDecoder(encoding, br, head)
+		} else if !looksLikeText(head) {
+			return "", fmt.Errorf("invalid %s body", encoding)
+		}
+		// 否则标了内容编码但内容实际上未压缩。
+	case looksLikeText(head):
+		// 不支持的内容编码，但内容实际上未压缩。
+	default:
+		return "", fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %s body: %s", encoding, err)
+	}
+	if decoded != io.Reader(br) {
+		httpResp.Header.Del("Content-Encoding")
+		httpResp.Header.Del("Content-Length")
+		httpResp.ContentLength = -1
+		httpResp.Uncompressed = true
+	} else if encoding != "" {
+		httpResp.Header.Del("Content-Encoding")
+	}
+	body := bufio.NewReaderSize(decoded, sniffLen)
+	httpResp.Body = &decodedBody{Reader: body, raw: raw}
+	if mediaType == "" {
+		// 只设置媒体类型，字符集留给之后的解码去判断。
+		head, _ := body.Peek(sniffLen)
+		if len(head) > 0 {
+			mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+			httpResp.Header.Set("Content-Type", mediaType)
+		}
+	}
+	return mediaType, nil
+}
+
+// newDecoder 用于按deflate或br内容编码创建解压的读取器，参数head为内容的开头部分。
+func newDecoder(encoding string, r io.Reader, head []byte) (io.Reader, error) {
+	if encoding == "br" {
+		return brotli.NewReader(r), nil
+	}
+	if isZlib(head) {
+		return zlib.NewReader(r)
+	}
+	// 有的服务器会发送没有zlib头的原始deflate数据。
+	return flate.NewReader(r), nil
+}
+
+// canDecode 用于判断内容的开头部分能否按deflate或br内容编码解压。
+// 开头部分可能被截断，所以解压到末尾时数据不完整不算失败。
+func canDecode(encoding string, head []byte) bool {
+	r, err := newDecoder(encoding, bytes.NewReader(head), head)
+	if err != nil {
+		return false
+	}
+	_, err = io.Copy(ioutil.Discard, r)
+	return err == nil || err == io.ErrUnexpectedEOF
+}
+
+// isGzip 用于判断数据是否以gzip格式的魔数开头。
+func isGzip(head []byte) bool {
+	return len(head) >= 3 && head[0] == 0x1f && head[1] == 0x8b && head[2] == 8
+}
+
+// isZlib 用于判断数据是否以zlib格式的头开头。
+func isZlib(head []byte) bool {
+	return len(head) >= 2 && head[0]&0x0f == 8 && (uint(head[0])<<8|uint(head[1]))%31 == 0
+}
+
+// isArchiveType 用于判断媒体类型是否代表压缩文件本身。
+func isArchiveType(mediaType string) bool {
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/x-tar",
+		"application/octet-stream":
+		return true
+	}
+	return false
+}
+
+// looksLikeText 用于判断数据是否像未压缩的文本。
+// 压缩后的数据几乎不可能是由可打印字符组成的合法UTF-8。
+func looksLikeText(head []byte) bool {
+	if len(head) == 0 {
+		return true
+	}
+	// 去掉末尾可能被截断的字符。
+	for i := 1; i < utf8.UTFMax && len(head) == sniffLen && head[len(head)-1] >= utf8.RuneSelf; i++ {
+		head = head[:len(head)-1]
+	}
+	if !utf8.Valid(head) {
+		return false
+	}
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+// decodedBody 代表解压后的响应体，关闭时会关闭原始的响应体。
+type decodedBody struct {
+	io.Reader
+	raw io.ReadCloser
+}
+
+func (body *decodedBody) Close() error {
+	return body.raw.Close()
+}
@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"mycha/module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+const testPage = "<html><body>hello, 世界</body></html>"
+
+// compress 用于按给定的格式压缩数据。
+func compress(format string, data string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		return []byte(data)
+	}
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+// contentCase 代表一次下载的测试用例。
+type contentCase struct {
+	name            string
+	body            []byte
+	contentEncoding string
+	contentType     string
+	// mimeType 代表期望的媒体类型，为空时期望下载出错。
+	mimeType string
+	text     string
+}
+
+// newContentServer 用于创建按当前的测试用例响应的服务器。
+func newContentServer(t *testing.T, current **contentCase) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := *current
+		if r.Header.Get("Accept-Encoding") != acceptEncoding {
+			t.Errorf("Inconsistent Accept-Encoding: expected: %q, actual: %q",
+				acceptEncoding, r.Header.Get("Accept-Encoding"))
+		}
+		// 不设置时http包会自动嗅探内容类型。
+		w.Header()["Content-Type"] = nil
+		if c.contentType != "" {
+			w.Header().Set("Content-Type", c.contentType)
+		}
+		if c.contentEncoding != "" {
+			w.Header().Set("Content-Encoding", c.contentEncoding)
+		}
+		w.Write(c.body)
+	}))
+}
+
+func TestDownloadContent(t *testing.T) {
+	gzipped := compress("gzip", testPage)
+	cases := []contentCase{
+		{"gzip", gzipped, "gzip", "text/html", "text/html", testPage},
+		{"zlib", compress("zlib", testPage), "deflate", "text/html", "text/html", testPage},
+		{"raw deflate", compress("flate", testPage), "deflate", "text/html", "text/html", testPage},
+		{"br", compress("br", testPage), "br", "text/html", "text/html", testPage},
+		// 空内容按br压缩后为";"，看起来像文本，但应按标明的编码解压。
+		{"empty br", compress("br", ""), "br", "text/plain", "text/plain", ""},
+		{"mislabeled gzip", []byte(testPage), "gzip", "text/html", "text/html", testPage},
+		{"mislabeled deflate", []byte(testPage), "deflate", "text/html", "text/html", testPage},
+		{"mislabeled br", []byte(testPage), "br", "text/html", "text/html", testPage},
+		{"unlabeled gzip", gzipped, "", "text/html", "text/html", testPage},
+		{"sniffed type", gzipped, "gzip", "", "text/html", testPage},
+		// 没有标明内容编码时，压缩文件本身不应被解压。
+		{"archive", gzipped, "", "application/gzip", "application/gzip", string(gzipped)},
+		{"invalid br", []byte{0xff, 0xfe, 0x00, 0x01, 0x02}, "br", "text/html", "", ""},
+		{"unsupported", []byte{0xff, 0xfe, 0x00}, "compress", "text/html", "", ""},
+	}
+	var current *contentCase
+	server := newContentServer(t, &current)
+	defer server.Close()
+	d, err := New("D1", &http.Client{}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	for i := range cases {
+		c := &cases[i]
+		current = c
+		httpReq, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := d.Download(module.NewRequest(httpReq, 0))
+		if c.mimeType == "" {
+			if err == nil {
+				t.Fatalf("No error when downloading %s", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when downloading %s: %s", c.name, err)
+		}
+		httpResp := resp.HTTPResp()
+		b, err := ioutil.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		if err != nil {
+			t.Fatalf("An error occurs when reading %s: %s", c.name, err)
+		}
+		if string(b) != c.text {
+			t.Fatalf("Inconsistent body of %s: expected: %q, actual: %q", c.name, c.text, b)
+		}
+		if resp.MIMEType() != c.mimeType || httpResp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("Inconsistent headers of %s: expected type: %s, actual: (%s, encoding: %s)",
+				c.name, c.mimeType, resp.MIMEType(), httpResp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestDownloadAllowedContentTypes(t *testing.T) {
+	current := &contentCase{body: compress("gzip", testPage), contentType: "application/gzip"}
+	server := newContentServer(t, &current)
+	defer server.Close()
+	d, err := NewWithOptions("D1", &http.Client{},
+		Options{AllowedContentTypes: []string{"text/*"}}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	httpReq, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatalf("No error when downloading a content type that is not allowed")
+	}
+	current = &contentCase{body: []byte(testPage), contentType: "text/html; charset=utf-8"}
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err != nil {
+		t.Fatalf("An error occurs when downloading an allowed content type: %s", err)
+	}
+}
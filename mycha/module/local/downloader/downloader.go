@@ -1,17 +1,25 @@
 package downloader
 
 import (
+	"fmt"
 	"mycha/errors"
 	"mycha/helper/log"
 	"mycha/module"
 	"mycha/module/stub"
 	"net/http"
+	"net/url"
 )
 
 var logger = log.DLogger()
 
 func New(mid module.MID,client *http.Client,
 	  scoreCalculator module.CalculateScore) (module.Downloader,error) {
+	return NewWithOptions(mid, client, Options{}, scoreCalculator)
+}
+
+// NewWithOptions 用于创建一个带有选项的下载器实例。
+func NewWithOptions(mid module.MID, client *http.Client, options Options,
+	scoreCalculator module.CalculateScore) (module.Downloader, error) {
 	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
 	if err != nil {
 		return nil, err
@@ -22,6 +30,7 @@ func New(mid module.MID,client *http.Client,
 	return &myDownloader{
 		ModuleInternal: moduleBase,
 		httpClient:     *client,
+		options:        options,
 	}, nil
 }
 
@@ -29,6 +38,8 @@ func New(mid module.MID,client *http.Client,
 type myDownloader struct {
 	stub.ModuleInternal
 	httpClient http.Client
+	// options 代表下载器的选项。
+	options Options
 }
 
 
@@ -45,12 +56,80 @@ func (downloader *myDownloader) Download(req *module.Request) (*module.Response,
 	}
 	downloader.ModuleInternal.IncrAcceptedCount()
 	logger.Infof("Do the request (URL: %s, depth: %d)... \n", httpReq.URL, req.Depth())
-	httpResp, err := downloader.httpClient.Do(httpReq)
+	if httpReq.Header.Get("Accept-Encoding") == "" {
+		// 自行声明支持的内容编码后，http.Client不会再自动解压，由下载器统一处理。
+		httpReq = httpReq.Clone(httpReq.Context())
+		httpReq.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	// 复制客户端，以便记录和检查本次下载经过的跳转。
+	client := downloader.httpClient
+	var redirects []module.Redirect
+	var rejected *module.RedirectError
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		redirect := module.Redirect{
+			Kind: module.REDIRECT_HTTP,
+			From: via[len(via)-1].URL.String(),
+			To:   next.URL.String(),
+		}
+		if next.Response != nil {
+			redirect.StatusCode = next.Response.StatusCode
+		}
+		if err := downloader.checkRedirect(req, next, via); err != nil {
+			if err == http.ErrUseLastResponse {
+				return err
+			}
+			rejected = &module.RedirectError{Redirect: redirect, Err: err}
+			return rejected
+		}
+		redirects = append(redirects, redirect)
+		return nil
+	}
+	httpResp, err := client.Do(httpReq)
 	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok && rejected != nil && urlErr.Err == rejected {
+			return nil, rejected
+		}
 		return nil, err
 	}
+	mediaType, err := decodeBody(httpResp)
+	if err != nil {
+		httpResp.Body.Close()
+		return nil, errors.NewCrawlerError(errors.ERROR_TYPE_DOWNLOADER,
+			fmt.Sprintf("%s (URL: %s)", err, httpReq.URL))
+	}
+	if !downloader.options.contentTypeAllowed(mediaType) {
+		// 在响应进入缓冲池之前丢弃不需要的内容。
+		httpResp.Body.Close()
+		return nil, errors.NewCrawlerError(errors.ERROR_TYPE_DOWNLOADER,
+			fmt.Sprintf("content type %q is not allowed (URL: %s)", mediaType, httpReq.URL))
+	}
 	downloader.ModuleInternal.IncrCompletedCount()
-	return module.NewResponse(httpResp, req.Depth()), nil
+	resp := module.NewResponseFor(httpResp, req)
+	resp.SetRedirects(redirects)
+	return resp, nil
+}
+
+// defaultMaxRedirects 代表请求和客户端都没有检查跳转的函数时的跳转上限，与http.Client的默认行为相同。
+const defaultMaxRedirects = 10
+
+// checkRedirect 用于检查一次HTTP跳转。
+// 请求带有检查跳转的函数时先用它检查，再用客户端自身的检查函数检查，
+// 都没有时按defaultMaxRedirects限制跳转次数。
+func (downloader *myDownloader) checkRedirect(
+	req *module.Request, next *http.Request, via []*http.Request) error {
+	policy := req.RedirectPolicy()
+	if policy != nil {
+		if err := policy(via[len(via)-1].URL, next.URL, len(via)); err != nil {
+			return err
+		}
+	}
+	if downloader.httpClient.CheckRedirect != nil {
+		return downloader.httpClient.CheckRedirect(next, via)
+	}
+	if policy == nil && len(via) >= defaultMaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", defaultMaxRedirects)
+	}
+	return nil
 }
 
 
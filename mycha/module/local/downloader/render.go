@@ -0,0 +1,367 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mycha/errors"
+	"mycha/module"
+	"mycha/module/stub"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 默认的渲染选项。
+const (
+	defaultMaxHops         = 5
+	defaultMaxRefreshDelay = 10 * time.Second
+	defaultMaxScanSize     = 256 << 10
+)
+
+// RenderOptions 代表渲染器的选项。
+type RenderOptions struct {
+	// MaxHops 代表一次下载中最多经过的跳转次数，为0时为5。
+	// HTTP跳转和页面中的跳转一起计数，与传给检查跳转的函数的次数相同，
+	// 所以和调度器的MaxRedirects共用同一个上限，先达到的那个生效。
+	// 达到上限后不再跟随页面中的跳转，并返回最后下载到的响应。
+	MaxHops int
+	// MaxRefreshDelay 代表跟随的<meta http-equiv="refresh">的最大延迟，为0时为10秒。
+	// 延迟更长的通常是定时刷新，而不是跳转。
+	MaxRefreshDelay time.Duration
+	// MaxScanSize 代表在响应体中查找跳转时最多读取的字节数，为0时为256KB。
+	MaxScanSize int
+}
+
+// NewRenderer 用于创建一个会跟随页面中简单跳转的下载器。
+// 它不执行JavaScript，只识别以下几种跳转，优先级从高到低：
+// <meta http-equiv="refresh" content="0; url=...">；
+// <script>中的location = "..."、location.href = "..."、
+// location.replace("...")和location.assign("...")；
+// <noscript>中的<meta http-equiv="refresh">，
+// 以及页面中除了<noscript>以外没有链接时，<noscript>中唯一的链接。
+// 最终的链接和经过的跳转会被记录在响应中。
+// 请求带有检查跳转的函数时，这些跳转也会经过它的检查。
+// 渲染器有自己的计数，一次下载无论经过多少次页面中的跳转都只计为一次，
+// 组件ID和评分计算器与被包装的下载器相同，被包装的下载器不需要再注册。
+func NewRenderer(downloader module.Downloader, options RenderOptions) (module.Downloader, error) {
+	if downloader == nil {
+		return nil, errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER, "nil downloader")
+	}
+	if options.MaxHops < 0 || options.MaxRefreshDelay < 0 || options.MaxScanSize < 0 {
+		return nil, errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER, "negative render option")
+	}
+	if options.MaxHops == 0 {
+		options.MaxHops = defaultMaxHops
+	}
+	if options.MaxRefreshDelay == 0 {
+		options.MaxRefreshDelay = defaultMaxRefreshDelay
+	}
+	if options.MaxScanSize == 0 {
+		options.MaxScanSize = defaultMaxScanSize
+	}
+	moduleBase, err := stub.NewModuleInternal(downloader.ID(), downloader.ScoreCalculator())
+	if err != nil {
+		return nil, err
+	}
+	if weighted, ok := downloader.(module.Weighted); ok {
+		moduleBase.SetWeight(weighted.Weight())
+	}
+	return &renderer{ModuleInternal: moduleBase, downloader: downloader, options: options}, nil
+}
+
+// renderer 代表渲染器的实现类型。
+type renderer struct {
+	// stub.ModuleInternal 代表渲染器自己的组件基础实例。
+	stub.ModuleInternal
+	// downloader 代表被包装的下载器，每一次跳转都会用它下载。
+	downloader module.Downloader
+	options    RenderOptions
+}
+
+func (r *renderer) Download(req *module.Request) (*module.Response, error) {
+	r.ModuleInternal.IncrHandlingNumber()
+	defer r.ModuleInternal.DecrHandlingNumber()
+	r.ModuleInternal.IncrCalledCount()
+	if req == nil || !req.Valid() {
+		return nil, errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER, "invalid request")
+	}
+	r.ModuleInternal.IncrAcceptedCount()
+	resp, err := r.render(req)
+	if err != nil {
+		return nil, err
+	}
+	r.ModuleInternal.IncrCompletedCount()
+	return resp, nil
+}
+
+// render 用于下载请求，并跟随页面中的跳转。
+func (r *renderer) render(req *module.Request) (*module.Response, error) {
+	resp, err := r.downloader.Download(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errors.NewCrawlerError(errors.ERROR_TYPE_DOWNLOADER,
+			fmt.Sprintf("nil response (URL: %s)", req.HTTPReq().URL))
+	}
+	redirects := resp.Redirects()
+	visited := map[string]bool{stripFragment(resp.FinalURL()): true}
+	for {
+		kind, target := r.findRedirect(resp)
+		if target == nil || visited[stripFragment(target.String())] {
+			break
+		}
+		if len(redirects) >= r.options.MaxHops {
+			logger.Warnf("Too many page redirects (URL: %s, max: %d)\n",
+				req.HTTPReq().URL, r.options.MaxHops)
+			break
+		}
+		resp.HTTPResp().Body.Close()
+		from := resp.FinalURL()
+		redirect := module.Redirect{Kind: kind, From: from, To: target.String()}
+		policy := req.RedirectPolicy()
+		if policy != nil {
+			if err := policy(resp.HTTPResp().Request.URL, target, len(redirects)+1); err != nil {
+				return nil, &module.RedirectError{Redirect: redirect, Err: err}
+			}
+		}
+		httpReq := req.HTTPReq().Clone(req.HTTPReq().Context())
+		httpReq.Method = http.MethodGet
+		httpReq.URL = target
+		httpReq.Host = ""
+		httpReq.Body = nil
+		httpReq.GetBody = nil
+		httpReq.ContentLength = 0
+		httpReq.Header.Del("Content-Type")
+		httpReq.Header.Set("Referer", from)
+		nextReq := req.Redirected(httpReq)
+		if policy != nil {
+			// 之后的HTTP跳转接着已经经过的跳转计数。
+			offset := len(redirects) + 1
+			nextReq.SetRedirectPolicy(func(from, to *url.URL, hops int) error {
+				return policy(from, to, offset+hops)
+			})
+		}
+		next, err := r.downloader.Download(nextReq)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			return nil, errors.NewCrawlerError(errors.ERROR_TYPE_DOWNLOADER,
+				fmt.Sprintf("nil response (URL: %s)", target))
+		}
+		redirects = append(redirects, redirect)
+		redirects = append(redirects, next.Redirects()...)
+		resp = next
+		visited[stripFragment(resp.FinalURL())] = true
+	}
+	if len(redirects) == 0 {
+		return resp, nil
+	}
+	// 让响应关联到原请求，以保留其元数据和父请求。
+	final := module.NewResponseFor(resp.HTTPResp(), req)
+	final.SetRedirects(redirects)
+	return final, nil
+}
+
+// findRedirect 用于在HTML页面中查找跳转，返回跳转的种类和链接，没有时链接为nil。
+// 它只读取响应体开头的一部分，响应体仍然可以完整地读取。
+func (r *renderer) findRedirect(resp *module.Response) (string, *url.URL) {
+	httpResp := resp.HTTPResp()
+	if httpResp == nil || httpResp.Body == nil || httpResp.StatusCode != http.StatusOK ||
+		httpResp.Request == nil || httpResp.Request.URL == nil {
+		return "", nil
+	}
+	mediaType := resp.MIMEType()
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", nil
+	}
+	br := bufio.NewReaderSize(httpResp.Body, r.options.MaxScanSize)
+	head, _ := br.Peek(r.options.MaxScanSize)
+	httpResp.Body = &decodedBody{Reader: br, raw: httpResp.Body}
+	page := scanPage(head, httpResp.Request.URL)
+	for _, refresh := range page.refreshes {
+		if refresh.delay <= r.options.MaxRefreshDelay {
+			return module.REDIRECT_META_REFRESH, refresh.target
+		}
+	}
+	if len(page.scriptTargets) > 0 {
+		return module.REDIRECT_JAVASCRIPT, page.scriptTargets[0]
+	}
+	for _, refresh := range page.noscriptRefreshes {
+		if refresh.delay <= r.options.MaxRefreshDelay {
+			return module.REDIRECT_NOSCRIPT, refresh.target
+		}
+	}
+	if page.links == 0 && len(page.noscriptLinks) == 1 {
+		return module.REDIRECT_NOSCRIPT, page.noscriptLinks[0]
+	}
+	return "", nil
+}
+
+// refresh 代表<meta http-equiv="refresh">中的跳转。
+type refresh struct {
+	delay  time.Duration
+	target *url.URL
+}
+
+// pageScan 代表在页面中找到的跳转和链接。
+type pageScan struct {
+	refreshes         []refresh
+	scriptTargets     []*url.URL
+	noscriptRefreshes []refresh
+	noscriptLinks     []*url.URL
+	// links 代表<noscript>以外的链接的数量。
+	links int
+}
+
+// scanPage 用于在HTML中查找跳转和链接，链接会按<base href>或页面的链接解析。
+func scanPage(data []byte, pageURL *url.URL) *pageScan {
+	page := &pageScan{}
+	base := pageURL
+	var noscripts []string
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := z.Token()
+		switch {
+		case tt == html.StartTagToken && token.DataAtom == atom.Script:
+			if z.Next() == html.TextToken {
+				for _, target := range scriptTargets(string(z.Text())) {
+					if u := resolveTarget(base, target); u != nil {
+						page.scriptTargets = append(page.scriptTargets, u)
+					}
+				}
+			}
+		case tt == html.StartTagToken && token.DataAtom == atom.Noscript:
+			// 分词器把<noscript>中的内容当作文本，需要再次分析。
+			if z.Next() == html.TextToken {
+				noscripts = append(noscripts, string(z.Text()))
+			}
+		case tt != html.StartTagToken && tt != html.SelfClosingTagToken:
+		case token.DataAtom == atom.Base:
+			if href, ok := attr(token, "href"); ok {
+				if u := resolveTarget(pageURL, href); u != nil {
+					base = u
+				}
+			}
+		case token.DataAtom == atom.Meta:
+			if r, ok := parseRefresh(token, base); ok {
+				page.refreshes = append(page.refreshes, r)
+			}
+		case token.DataAtom == atom.A:
+			if _, ok := attr(token, "href"); ok {
+				page.links++
+			}
+		}
+	}
+	for _, noscript := range noscripts {
+		inner := html.NewTokenizer(strings.NewReader(noscript))
+		for {
+			tt := inner.Next()
+			if tt == html.ErrorToken {
+				break
+			}
+			if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+				continue
+			}
+			token := inner.Token()
+			switch token.DataAtom {
+			case atom.Meta:
+				if r, ok := parseRefresh(token, base); ok {
+					page.noscriptRefreshes = append(page.noscriptRefreshes, r)
+				}
+			case atom.A:
+				if href, ok := attr(token, "href"); ok {
+					if u := resolveTarget(base, href); u != nil {
+						page.noscriptLinks = append(page.noscriptLinks, u)
+					}
+				}
+			}
+		}
+	}
+	return page
+}
+
+// refreshPattern 代表<meta http-equiv="refresh">的content属性的格式。
+var refreshPattern = regexp.MustCompile(
+	`(?i)^\s*(\d+(?:\.\d*)?)\s*[;,]?\s*(?:url\s*=\s*)?(?:"([^"]*)"?|'([^']*)'?|(\S.*?))\s*$`)
+
+// parseRefresh 用于解析<meta http-equiv="refresh">，没有链接的只是刷新本页面，会被忽略。
+func parseRefresh(token html.Token, base *url.URL) (refresh, bool) {
+	equiv, _ := attr(token, "http-equiv")
+	if !strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+		return refresh{}, false
+	}
+	content, _ := attr(token, "content")
+	match := refreshPattern.FindStringSubmatch(content)
+	if match == nil {
+		return refresh{}, false
+	}
+	seconds, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return refresh{}, false
+	}
+	target := resolveTarget(base, match[2]+match[3]+match[4])
+	if target == nil {
+		return refresh{}, false
+	}
+	return refresh{delay: time.Duration(seconds * float64(time.Second)), target: target}, true
+}
+
+// locationPattern 代表JavaScript中的跳转语句。
+var locationPattern = regexp.MustCompile(
+	`(?:^|[^\w$.])(?:(?:window|document|top|self)\s*\.\s*)*location` +
+		`(?:\s*\.\s*href\s*=\s*|\s*=\s*|\s*\.\s*(?:replace|assign)\s*\(\s*)` +
+		`(?:"([^"]*)"|'([^']*)')`)
+
+// scriptTargets 用于找出脚本中跳转的链接。
+func scriptTargets(script string) []string {
+	var targets []string
+	for _, match := range locationPattern.FindAllStringSubmatch(script, -1) {
+		targets = append(targets, match[1]+match[2])
+	}
+	return targets
+}
+
+// resolveTarget 用于解析跳转的链接，只接受http和https链接。
+func resolveTarget(base *url.URL, ref string) *url.URL {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil
+	}
+	u.Fragment = ""
+	return u
+}
+
+// stripFragment 用于去掉链接中的片段。
+func stripFragment(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
+// attr 用于获取标签的属性值。
+func attr(token html.Token, key string) (string, bool) {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
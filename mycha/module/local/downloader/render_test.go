@@ -0,0 +1,227 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mycha/module"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// renderPages 代表测试用的页面，其中的%s会被替换为服务器的链接。
+var renderPages = map[string]string{
+	"/start":   `<html><head><meta http-equiv="Refresh" content="0; URL='/js'"></head></html>`,
+	"/js":      `<html><script>if (x == 1) {}; window.location.href = "/ns#frag";</script></html>`,
+	"/ns":      `<html><body><script>go()</script><noscript><a href="/final">continue</a></noscript></body></html>`,
+	"/final":   `<html><body><a href="/x">x</a>done</body></html>`,
+	"/loop":    `<html><meta http-equiv="refresh" content="0;url=/loop2"></html>`,
+	"/loop2":   `<html><meta http-equiv="refresh" content="0;url=/loop"></html>`,
+	"/slow":    `<html><meta http-equiv="refresh" content="300;url=/final"></html>`,
+	"/replace": `<script>location.replace('%s/final')</script>`,
+	"/page":    `<meta http-equiv="refresh" content="0;url=/c">`,
+}
+
+// newRenderServer 用于创建返回测试页面的服务器。
+// 其中/a、/b和/c会经过HTTP跳转，分别跳转到/b、/page和/done。
+func newRenderServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+			return
+		case "/b":
+			http.Redirect(w, r, "/page", http.StatusFound)
+			return
+		case "/c":
+			http.Redirect(w, r, "/done", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		body, ok := renderPages[r.URL.Path]
+		if !ok {
+			body = "done"
+		}
+		if strings.Contains(body, "%s") {
+			body = fmt.Sprintf(body, server.URL)
+		}
+		fmt.Fprint(w, body)
+	}))
+	return server
+}
+
+// newRenderer 用于创建测试用的渲染器。
+func newRenderer(t *testing.T, options RenderOptions) module.Downloader {
+	inner, err := New("D1", &http.Client{}, module.CalculateScoreSimple)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	d, err := NewRenderer(inner, options)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a renderer: %s", err)
+	}
+	return d
+}
+
+// newRenderRequest 用于生成测试用的请求。
+func newRenderRequest(server *httptest.Server, path string) *module.Request {
+	httpReq, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	return module.NewRequest(httpReq, 2)
+}
+
+func TestRendererFollow(t *testing.T) {
+	server := newRenderServer()
+	defer server.Close()
+	d := newRenderer(t, RenderOptions{})
+	cases := []struct {
+		path  string
+		final string
+		kinds []string
+	}{
+		{"/start", "/final", []string{module.REDIRECT_META_REFRESH,
+			module.REDIRECT_JAVASCRIPT, module.REDIRECT_NOSCRIPT}},
+		{"/replace", "/final", []string{module.REDIRECT_JAVASCRIPT}},
+		// 回到已经访问过的页面时停止跟随。
+		{"/loop", "/loop2", []string{module.REDIRECT_META_REFRESH}},
+		// 延迟太长的只是定时刷新。
+		{"/slow", "/slow", nil},
+		{"/a", "/done", []string{module.REDIRECT_HTTP, module.REDIRECT_HTTP,
+			module.REDIRECT_META_REFRESH, module.REDIRECT_HTTP}},
+	}
+	for _, c := range cases {
+		req := newRenderRequest(server, c.path)
+		req.SetMeta("k", "v")
+		resp, err := d.Download(req)
+		if err != nil {
+			t.Fatalf("An error occurs when rendering %s: %s", c.path, err)
+		}
+		if resp.FinalURL() != server.URL+c.final {
+			t.Fatalf("Inconsistent final URL of %s: expected: %s, actual: %s",
+				c.path, server.URL+c.final, resp.FinalURL())
+		}
+		var kinds []string
+		for _, redirect := range resp.Redirects() {
+			kinds = append(kinds, redirect.Kind)
+		}
+		if fmt.Sprint(kinds) != fmt.Sprint(c.kinds) {
+			t.Fatalf("Inconsistent redirects of %s: expected: %v, actual: %v", c.path, c.kinds, kinds)
+		}
+		// 响应仍然关联到原请求。
+		if resp.Request() != req || resp.Depth() != 2 || resp.Request().Meta()["k"] != "v" {
+			t.Fatalf("The response of %s is not associated with the original request", c.path)
+		}
+		b, _ := ioutil.ReadAll(resp.HTTPResp().Body)
+		resp.HTTPResp().Body.Close()
+		if want := renderPages[c.final]; want != "" && !strings.Contains(want, "%s") && string(b) != want {
+			t.Fatalf("Inconsistent body of %s: expected: %q, actual: %q", c.path, want, b)
+		}
+	}
+	resp, err := d.Download(newRenderRequest(server, "/start"))
+	if err != nil {
+		t.Fatalf("An error occurs when rendering: %s", err)
+	}
+	if referer := resp.HTTPResp().Request.Header.Get("Referer"); referer != server.URL+"/ns" {
+		t.Fatalf("Inconsistent referer: expected: %s, actual: %s", server.URL+"/ns", referer)
+	}
+}
+
+func TestRendererCounts(t *testing.T) {
+	server := newRenderServer()
+	defer server.Close()
+	d := newRenderer(t, RenderOptions{})
+	if _, err := d.Download(newRenderRequest(server, "/start")); err != nil {
+		t.Fatalf("An error occurs when rendering: %s", err)
+	}
+	// 无论经过多少次跳转，一次渲染只计为一次下载。
+	if d.CallCount() != 1 || d.AcceptedCount() != 1 || d.Completed() != 1 || d.Handling() != 0 {
+		t.Fatalf("Inconsistent counts: expected: (1, 1, 1, 0), actual: (%d, %d, %d, %d)",
+			d.CallCount(), d.AcceptedCount(), d.Completed(), d.Handling())
+	}
+	if _, err := d.Download(nil); err == nil {
+		t.Fatalf("No error when rendering a nil request")
+	}
+	if d.CallCount() != 2 || d.AcceptedCount() != 1 || d.Completed() != 1 {
+		t.Fatalf("Inconsistent counts: expected: (2, 1, 1), actual: (%d, %d, %d)",
+			d.CallCount(), d.AcceptedCount(), d.Completed())
+	}
+	if d.ID() != "D1" {
+		t.Fatalf("Inconsistent MID: expected: %s, actual: %s", "D1", d.ID())
+	}
+}
+
+func TestRendererMaxHops(t *testing.T) {
+	server := newRenderServer()
+	defer server.Close()
+	cases := []struct {
+		path    string
+		maxHops int
+		final   string
+		hops    int
+	}{
+		{"/start", 1, "/js", 1},
+		{"/start", 2, "/ns", 2},
+		// HTTP跳转也计入上限，经过两次HTTP跳转后不再跟随页面中的跳转。
+		{"/a", 2, "/page", 2},
+		{"/a", 3, "/done", 4},
+	}
+	for _, c := range cases {
+		d := newRenderer(t, RenderOptions{MaxHops: c.maxHops})
+		resp, err := d.Download(newRenderRequest(server, c.path))
+		if err != nil {
+			t.Fatalf("An error occurs when rendering %s with %d hops: %s", c.path, c.maxHops, err)
+		}
+		if resp.FinalURL() != server.URL+c.final || len(resp.Redirects()) != c.hops {
+			t.Fatalf("Inconsistent result of %s with %d hops: expected: (%s, %d), actual: (%s, %d)",
+				c.path, c.maxHops, server.URL+c.final, c.hops, resp.FinalURL(), len(resp.Redirects()))
+		}
+	}
+	if _, err := NewRenderer(nil, RenderOptions{}); err == nil {
+		t.Fatalf("No error when creating a renderer with a nil downloader")
+	}
+	inner, _ := New("D1", &http.Client{}, module.CalculateScoreSimple)
+	if _, err := NewRenderer(inner, RenderOptions{MaxHops: -1}); err == nil {
+		t.Fatalf("No error when creating a renderer with negative options")
+	}
+}
+
+func TestRendererRedirectPolicy(t *testing.T) {
+	server := newRenderServer()
+	defer server.Close()
+	d := newRenderer(t, RenderOptions{})
+	// HTTP跳转和页面中的跳转连续计数。
+	var seen []string
+	req := newRenderRequest(server, "/a")
+	req.SetRedirectPolicy(func(from, to *url.URL, hops int) error {
+		seen = append(seen, fmt.Sprintf("%s>%s#%d", from.Path, to.Path, hops))
+		if to.Path == "/done" {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	resp, err := d.Download(req)
+	redirectErr, ok := err.(*module.RedirectError)
+	if !ok || resp != nil || redirectErr.Redirect.To != server.URL+"/done" {
+		t.Fatalf("Inconsistent error of a rejected HTTP redirect: %v", err)
+	}
+	expected := "[/a>/b#1 /b>/page#2 /page>/c#3 /c>/done#4]"
+	if fmt.Sprint(seen) != expected {
+		t.Fatalf("Inconsistent redirects: expected: %s, actual: %v", expected, seen)
+	}
+
+	req = newRenderRequest(server, "/page")
+	req.SetRedirectPolicy(func(from, to *url.URL, hops int) error {
+		return errors.New("rejected")
+	})
+	_, err = d.Download(req)
+	redirectErr, ok = err.(*module.RedirectError)
+	if !ok || redirectErr.Redirect.Kind != module.REDIRECT_META_REFRESH {
+		t.Fatalf("Inconsistent error of a rejected page redirect: %v", err)
+	}
+	if d.Completed() != 0 {
+		t.Fatalf("Inconsistent completed count: expected: %d, actual: %d", 0, d.Completed())
+	}
+}
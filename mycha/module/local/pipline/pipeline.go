@@ -1,111 +1,167 @@
-package pipeline
-
-import (
-	"fmt"
-
-	"gopcp.v2/chapter6/webcrawler/module"
-	"gopcp.v2/chapter6/webcrawler/module/stub"
-	"gopcp.v2/helper/log"
-)
-
-// logger 代表日志记录器。
-var logger = log.DLogger()
-
-// New 用于创建一个条目处理管道实例。
-func New(
-	mid module.MID,
-	itemProcessors []module.ProcessItem,
-	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
-	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
-	if err != nil {
-		return nil, err
-	}
-	if itemProcessors == nil {
-		return nil, genParameterError("nil item processor list")
-	}
-	if len(itemProcessors) == 0 {
-		return nil, genParameterError("empty item processor list")
-	}
-	var innerProcessors []module.ProcessItem
-	for i, pipeline := range itemProcessors {
-		if pipeline == nil {
-			err := genParameterError(fmt.Sprintf("nil item processor[%d]", i))
-			return nil, err
-		}
-		innerProcessors = append(innerProcessors, pipeline)
-	}
-	return &myPipeline{
-		ModuleInternal: moduleBase,
-		itemProcessors: innerProcessors,
-	}, nil
-}
-
-// myPipeline 代表条目处理管道的实现类型。
-type myPipeline struct {
-	// stub.ModuleInternal 代表组件基础实例。
-	stub.ModuleInternal
-	// itemProcessors 代表条目处理器的列表。
-	itemProcessors []module.ProcessItem
-	// failFast 代表处理是否需要快速失败。
-	failFast bool
-}
-
-func (pipeline *myPipeline) ItemProcessors() []module.ProcessItem {
-	processors := make([]module.ProcessItem, len(pipeline.itemProcessors))
-	copy(processors, pipeline.itemProcessors)
-	return processors
-}
-
-func (pipeline *myPipeline) Send(item module.Item) []error {
-	pipeline.ModuleInternal.IncrHandlingNumber()
-	defer pipeline.ModuleInternal.DecrHandlingNumber()
-	pipeline.ModuleInternal.IncrCalledCount()
-	var errs []error
-	if item == nil {
-		err := genParameterError("nil item")
-		errs = append(errs, err)
-		return errs
-	}
-	pipeline.ModuleInternal.IncrAcceptedCount()
-	logger.Infof("Process item %+v... \n", item)
-	var currentItem = item
-	for _, processor := range pipeline.itemProcessors {
-		processedItem, err := processor(currentItem)
-		if err != nil {
-			errs = append(errs, err)
-			if pipeline.failFast {
-				break
-			}
-		}
-		if processedItem != nil {
-			currentItem = processedItem
-		}
-	}
-	if len(errs) == 0 {
-		pipeline.ModuleInternal.IncrCompletedCount()
-	}
-	return errs
-}
-
-func (pipeline *myPipeline) FailFast() bool {
-	return pipeline.failFast
-}
-
-func (pipeline *myPipeline) SetFailFast(failFast bool) {
-	pipeline.failFast = failFast
-}
-
-// extraSummaryStruct 代表条目处理管道实额外信息的摘要类型。
-type extraSummaryStruct struct {
-	FailFast        bool `json:"fail_fast"`
-	ProcessorNumber int  `json:"processor_number"`
-}
-
-func (pipeline *myPipeline) Summary() module.SummaryStruct {
-	summary := pipeline.ModuleInternal.Summary()
-	summary.Extra = extraSummaryStruct{
-		FailFast:        pipeline.failFast,
-		ProcessorNumber: len(pipeline.itemProcessors),
-	}
-	return summary
-}
+package pipeline
+
+import (
+	"fmt"
+
+	"mycha/helper/log"
+	"mycha/module"
+	"mycha/module/stub"
+)
+
+// logger 代表日志记录器。
+var logger = log.DLogger()
+
+// New 用于创建一个条目处理管道实例。
+// 所有条目都会交给同一组条目处理器处理。
+func New(
+	mid module.MID,
+	itemProcessors []module.ProcessItem,
+	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
+	if itemProcessors == nil {
+		return nil, genParameterError("nil item processor list")
+	}
+	return NewWithChains(mid, []Chain{{Processors: itemProcessors}}, scoreCalculator)
+}
+
+// Chain 代表处理某一种条目的条目处理器链。
+type Chain struct {
+	// Kind 代表条目的种类，为空时处理所有没有对应的链的条目。
+	Kind string
+	// Processors 代表条目处理器的列表。
+	Processors []module.ProcessItem
+}
+
+// NewWithChains 用于创建一个按条目的种类选择条目处理器链的条目处理管道实例。
+func NewWithChains(
+	mid module.MID,
+	chains []Chain,
+	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) == 0 {
+		return nil, genParameterError("empty item processor chain list")
+	}
+	chainMap := map[string][]module.ProcessItem{}
+	var kinds []string
+	for _, chain := range chains {
+		if _, ok := chainMap[chain.Kind]; ok {
+			return nil, genParameterError(
+				fmt.Sprintf("duplicate item processor chain for kind %q", chain.Kind))
+		}
+		if chain.Processors == nil {
+			return nil, genParameterError("nil item processor list")
+		}
+		if len(chain.Processors) == 0 {
+			return nil, genParameterError("empty item processor list")
+		}
+		var innerProcessors []module.ProcessItem
+		for i, processor := range chain.Processors {
+			if processor == nil {
+				err := genParameterError(fmt.Sprintf("nil item processor[%d]", i))
+				return nil, err
+			}
+			innerProcessors = append(innerProcessors, processor)
+		}
+		chainMap[chain.Kind] = innerProcessors
+		kinds = append(kinds, chain.Kind)
+	}
+	return &myPipeline{
+		ModuleInternal: moduleBase,
+		chainMap:       chainMap,
+		kinds:          kinds,
+	}, nil
+}
+
+// myPipeline 代表条目处理管道的实现类型。
+type myPipeline struct {
+	// stub.ModuleInternal 代表组件基础实例。
+	stub.ModuleInternal
+	// chainMap 代表条目处理器链，键为条目的种类。
+	chainMap map[string][]module.ProcessItem
+	// kinds 代表条目的种类，顺序与创建时一致。
+	kinds []string
+	// failFast 代表处理是否需要快速失败。
+	failFast bool
+}
+
+// ItemProcessors 用于获取所有的条目处理器，按条目处理器链的顺序排列。
+func (pipeline *myPipeline) ItemProcessors() []module.ProcessItem {
+	var processors []module.ProcessItem
+	for _, kind := range pipeline.kinds {
+		processors = append(processors, pipeline.chainMap[kind]...)
+	}
+	return processors
+}
+
+func (pipeline *myPipeline) Send(item module.Item) []error {
+	pipeline.ModuleInternal.IncrHandlingNumber()
+	defer pipeline.ModuleInternal.DecrHandlingNumber()
+	pipeline.ModuleInternal.IncrCalledCount()
+	var errs []error
+	if item == nil {
+		err := genParameterError("nil item")
+		errs = append(errs, err)
+		return errs
+	}
+	pipeline.ModuleInternal.IncrAcceptedCount()
+	logger.Infof("Process item %+v... \n", item)
+	processors, ok := pipeline.chainMap[item.Kind()]
+	if !ok {
+		if processors, ok = pipeline.chainMap[""]; !ok {
+			errs = append(errs, genError(
+				fmt.Sprintf("no item processor for kind %q", item.Kind())))
+			return errs
+		}
+	}
+	var currentItem = item
+	for _, processor := range processors {
+		processedItem, err := processor(currentItem)
+		if err != nil {
+			errs = append(errs, err)
+			// 校验失败的条目不会再被后续的条目处理器处理。
+			if _, invalid := err.(*ValidationError); invalid || pipeline.failFast {
+				break
+			}
+		}
+		if processedItem != nil {
+			currentItem = processedItem
+		}
+	}
+	if len(errs) == 0 {
+		pipeline.ModuleInternal.IncrCompletedCount()
+	}
+	return errs
+}
+
+func (pipeline *myPipeline) FailFast() bool {
+	return pipeline.failFast
+}
+
+func (pipeline *myPipeline) SetFailFast(failFast bool) {
+	pipeline.failFast = failFast
+}
+
+// extraSummaryStruct 代表条目处理管道实额外信息的摘要类型。
+type extraSummaryStruct struct {
+	FailFast        bool     `json:"fail_fast"`
+	ProcessorNumber int      `json:"processor_number"`
+	Kinds           []string `json:"kinds,omitempty"`
+}
+
+func (pipeline *myPipeline) Summary() module.SummaryStruct {
+	summary := pipeline.ModuleInternal.Summary()
+	var kinds []string
+	for _, kind := range pipeline.kinds {
+		if kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	summary.Extra = extraSummaryStruct{
+		FailFast:        pipeline.failFast,
+		ProcessorNumber: len(pipeline.ItemProcessors()),
+		Kinds:           kinds,
+	}
+	return summary
+}
@@ -0,0 +1,306 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mycha/errors"
+	"mycha/module"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// 字段的类型。
+const (
+	// FIELD_TYPE_ANY 代表任意类型。
+	FIELD_TYPE_ANY = ""
+	// FIELD_TYPE_STRING 代表字符串。
+	FIELD_TYPE_STRING = "string"
+	// FIELD_TYPE_INT 代表整数，没有小数部分的浮点数也被视为整数。
+	FIELD_TYPE_INT = "int"
+	// FIELD_TYPE_FLOAT 代表数值，包括整数。
+	FIELD_TYPE_FLOAT = "float"
+	// FIELD_TYPE_BOOL 代表布尔值。
+	FIELD_TYPE_BOOL = "bool"
+	// FIELD_TYPE_TIME 代表时间，即time.Time类型的值。
+	FIELD_TYPE_TIME = "time"
+	// FIELD_TYPE_LIST 代表切片或数组。
+	FIELD_TYPE_LIST = "list"
+	// FIELD_TYPE_MAP 代表以字符串为键的映射。
+	FIELD_TYPE_MAP = "map"
+)
+
+// FieldRule 代表条目中一个字段的校验规则。
+type FieldRule struct {
+	// Name 代表字段名。
+	Name string
+	// Type 代表字段的类型，即FIELD_TYPE_开头的常量之一。
+	Type string
+	// Required 代表字段是否必须存在且不为nil。
+	Required bool
+	// Min 和 Max 代表数值的取值范围，为nil时不限制。
+	Min *float64
+	Max *float64
+	// MinLen 和 MaxLen 代表字符串的字符数或列表的长度的范围，为0时不限制。
+	MinLen int
+	MaxLen int
+	// Pattern 代表字符串需要匹配的正则表达式。
+	Pattern string
+	// Enum 代表字段允许的取值。
+	Enum []interface{}
+}
+
+// Schema 代表一种条目的校验规则。
+type Schema struct {
+	// Kind 代表条目的种类，不为空时条目的种类必须与之相同。
+	Kind string
+	// Fields 代表字段的校验规则。
+	Fields []FieldRule
+	// Strict 代表是否拒绝规则之外的字段，条目的种类不在此列。
+	Strict bool
+}
+
+// Violation 代表条目违反的一条校验规则。
+type Violation struct {
+	// Field 代表字段名。
+	Field string `json:"field"`
+	// Rule 代表违反的规则，比如"required"、"type"、"min"和"pattern"等。
+	Rule string `json:"rule"`
+	// Message 代表详细的信息。
+	Message string `json:"message"`
+}
+
+// ValidationError 代表条目校验失败的错误。
+// 条目处理管道遇到该错误时总会停止处理该条目。
+type ValidationError struct {
+	// Kind 代表条目的种类。
+	Kind string `json:"kind"`
+	// Violations 代表条目违反的所有校验规则。
+	Violations []Violation `json:"violations"`
+}
+
+// Type 用于获取错误的类型。
+func (err *ValidationError) Type() errors.ErrorType {
+	return errors.ERROR_TYPE_PIPELINE
+}
+
+func (err *ValidationError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("出现一个错误 类型：")
+	buf.WriteString(string(errors.ERROR_TYPE_PIPELINE))
+	buf.WriteString("invalid item")
+	if err.Kind != "" {
+		fmt.Fprintf(&buf, " (kind: %s)", err.Kind)
+	}
+	for i, v := range err.Violations {
+		if i == 0 {
+			buf.WriteString(": ")
+		} else {
+			buf.WriteString("; ")
+		}
+		fmt.Fprintf(&buf, "field %s: %s", v.Field, v.Message)
+	}
+	return buf.String()
+}
+
+// fieldRule 代表编译后的字段校验规则。
+type fieldRule struct {
+	FieldRule
+	pattern *regexp.Regexp
+}
+
+// NewValidator 用于根据校验规则创建一个校验条目的条目处理器。
+// 校验通过时条目原样返回，否则返回*ValidationError类型的错误。
+func NewValidator(schema Schema) (module.ProcessItem, error) {
+	var rules []*fieldRule
+	names := map[string]bool{}
+	for i, f := range schema.Fields {
+		if f.Name == "" {
+			return nil, genParameterError(fmt.Sprintf("empty name of field rule[%d]", i))
+		}
+		if names[f.Name] {
+			return nil, genParameterError(fmt.Sprintf("duplicate field rule: %s", f.Name))
+		}
+		names[f.Name] = true
+		switch f.Type {
+		case FIELD_TYPE_ANY, FIELD_TYPE_STRING, FIELD_TYPE_INT, FIELD_TYPE_FLOAT,
+			FIELD_TYPE_BOOL, FIELD_TYPE_TIME, FIELD_TYPE_LIST, FIELD_TYPE_MAP:
+		default:
+			return nil, genParameterError(
+				fmt.Sprintf("unsupported type %q of field %s", f.Type, f.Name))
+		}
+		rule := &fieldRule{FieldRule: f}
+		if f.Pattern != "" {
+			pattern, err := regexp.Compile(f.Pattern)
+			if err != nil {
+				return nil, genParameterError(
+					fmt.Sprintf("invalid pattern of field %s: %s", f.Name, err))
+			}
+			rule.pattern = pattern
+		}
+		rules = append(rules, rule)
+	}
+	return func(item module.Item) (module.Item, error) {
+		var violations []Violation
+		if schema.Kind != "" && item.Kind() != schema.Kind {
+			violations = append(violations, Violation{
+				Field:   module.ITEM_KIND_KEY,
+				Rule:    "kind",
+				Message: fmt.Sprintf("kind %q is not %q", item.Kind(), schema.Kind),
+			})
+		}
+		for _, rule := range rules {
+			violations = append(violations, rule.check(item)...)
+		}
+		if schema.Strict {
+			var unknown []string
+			for key := range item {
+				if key != module.ITEM_KIND_KEY && !names[key] {
+					unknown = append(unknown, key)
+				}
+			}
+			sort.Strings(unknown)
+			for _, key := range unknown {
+				violations = append(violations, Violation{
+					Field: key, Rule: "unknown", Message: "unknown field"})
+			}
+		}
+		if len(violations) > 0 {
+			return nil, &ValidationError{Kind: item.Kind(), Violations: violations}
+		}
+		return item, nil
+	}, nil
+}
+
+// check 用于校验条目中的字段。
+func (rule *fieldRule) check(item module.Item) []Violation {
+	value, ok := item[rule.Name]
+	if !ok || value == nil {
+		if rule.Required {
+			return []Violation{rule.violation("required", "missing required value")}
+		}
+		return nil
+	}
+	if !matchType(value, rule.Type) {
+		return []Violation{rule.violation("type",
+			fmt.Sprintf("%T is not %s", value, rule.Type))}
+	}
+	var violations []Violation
+	if number, ok := toFloat(value); ok {
+		if rule.Min != nil && number < *rule.Min {
+			violations = append(violations, rule.violation("min",
+				fmt.Sprintf("%v is less than %v", value, *rule.Min)))
+		}
+		if rule.Max != nil && number > *rule.Max {
+			violations = append(violations, rule.violation("max",
+				fmt.Sprintf("%v is greater than %v", value, *rule.Max)))
+		}
+	}
+	if length, ok := lengthOf(value); ok {
+		if rule.MinLen > 0 && length < rule.MinLen {
+			violations = append(violations, rule.violation("min_len",
+				fmt.Sprintf("length %d is less than %d", length, rule.MinLen)))
+		}
+		if rule.MaxLen > 0 && length > rule.MaxLen {
+			violations = append(violations, rule.violation("max_len",
+				fmt.Sprintf("length %d is greater than %d", length, rule.MaxLen)))
+		}
+	}
+	if s, ok := value.(string); ok && rule.pattern != nil && !rule.pattern.MatchString(s) {
+		violations = append(violations, rule.violation("pattern",
+			fmt.Sprintf("%q does not match %q", s, rule.Pattern)))
+	}
+	if len(rule.Enum) > 0 && !inEnum(value, rule.Enum) {
+		violations = append(violations, rule.violation("enum",
+			fmt.Sprintf("%v is not one of %v", value, rule.Enum)))
+	}
+	return violations
+}
+
+func (rule *fieldRule) violation(name string, message string) Violation {
+	return Violation{Field: rule.Name, Rule: name, Message: message}
+}
+
+// matchType 用于判断值是否为指定的类型。
+func matchType(value interface{}, t string) bool {
+	switch t {
+	case FIELD_TYPE_ANY:
+		return true
+	case FIELD_TYPE_STRING:
+		_, ok := value.(string)
+		return ok
+	case FIELD_TYPE_INT:
+		number, ok := toFloat(value)
+		return ok && number == float64(int64(number))
+	case FIELD_TYPE_FLOAT:
+		_, ok := toFloat(value)
+		return ok
+	case FIELD_TYPE_BOOL:
+		_, ok := value.(bool)
+		return ok
+	case FIELD_TYPE_TIME:
+		_, ok := value.(time.Time)
+		return ok
+	case FIELD_TYPE_LIST:
+		kind := reflect.TypeOf(value).Kind()
+		return kind == reflect.Slice || kind == reflect.Array
+	case FIELD_TYPE_MAP:
+		t := reflect.TypeOf(value)
+		return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+	}
+	return false
+}
+
+// toFloat 用于把数值转换为float64，值不是数值时返回false。
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case bool, string:
+		return 0, false
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// lengthOf 用于获取字符串的字符数或列表的长度。
+func lengthOf(value interface{}) (int, bool) {
+	if s, ok := value.(string); ok {
+		return utf8.RuneCountInString(s), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Len(), true
+	}
+	return 0, false
+}
+
+// inEnum 用于判断值是否在允许的取值之中，数值按大小比较。
+func inEnum(value interface{}, enum []interface{}) bool {
+	number, isNumber := toFloat(value)
+	for _, allowed := range enum {
+		if isNumber {
+			if n, ok := toFloat(allowed); ok && n == number {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
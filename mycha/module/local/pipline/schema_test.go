@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"mycha/module"
+	"strings"
+	"testing"
+	"time"
+)
+
+func float(v float64) *float64 { return &v }
+
+// violatedRules 用于获取错误中违反的规则，不是校验错误时返回nil。
+func violatedRules(err error) []string {
+	ve, ok := err.(*ValidationError)
+	if !ok {
+		return nil
+	}
+	var rules []string
+	for _, v := range ve.Violations {
+		rules = append(rules, v.Field+"."+v.Rule)
+	}
+	return rules
+}
+
+func TestValidator(t *testing.T) {
+	validate, err := NewValidator(Schema{Kind: "book", Strict: true, Fields: []FieldRule{
+		{Name: "title", Type: FIELD_TYPE_STRING, Required: true, MinLen: 2, MaxLen: 5},
+		{Name: "price", Type: FIELD_TYPE_FLOAT, Min: float(0), Max: float(100)},
+		{Name: "id", Type: FIELD_TYPE_INT},
+		{Name: "lang", Enum: []interface{}{"zh", "en"}},
+		{Name: "isbn", Pattern: `^\d+$`},
+		{Name: "tags", Type: FIELD_TYPE_LIST, MaxLen: 2},
+		{Name: "published", Type: FIELD_TYPE_TIME},
+		{Name: "rating", Enum: []interface{}{1, 2, 3}},
+	}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a validator: %s", err)
+	}
+	cases := []struct {
+		item  module.Item
+		rules string
+	}{
+		{module.Item{"title": "Go", "price": 3, "id": float64(7), "lang": "zh", "isbn": "123",
+			"tags": []string{"a"}, "published": time.Now(), "rating": json.Number("2")}, ""},
+		{module.Item{"title": "语言"}, ""},
+		{module.Item{}, "title.required"},
+		{module.Item{"title": nil}, "title.required"},
+		{module.Item{"title": 1}, "title.type"},
+		{module.Item{"title": "G"}, "title.min_len"},
+		{module.Item{"title": "Golang"}, "title.max_len"},
+		{module.Item{"title": "Go", "price": -1}, "price.min"},
+		{module.Item{"title": "Go", "price": uint8(101)}, "price.max"},
+		{module.Item{"title": "Go", "price": "1"}, "price.type"},
+		{module.Item{"title": "Go", "id": 1.5}, "id.type"},
+		{module.Item{"title": "Go", "lang": "fr"}, "lang.enum"},
+		{module.Item{"title": "Go", "rating": 4.0}, "rating.enum"},
+		{module.Item{"title": "Go", "isbn": "x1"}, "isbn.pattern"},
+		{module.Item{"title": "Go", "tags": []int{1, 2, 3}}, "tags.max_len"},
+		{module.Item{"title": "Go", "published": "2016-01-02"}, "published.type"},
+		{module.Item{"title": "Go", "extra": 1, "another": 2}, "another.unknown,extra.unknown"},
+		{module.Item{"title": "G", "price": -1, "id": 1.5},
+			"title.min_len,price.min,id.type"},
+	}
+	for i, c := range cases {
+		c.item.SetKind("book")
+		result, err := validate(c.item)
+		if rules := strings.Join(violatedRules(err), ","); rules != c.rules {
+			t.Fatalf("Inconsistent violations[%d]: expected: %q, actual: %q (%v)", i, c.rules, rules, err)
+		}
+		if err == nil && result["title"] != c.item["title"] {
+			t.Fatalf("The valid item[%d] is not returned as is: %v", i, result)
+		}
+	}
+	_, err = validate(module.Item{"title": "Go"})
+	if rules := strings.Join(violatedRules(err), ","); rules != module.ITEM_KIND_KEY+".kind" {
+		t.Fatalf("Inconsistent violations of an item without kind: %q", rules)
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	for i, fields := range [][]FieldRule{
+		{{Type: FIELD_TYPE_STRING}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Type: "date"}},
+		{{Name: "a", Pattern: `(`}},
+	} {
+		if _, err := NewValidator(Schema{Fields: fields}); err == nil {
+			t.Fatalf("No error when creating a validator with fields[%d]: %+v", i, fields)
+		}
+	}
+}
+
+func TestPipelineChains(t *testing.T) {
+	validate, err := NewValidator(Schema{Kind: "book", Fields: []FieldRule{
+		{Name: "title", Type: FIELD_TYPE_STRING, Required: true},
+	}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a validator: %s", err)
+	}
+	var hits []string
+	record := func(name string) module.ProcessItem {
+		return func(item module.Item) (module.Item, error) {
+			hits = append(hits, name)
+			return nil, nil
+		}
+	}
+	pipeline, err := NewWithChains("P1", []Chain{
+		{Kind: "book", Processors: []module.ProcessItem{validate, record("book")}},
+		{Processors: []module.ProcessItem{record("other")}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	book := module.Item{"title": "Go"}
+	book.SetKind("book")
+	invalid := module.Item{"title": 1}
+	invalid.SetKind("book")
+	movie := module.Item{"title": "Up"}
+	movie.SetKind("movie")
+	cases := []struct {
+		item module.Item
+		hits string
+		errs int
+	}{
+		{book, "book", 0},
+		// 校验失败的条目不会再被后续的条目处理器处理。
+		{invalid, "", 1},
+		// 没有对应的链的条目由种类为空的链处理。
+		{movie, "other", 0},
+		{module.Item{"x": 1}, "other", 0},
+	}
+	for i, c := range cases {
+		hits = nil
+		errs := pipeline.Send(c.item)
+		if len(errs) != c.errs || strings.Join(hits, ",") != c.hits {
+			t.Fatalf("Inconsistent result[%d]: expected: (%q, %d), actual: (%q, %d)",
+				i, c.hits, c.errs, strings.Join(hits, ","), len(errs))
+		}
+	}
+
+	pipeline, err = NewWithChains("P1", []Chain{
+		{Kind: "book", Processors: []module.ProcessItem{record("book")}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	if errs := pipeline.Send(movie); len(errs) != 1 {
+		t.Fatalf("Inconsistent number of errors for an item without chain: expected: 1, actual: %d",
+			len(errs))
+	}
+	for i, chains := range [][]Chain{
+		{},
+		{{Kind: "a", Processors: []module.ProcessItem{record("")}},
+			{Kind: "a", Processors: []module.ProcessItem{record("")}}},
+		{{Kind: "a", Processors: []module.ProcessItem{}}},
+		{{Kind: "a", Processors: []module.ProcessItem{nil}}},
+	} {
+		if _, err := NewWithChains("P1", chains, nil); err == nil {
+			t.Fatalf("No error when creating a pipeline with chains[%d]", i)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package module
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// 跳转的种类。
+const (
+	// REDIRECT_HTTP 代表HTTP状态码为3xx的跳转。
+	REDIRECT_HTTP = "http"
+	// REDIRECT_META_REFRESH 代表<meta http-equiv="refresh">引起的跳转。
+	REDIRECT_META_REFRESH = "meta-refresh"
+	// REDIRECT_JAVASCRIPT 代表JavaScript中给location赋值等引起的跳转。
+	REDIRECT_JAVASCRIPT = "javascript"
+	// REDIRECT_NOSCRIPT 代表<noscript>中的跳转或唯一的链接。
+	REDIRECT_NOSCRIPT = "noscript"
+)
+
+// Redirect 代表一次跳转。
+type Redirect struct {
+	// Kind 代表跳转的种类，即REDIRECT_开头的常量之一。
+	Kind string `json:"kind"`
+	// From 代表跳转前的链接。
+	From string `json:"from"`
+	// To 代表跳转后的链接。
+	To string `json:"to"`
+	// StatusCode 代表HTTP跳转的状态码，其他种类的跳转为0。
+	StatusCode int `json:"status_code,omitempty"`
+}
+
+// RedirectPolicy 代表下载过程中检查跳转的函数。
+// from和to分别为跳转前后的链接，hops为包括这次在内已经经过的跳转次数。
+// 返回的错误不为nil时，不再跟随这次跳转，下载器会返回*RedirectError。
+type RedirectPolicy func(from, to *url.URL, hops int) error
+
+// RedirectError 代表跳转被拒绝时下载器返回的错误。
+type RedirectError struct {
+	// Redirect 代表被拒绝的跳转。
+	Redirect Redirect
+	// Err 代表拒绝的原因。
+	Err error
+}
+
+func (err *RedirectError) Error() string {
+	return fmt.Sprintf("redirect rejected (%s: %s -> %s): %s",
+		err.Redirect.Kind, err.Redirect.From, err.Redirect.To, err.Err)
+}
+
+// RedirectPolicy 用于获取下载过程中检查跳转的函数，为nil时由下载器决定。
+func (req *Request) RedirectPolicy() RedirectPolicy {
+	return req.redirectPolicy
+}
+
+// SetRedirectPolicy 用于设置下载过程中检查跳转的函数。
+// 它不会被发往远程组件，远程下载器跟随的跳转由调度器在下载之后按它检查。
+func (req *Request) SetRedirectPolicy(policy RedirectPolicy) {
+	req.redirectPolicy = policy
+}
+
+// Redirected 用于生成跳转到另一个HTTP请求的新请求。
+// 新请求的深度、优先级、元数据、条目、父请求和跳转检查函数都与原请求相同。
+func (req *Request) Redirected(httpReq *http.Request) *Request {
+	redirected := *req
+	redirected.httpReq = httpReq
+	return &redirected
+}
+
+// Redirects 用于获取得到该响应之前经过的跳转，按发生的顺序排列。
+func (resp *Response) Redirects() []Redirect {
+	return resp.redirects
+}
+
+// SetRedirects 用于设置得到该响应之前经过的跳转。
+func (resp *Response) SetRedirects(redirects []Redirect) {
+	resp.redirects = redirects
+}
+
+// FinalURL 用于获取经过所有跳转后的最终链接。
+func (resp *Response) FinalURL() string {
+	return resp.url()
+}
@@ -2,9 +2,10 @@ package module
 
 import (
 	"mycha/errors"
+	"sort"
 	"sync"
-
-
+	"sync/atomic"
+	"time"
 )
 
 //注册器的接口
@@ -12,7 +13,7 @@ type Registrar interface {
 	 Register(module Module) (bool,error) //用于注册组件实例
 	 Unregister(mid MID) (bool,error)  //用于注销实例
 
-	 //用于获取到某个实例 这里用了负载均衡的方式？ 不是很懂
+	 //用于获取某个类型的一个组件实例 由该类型的选择策略决定选哪一个
 	 Get(moduleType Type)  (Module,error)
 	 //获取到全部的某种类型实例
 	 GetAllByType(moduleType Type)(map[MID]Module,error)
@@ -20,12 +21,21 @@ type Registrar interface {
 	 GetAll() map[MID]Module
 	 //清除
 	 Clear()
+	 //设置某种类型组件的选择策略 为nil时使用默认的策略
+	 SetSelector(moduleType Type, selector Selector) error
+	 //记录某个组件一次调用的耗时 供需要耗时的选择策略使用
+	 ObserveLatency(mid MID, latency time.Duration)
+	 //获取某个组件被Get选中的次数
+	 SelectedCount(mid MID) uint64
 }
 
 // NewRegistrar 用于创建一个组件注册器的实例。
 func NewRegistrar() Registrar {
 	return &myRegistrar{
 		moduleTypeMap: map[Type]map[MID]Module{},
+		sortedMap:     map[Type][]Module{},
+		selectorMap:   map[Type]Selector{},
+		selectedMap:   map[MID]*uint64{},
 	}
 }
 
@@ -33,6 +43,12 @@ func NewRegistrar() Registrar {
 type myRegistrar struct {
 	//代表组件类型与对应的类型
 	moduleTypeMap map[Type]map[MID]Module
+	//代表组件类型与按MID排序的组件列表 组件变化时重新生成 供选择策略使用
+	sortedMap map[Type][]Module
+	//代表组件类型与对应的选择策略
+	selectorMap map[Type]Selector
+	//代表各组件被选中的次数
+	selectedMap map[MID]*uint64
 	rwlock sync.RWMutex  //读写锁
 }
 
@@ -62,6 +78,10 @@ func (registrar *myRegistrar) Register(module Module) (bool,error) {
 	}
 	modules[mid] = module
 	registrar.moduleTypeMap[moduleType] = modules
+	registrar.sortModules(moduleType)
+	if _, ok := registrar.selectedMap[mid]; !ok {
+		registrar.selectedMap[mid] = new(uint64)
+	}
 	return true, nil
 }
 
@@ -74,36 +94,64 @@ func (registrar *myRegistrar) Unregister(mid MID) (bool,error) {
 	moduleType := legalLetterTypeMap[parts[0]]
 	var deleted bool
 	registrar.rwlock.Lock()
-	defer registrar.rwlock.Unlock()
 	if modules,ok:= registrar.moduleTypeMap[moduleType];ok {
 		if _,ok := modules[mid]; ok {
 			delete(modules,mid)
+			registrar.sortModules(moduleType)
 			deleted = true
 		}
 	}
-
+	selector := registrar.selectorMap[moduleType]
+	registrar.rwlock.Unlock()
+	//组件重新注册时不应沿用之前的耗时记录
+	if observer, ok := selector.(LatencyObserver); ok && deleted {
+		observer.Forget(mid)
+	}
 	return deleted,nil
 }
 
-//在某个类型的组件数组中 随机的给予一个空闲的组件
+//重新生成某个类型的按MID排序的组件列表 调用方需持有写锁
+//列表生成后不再修改 所以Get可以在释放锁之后使用它
+func (registrar *myRegistrar) sortModules(moduleType Type) {
+	modules := registrar.moduleTypeMap[moduleType]
+	if len(modules) == 0 {
+		delete(registrar.sortedMap, moduleType)
+		return
+	}
+	list := make([]Module, 0, len(modules))
+	for _, module := range modules {
+		list = append(list, module)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID() < list[j].ID()
+	})
+	registrar.sortedMap[moduleType] = list
+}
+
+//在某个类型的组件中 按该类型的选择策略选出一个组件
 func (registrar *myRegistrar) Get(moduleType Type) (Module,error) {
-	modules, err := registrar.GetAllByType(moduleType)
-	if err != nil {
-		return nil,err
-	}
-	minScore := uint32(0)
-	var selectedModule Module
-	for _,module := range modules {
-		SetScore(module)
-		if err != nil {
-			return nil,err
-		}
-		score:= module.Score()
-		if minScore == 0 || score<minScore {
-			selectedModule = module
-			minScore = score
-		}
+	if !LegalType(moduleType) {
+		return nil,errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER,"不存在的类型")
+	}
+	registrar.rwlock.RLock()
+	list := registrar.sortedMap[moduleType]
+	selector := registrar.selectorMap[moduleType]
+	registrar.rwlock.RUnlock()
+	if len(list) == 0 {
+		return nil, ErrNotFoundModulInstance
+	}
+	if selector == nil {
+		selector = &scoreSelector{}
+	}
+	selectedModule := selector.Select(list)
+	if selectedModule == nil {
+		return nil, ErrNotFoundModulInstance
+	}
+	registrar.rwlock.RLock()
+	if counter, ok := registrar.selectedMap[selectedModule.ID()]; ok {
+		atomic.AddUint64(counter, 1)
 	}
+	registrar.rwlock.RUnlock()
 	return selectedModule,nil
 }
 
@@ -144,8 +192,57 @@ func (registrar *myRegistrar) GetAll() map[MID]Module {
 
 func(registrar *myRegistrar) Clear() {
 	registrar.rwlock.Lock()
-	defer registrar.rwlock.Unlock()
+	moduleTypeMap := registrar.moduleTypeMap
+	selectorMap := registrar.selectorMap
 	registrar.moduleTypeMap = map[Type]map[MID]Module{}
+	registrar.sortedMap = map[Type][]Module{}
+	registrar.selectedMap = map[MID]*uint64{}
+	registrar.rwlock.Unlock()
+	for moduleType, modules := range moduleTypeMap {
+		observer, ok := selectorMap[moduleType].(LatencyObserver)
+		if !ok {
+			continue
+		}
+		for mid := range modules {
+			observer.Forget(mid)
+		}
+	}
+}
+
+func (registrar *myRegistrar) SetSelector(moduleType Type, selector Selector) error {
+	if !LegalType(moduleType) {
+		return errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER,"不存在的类型")
+	}
+	registrar.rwlock.Lock()
+	defer registrar.rwlock.Unlock()
+	if selector == nil {
+		delete(registrar.selectorMap, moduleType)
+		return nil
+	}
+	registrar.selectorMap[moduleType] = selector
+	return nil
+}
+
+func (registrar *myRegistrar) ObserveLatency(mid MID, latency time.Duration) {
+	parts, err := SplitMID(mid)
+	if err != nil {
+		return
+	}
+	registrar.rwlock.RLock()
+	selector := registrar.selectorMap[legalLetterTypeMap[parts[0]]]
+	registrar.rwlock.RUnlock()
+	if observer, ok := selector.(LatencyObserver); ok {
+		observer.ObserveLatency(mid, latency)
+	}
+}
+
+func (registrar *myRegistrar) SelectedCount(mid MID) uint64 {
+	registrar.rwlock.RLock()
+	defer registrar.rwlock.RUnlock()
+	if counter, ok := registrar.selectedMap[mid]; ok {
+		return atomic.LoadUint64(counter)
+	}
+	return 0
 }
 
 
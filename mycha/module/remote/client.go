@@ -0,0 +1,251 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mycha/errors"
+	"mycha/module"
+	"mycha/module/stub"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// client 代表远程组件的客户端，负责调用工作节点上的同名组件。
+// 组件的地址来自MID中的网络地址部分。
+// 没有指定HTTP客户端时使用超时时间为DEFAULT_TIMEOUT的客户端。
+type client struct {
+	httpClient *http.Client
+	mid        module.MID
+	baseURL    string
+	errType    errors.ErrorType
+}
+
+func newClient(moduleBase stub.ModuleInternal, httpClient *http.Client,
+	errType errors.ErrorType) (*client, error) {
+	if moduleBase.Addr() == "" {
+		return nil, genParameterError(
+			fmt.Sprintf("no network address in MID %q", moduleBase.ID()))
+	}
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+	return &client{
+		httpClient: httpClient,
+		mid:        moduleBase.ID(),
+		baseURL:    "http://" + moduleBase.Addr(),
+		errType:    errType,
+	}, nil
+}
+
+// call 用于调用工作节点上的组件。
+func (c *client) call(path string, args interface{}, result interface{}) error {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return errors.NewCrawlerErrorByErr(c.errType, err)
+	}
+	u := c.baseURL + path + "?mid=" + url.QueryEscape(string(c.mid))
+	httpResp, err := c.httpClient.Post(u, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.NewCrawlerErrorByErr(c.errType, err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		var errMsg ErrorMessage
+		body, _ := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxMessageSize))
+		if json.Unmarshal(body, &errMsg) != nil || errMsg.Message == "" {
+			errMsg.Message = string(body)
+		}
+		return errors.NewCrawlerError(c.errType, fmt.Sprintf(
+			"remote module %q: %s: %s", c.mid, httpResp.Status, errMsg.Message))
+	}
+	if err = json.NewDecoder(io.LimitReader(httpResp.Body, maxMessageSize)).Decode(result); err != nil {
+		return errors.NewCrawlerError(c.errType, fmt.Sprintf(
+			"remote module %q: invalid result: %s", c.mid, err))
+	}
+	return nil
+}
+
+// NewDownloader 用于创建一个调用远程下载器的下载器。
+// 参数mid需要是工作节点上的下载器的MID，并且带有网络地址。
+func NewDownloader(mid module.MID, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Downloader, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(moduleBase, httpClient, errors.ERROR_TYPE_DOWNLOADER)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteDownloader{ModuleInternal: moduleBase, client: c}, nil
+}
+
+// remoteDownloader 代表远程下载器的代理类型。
+type remoteDownloader struct {
+	stub.ModuleInternal
+	client *client
+}
+
+func (downloader *remoteDownloader) Download(req *module.Request) (*module.Response, error) {
+	downloader.ModuleInternal.IncrHandlingNumber()
+	defer downloader.ModuleInternal.DecrHandlingNumber()
+	downloader.ModuleInternal.IncrCalledCount()
+	if req == nil || !req.Valid() {
+		return nil, genParameterError("nil request")
+	}
+	downloader.ModuleInternal.IncrAcceptedCount()
+	msg, err := encodeRequest(req)
+	if err != nil {
+		return nil, errors.NewCrawlerErrorByErr(errors.ERROR_TYPE_DOWNLOADER, err)
+	}
+	var result DownloadResult
+	if err = downloader.client.call(PATH_DOWNLOAD, msg, &result); err != nil {
+		return nil, err
+	}
+	var resp *module.Response
+	if result.Response != nil {
+		if resp, err = result.Response.decode(); err != nil {
+			return nil, errors.NewCrawlerErrorByErr(errors.ERROR_TYPE_DOWNLOADER, err)
+		}
+		// 让响应关联到本地的请求，以保留其父请求。
+		redirects := resp.Redirects()
+		resp = module.NewResponseFor(resp.HTTPResp(), req)
+		resp.SetRedirects(redirects)
+	}
+	if result.Error != nil {
+		return resp, result.Error.decode(errors.ERROR_TYPE_DOWNLOADER)
+	}
+	downloader.ModuleInternal.IncrCompletedCount()
+	return resp, nil
+}
+
+// NewAnalyzer 用于创建一个调用远程分析器的分析器。
+// 参数mid需要是工作节点上的分析器的MID，并且带有网络地址。
+func NewAnalyzer(mid module.MID, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(moduleBase, httpClient, errors.ERROR_TYPE_ANALYZER)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteAnalyzer{ModuleInternal: moduleBase, client: c}, nil
+}
+
+// remoteAnalyzer 代表远程分析器的代理类型。
+type remoteAnalyzer struct {
+	stub.ModuleInternal
+	client *client
+}
+
+// RespParsers 总是返回nil，响应解析函数只存在于工作节点上。
+func (analyzer *remoteAnalyzer) RespParsers() []module.ParseResponse {
+	return nil
+}
+
+func (analyzer *remoteAnalyzer) Analyze(resp *module.Response) (dataList []module.Data, errs []error) {
+	analyzer.ModuleInternal.IncrHandlingNumber()
+	defer analyzer.ModuleInternal.DecrHandlingNumber()
+	analyzer.ModuleInternal.IncrCalledCount()
+	if resp == nil || !resp.Valid() {
+		errs = append(errs, genParameterError("nil response"))
+		return
+	}
+	analyzer.ModuleInternal.IncrAcceptedCount()
+	msg, err := encodeResponse(resp)
+	if err != nil {
+		errs = append(errs, errors.NewCrawlerErrorByErr(errors.ERROR_TYPE_ANALYZER, err))
+		return
+	}
+	var result AnalyzeResult
+	if err = analyzer.client.call(PATH_ANALYZE, msg, &result); err != nil {
+		errs = append(errs, err)
+		return
+	}
+	for _, data := range result.Data {
+		switch {
+		case data.Request != nil:
+			req, err := data.Request.decode()
+			if err != nil {
+				errs = append(errs, errors.NewCrawlerErrorByErr(errors.ERROR_TYPE_ANALYZER, err))
+				continue
+			}
+			// 父请求不会被传输，需要在本地重新挂到响应之下。
+			dataList = append(dataList, resp.Adopt(req))
+		case data.Item != nil:
+			dataList = append(dataList, data.Item)
+		}
+	}
+	errs = append(errs, decodeErrors(result.Errors, errors.ERROR_TYPE_ANALYZER)...)
+	if len(errs) == 0 {
+		analyzer.ModuleInternal.IncrCompletedCount()
+	}
+	return
+}
+
+// NewPipeline 用于创建一个调用远程条目处理管道的条目处理管道。
+// 参数mid需要是工作节点上的条目处理管道的MID，并且带有网络地址。
+// 条目会以JSON格式传输，其中的数值在工作节点上都会成为float64类型。
+func NewPipeline(mid module.MID, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(moduleBase, httpClient, errors.ERROR_TYPE_PIPELINE)
+	if err != nil {
+		return nil, err
+	}
+	return &remotePipeline{ModuleInternal: moduleBase, client: c}, nil
+}
+
+// remotePipeline 代表远程条目处理管道的代理类型。
+type remotePipeline struct {
+	stub.ModuleInternal
+	client   *client
+	lock     sync.RWMutex
+	failFast bool
+}
+
+// ItemProcessors 总是返回nil，条目处理函数只存在于工作节点上。
+func (pipeline *remotePipeline) ItemProcessors() []module.ProcessItem {
+	return nil
+}
+
+func (pipeline *remotePipeline) Send(item module.Item) []error {
+	pipeline.ModuleInternal.IncrHandlingNumber()
+	defer pipeline.ModuleInternal.DecrHandlingNumber()
+	pipeline.ModuleInternal.IncrCalledCount()
+	if item == nil {
+		return []error{genParameterError("nil item")}
+	}
+	pipeline.ModuleInternal.IncrAcceptedCount()
+	args := SendArgs{Item: item, FailFast: pipeline.FailFast()}
+	var result SendResult
+	if err := pipeline.client.call(PATH_SEND, args, &result); err != nil {
+		return []error{err}
+	}
+	errs := decodeErrors(result.Errors, errors.ERROR_TYPE_PIPELINE)
+	if len(errs) == 0 {
+		pipeline.ModuleInternal.IncrCompletedCount()
+	}
+	return errs
+}
+
+func (pipeline *remotePipeline) FailFast() bool {
+	pipeline.lock.RLock()
+	defer pipeline.lock.RUnlock()
+	return pipeline.failFast
+}
+
+func (pipeline *remotePipeline) SetFailFast(failFast bool) {
+	pipeline.lock.Lock()
+	pipeline.failFast = failFast
+	pipeline.lock.Unlock()
+}
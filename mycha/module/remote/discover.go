@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"mycha/module"
+	"net/http"
+)
+
+// Modules 代表从工作节点发现的组件代理。
+type Modules struct {
+	Downloaders []module.Downloader
+	Analyzers   []module.Analyzer
+	Pipelines   []module.Pipeline
+}
+
+// Discover 用于获取工作节点上托管的组件，并为每个组件创建代理。
+// 参数addr代表工作节点的地址，如 10.0.0.5:8080。
+// 参数httpClient为nil时使用超时时间为DEFAULT_TIMEOUT的客户端，创建的组件代理也会使用它。
+func Discover(addr string, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (*Modules, error) {
+	if addr == "" {
+		return nil, genParameterError("empty worker address")
+	}
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+	httpResp, err := httpClient.Get("http://" + addr + PATH_MODULES)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker %s: %s", addr, httpResp.Status)
+	}
+	var infos []ModuleInfo
+	if err = json.NewDecoder(httpResp.Body).Decode(&infos); err != nil {
+		return nil, fmt.Errorf("worker %s: invalid module list: %s", addr, err)
+	}
+	modules := &Modules{}
+	for _, info := range infos {
+		mid := info.Summary.ID
+		switch info.Type {
+		case module.TYPE_DOWNLOADER:
+			d, err := NewDownloader(mid, httpClient, scoreCalculator)
+			if err != nil {
+				return nil, err
+			}
+			modules.Downloaders = append(modules.Downloaders, d)
+		case module.TYPE_ANALYZER:
+			a, err := NewAnalyzer(mid, httpClient, scoreCalculator)
+			if err != nil {
+				return nil, err
+			}
+			modules.Analyzers = append(modules.Analyzers, a)
+		case module.TYPE_PIPELINE:
+			p, err := NewPipeline(mid, httpClient, scoreCalculator)
+			if err != nil {
+				return nil, err
+			}
+			modules.Pipelines = append(modules.Pipelines, p)
+		default:
+			logger.Warnf("忽略工作节点 %s 上未知类型的组件 %q (类型: %q)", addr, mid, info.Type)
+		}
+	}
+	return modules, nil
+}
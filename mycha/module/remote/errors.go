@@ -0,0 +1,8 @@
+package remote
+
+import "mycha/errors"
+
+// genParameterError 用于生成爬虫参数错误值。
+func genParameterError(errMsg string) error {
+	return errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER, errMsg)
+}
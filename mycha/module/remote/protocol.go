@@ -0,0 +1,276 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mycha/errors"
+	"mycha/module"
+	"mycha/tool/reader"
+	"net/http"
+	"time"
+)
+
+// DEFAULT_TIMEOUT 代表未指定HTTP客户端时，调用工作节点的超时时间。
+// 它需要大于工作节点上的组件处理一次调用的时间，比如下载器的超时时间。
+const DEFAULT_TIMEOUT = 2 * time.Minute
+
+// defaultHTTPClient 代表未指定HTTP客户端时使用的客户端。
+var defaultHTTPClient = &http.Client{Timeout: DEFAULT_TIMEOUT}
+
+// maxBodySize 代表传输的响应体的最大字节数，与分析器默认的响应体上限相同。
+const maxBodySize = reader.DEFAULT_MAX_BODY_SIZE
+
+// maxMessageSize 代表传输的消息的最大字节数。
+// 响应体在JSON中以base64编码，所以消息的上限要比响应体的上限大一些。
+const maxMessageSize = maxBodySize/3*4 + 1<<20
+
+// 远程组件协议的路径。
+// 除了获取组件列表以外，都需要用查询参数mid指定组件。
+const (
+	PATH_MODULES  = "/modules"
+	PATH_DOWNLOAD = "/download"
+	PATH_ANALYZE  = "/analyze"
+	PATH_SEND     = "/send"
+)
+
+// ModuleInfo 代表工作节点上的一个组件的信息。
+type ModuleInfo struct {
+	Type    module.Type          `json:"type"`
+	Summary module.SummaryStruct `json:"summary"`
+}
+
+// RequestMessage 代表在网络上传输的请求。
+// 请求的父请求不会被传输。
+type RequestMessage struct {
+	URL      string                 `json:"url"`
+	Method   string                 `json:"method"`
+	Header   http.Header            `json:"header,omitempty"`
+	Body     []byte                 `json:"body,omitempty"`
+	Depth    uint32                 `json:"depth"`
+	Priority int                    `json:"priority,omitempty"`
+	Callback string                 `json:"callback,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Item     module.Item            `json:"item,omitempty"`
+}
+
+// ResponseMessage 代表在网络上传输的响应。
+// 响应体会被完整读出后一起传输。
+type ResponseMessage struct {
+	Request    RequestMessage    `json:"request"`
+	StatusCode int               `json:"status_code"`
+	Header     http.Header       `json:"header,omitempty"`
+	Body       []byte            `json:"body,omitempty"`
+	Depth      uint32            `json:"depth"`
+	Redirects  []module.Redirect `json:"redirects,omitempty"`
+}
+
+// ErrorMessage 代表在网络上传输的错误。
+type ErrorMessage struct {
+	Type    errors.ErrorType `json:"type,omitempty"`
+	Message string           `json:"message"`
+}
+
+// DataMessage 代表分析器输出的一个数据，请求和条目有且只有一个不为空。
+type DataMessage struct {
+	Request *RequestMessage `json:"request,omitempty"`
+	Item    module.Item     `json:"item,omitempty"`
+}
+
+// DownloadResult 代表下载的结果。
+type DownloadResult struct {
+	Response *ResponseMessage `json:"response,omitempty"`
+	Error    *ErrorMessage    `json:"error,omitempty"`
+}
+
+// AnalyzeResult 代表分析的结果。
+type AnalyzeResult struct {
+	Data   []DataMessage  `json:"data,omitempty"`
+	Errors []ErrorMessage `json:"errors,omitempty"`
+}
+
+// SendArgs 代表发送条目的参数。
+type SendArgs struct {
+	Item     module.Item `json:"item"`
+	FailFast bool        `json:"fail_fast"`
+}
+
+// SendResult 代表发送条目的结果。
+type SendResult struct {
+	Errors []ErrorMessage `json:"errors,omitempty"`
+}
+
+// encodeRequest 用于把请求转换为可传输的形式。
+// 请求体会通过GetBody重新获取，不会消耗原请求的请求体。
+func encodeRequest(req *module.Request) (*RequestMessage, error) {
+	httpReq := req.HTTPReq()
+	msg := &RequestMessage{
+		URL:      httpReq.URL.String(),
+		Method:   httpReq.Method,
+		Header:   httpReq.Header,
+		Depth:    req.Depth(),
+		Priority: req.Priority(),
+		Callback: req.Callback(),
+		Meta:     req.Meta(),
+		Item:     req.Item(),
+	}
+	if httpReq.GetBody != nil {
+		body, err := httpReq.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		if msg.Body, err = ioutil.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// decode 用于把传输的请求还原为请求。
+func (msg *RequestMessage) decode() (*module.Request, error) {
+	method := msg.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	httpReq, err := http.NewRequest(method, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Body) == 0 {
+		httpReq.Body = http.NoBody
+		httpReq.GetBody = nil
+	}
+	for key, values := range msg.Header {
+		httpReq.Header[key] = append([]string(nil), values...)
+	}
+	req := module.NewRequest(httpReq, msg.Depth)
+	req.SetPriority(msg.Priority)
+	req.SetCallback(msg.Callback)
+	req.SetItem(msg.Item)
+	for key, value := range msg.Meta {
+		req.SetMeta(key, value)
+	}
+	return req, nil
+}
+
+// encodeResponse 用于把响应转换为可传输的形式，会读取并关闭响应体。
+// 响应体超过maxBodySize时返回错误。
+// 其中请求的链接取自最终的HTTP请求，元数据取自产生该响应的请求。
+func encodeResponse(resp *module.Response) (*ResponseMessage, error) {
+	httpResp := resp.HTTPResp()
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("response body exceeds %d bytes", maxBodySize)
+	}
+	msg := &ResponseMessage{
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Body:       body,
+		Depth:      resp.Depth(),
+		Redirects:  resp.Redirects(),
+	}
+	if httpResp.Request != nil {
+		msg.Request = RequestMessage{
+			URL:    httpResp.Request.URL.String(),
+			Method: httpResp.Request.Method,
+			Header: httpResp.Request.Header,
+			Depth:  resp.Depth(),
+		}
+	}
+	if req := resp.Request(); req != nil {
+		msg.Request.Priority = req.Priority()
+		msg.Request.Callback = req.Callback()
+		msg.Request.Meta = req.Meta()
+		msg.Request.Item = req.Item()
+	}
+	return msg, nil
+}
+
+// decode 用于把传输的响应还原为响应。
+func (msg *ResponseMessage) decode() (*module.Response, error) {
+	httpResp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", msg.StatusCode, http.StatusText(msg.StatusCode)),
+		StatusCode:    msg.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        msg.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(msg.Body)),
+		ContentLength: int64(len(msg.Body)),
+	}
+	if httpResp.Header == nil {
+		httpResp.Header = http.Header{}
+	}
+	if msg.Request.URL == "" {
+		resp := module.NewResponse(httpResp, msg.Depth)
+		resp.SetRedirects(msg.Redirects)
+		return resp, nil
+	}
+	req, err := msg.Request.decode()
+	if err != nil {
+		return nil, err
+	}
+	httpResp.Request = req.HTTPReq()
+	resp := module.NewResponseFor(httpResp, req)
+	resp.SetRedirects(msg.Redirects)
+	return resp, nil
+}
+
+// encodeError 用于把错误转换为可传输的形式。
+func encodeError(err error) ErrorMessage {
+	msg := ErrorMessage{Message: err.Error()}
+	if crawlerError, ok := err.(errors.CrawlerError); ok {
+		msg.Type = crawlerError.Type()
+	}
+	return msg
+}
+
+// encodeErrors 用于把错误列表转换为可传输的形式。
+func encodeErrors(errs []error) []ErrorMessage {
+	var msgs []ErrorMessage
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, encodeError(err))
+		}
+	}
+	return msgs
+}
+
+// remoteError 代表远程组件返回的错误，会保留原错误的类型和信息。
+type remoteError struct {
+	errType errors.ErrorType
+	msg     string
+}
+
+func (err *remoteError) Type() errors.ErrorType {
+	return err.errType
+}
+
+func (err *remoteError) Error() string {
+	return err.msg
+}
+
+// decode 用于把传输的错误还原为错误。
+// 没有类型的错误会被归为参数defaultType代表的类型。
+func (msg ErrorMessage) decode(defaultType errors.ErrorType) error {
+	errType := msg.Type
+	if errType == "" {
+		errType = defaultType
+	}
+	return &remoteError{errType: errType, msg: msg.Message}
+}
+
+// decodeErrors 用于把传输的错误列表还原为错误列表。
+func decodeErrors(msgs []ErrorMessage, defaultType errors.ErrorType) []error {
+	var errs []error
+	for _, msg := range msgs {
+		errs = append(errs, msg.decode(defaultType))
+	}
+	return errs
+}
@@ -0,0 +1,181 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mycha/errors"
+	"mycha/module"
+	"mycha/module/stub"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testDownloader 代表测试用的下载器，直接用默认的HTTP客户端下载。
+type testDownloader struct{ stub.ModuleInternal }
+
+func (d *testDownloader) Download(req *module.Request) (*module.Response, error) {
+	d.IncrCalledCount()
+	resp, err := http.DefaultClient.Do(req.HTTPReq())
+	if err != nil {
+		return nil, err
+	}
+	d.IncrCompletedCount()
+	return module.NewResponse(resp, req.Depth()), nil
+}
+
+// testAnalyzer 代表测试用的分析器，为每个响应生成一个请求、一个条目和一个错误。
+type testAnalyzer struct{ stub.ModuleInternal }
+
+func (a *testAnalyzer) RespParsers() []module.ParseResponse { return nil }
+func (a *testAnalyzer) Analyze(resp *module.Response) ([]module.Data, []error) {
+	b, _ := ioutil.ReadAll(resp.HTTPResp().Body)
+	httpReq, _ := http.NewRequest(http.MethodPost,
+		resp.HTTPResp().Request.URL.String()+"/next", strings.NewReader("payload"))
+	req := module.NewRequest(httpReq, resp.Depth()+1)
+	req.SetPriority(7)
+	return []module.Data{req, module.Item{"body": string(b), "n": 3}},
+		[]error{errors.NewCrawlerError(errors.ERROR_TYPE_ANALYZER, "test")}
+}
+
+// testPipeline 代表测试用的条目处理管道，会记录最后收到的条目。
+type testPipeline struct {
+	stub.ModuleInternal
+	item     module.Item
+	failFast bool
+}
+
+func (p *testPipeline) ItemProcessors() []module.ProcessItem { return nil }
+func (p *testPipeline) Send(item module.Item) []error {
+	p.item = item
+	return nil
+}
+func (p *testPipeline) FailFast() bool            { return p.failFast }
+func (p *testPipeline) SetFailFast(failFast bool) { p.failFast = failFast }
+
+func TestRemoteRoundTrip(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	}))
+	defer site.Close()
+	// 组件ID中的地址需要和工作节点的地址一致，所以先确定监听的地址。
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("An error occurs when listening: %s", err)
+	}
+	addr := ln.Addr().String()
+	newModule := func(letter string) stub.ModuleInternal {
+		m, err := stub.NewModuleInternal(module.MID(letter+"1|"+addr), nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating an internal module: %s", err)
+		}
+		return m
+	}
+	p := &testPipeline{ModuleInternal: newModule("P")}
+	handler, err := NewHandler(
+		&testDownloader{newModule("D")}, &testAnalyzer{newModule("A")}, p)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a handler: %s", err)
+	}
+	worker := &httptest.Server{Listener: ln, Config: &http.Server{Handler: handler}}
+	worker.Start()
+	defer worker.Close()
+
+	modules, err := Discover(addr, nil, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when discovering modules: %s", err)
+	}
+	if len(modules.Downloaders) != 1 || len(modules.Analyzers) != 1 || len(modules.Pipelines) != 1 {
+		t.Fatalf("Inconsistent number of modules: expected: 1/1/1, actual: %d/%d/%d",
+			len(modules.Downloaders), len(modules.Analyzers), len(modules.Pipelines))
+	}
+
+	httpReq, _ := http.NewRequest(http.MethodGet, site.URL+"/a", nil)
+	resp, err := modules.Downloaders[0].Download(module.NewRequest(httpReq, 2))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s", err)
+	}
+	httpResp := resp.HTTPResp()
+	if resp.Depth() != 2 || httpResp.Header.Get("X-Test") != "1" || httpResp.Request.URL.Path != "/a" {
+		t.Fatalf("Inconsistent response: depth: %d, header: %v, URL: %s",
+			resp.Depth(), httpResp.Header, httpResp.Request.URL)
+	}
+
+	data, errs := modules.Analyzers[0].Analyze(resp)
+	if len(errs) != 1 {
+		t.Fatalf("Inconsistent number of errors: expected: 1, actual: %d", len(errs))
+	}
+	if ce, ok := errs[0].(errors.CrawlerError); !ok || ce.Type() != errors.ERROR_TYPE_ANALYZER {
+		t.Fatalf("Inconsistent error: expected type: %s, actual: %#v", errors.ERROR_TYPE_ANALYZER, errs[0])
+	}
+	if len(data) != 2 {
+		t.Fatalf("Inconsistent number of data: expected: 2, actual: %d", len(data))
+	}
+	req, ok := data[0].(*module.Request)
+	if !ok {
+		t.Fatalf("Inconsistent type of data: expected: *module.Request, actual: %T", data[0])
+	}
+	body, _ := ioutil.ReadAll(req.HTTPReq().Body)
+	if req.Depth() != 3 || req.Priority() != 7 ||
+		req.HTTPReq().Method != http.MethodPost || string(body) != "payload" {
+		t.Fatalf("Inconsistent request: depth: %d, priority: %d, method: %s, body: %q",
+			req.Depth(), req.Priority(), req.HTTPReq().Method, body)
+	}
+	item, ok := data[1].(module.Item)
+	if !ok || item["body"] != "hello /a" {
+		t.Fatalf("Inconsistent item: expected body: %q, actual: %v", "hello /a", data[1])
+	}
+
+	modules.Pipelines[0].SetFailFast(true)
+	if errs := modules.Pipelines[0].Send(item); len(errs) != 0 {
+		t.Fatalf("An error occurs when sending item: %v", errs)
+	}
+	// 条目经过JSON传输，数字会变成float64。
+	if p.item["n"] != float64(3) || !p.failFast {
+		t.Fatalf("Inconsistent item or fail-fast: item: %v, fail-fast: %v", p.item, p.failFast)
+	}
+
+	httpReq, _ = http.NewRequest(http.MethodGet, "http://127.0.0.1:1/x", nil)
+	if _, err := modules.Downloaders[0].Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatalf("No error when downloading from an unreachable site")
+	}
+	if completed := modules.Downloaders[0].Summary().Completed; completed != 1 {
+		t.Fatalf("Inconsistent completed count: expected: 1, actual: %d", completed)
+	}
+}
+
+// zeroReader 代表无限长的全零数据。
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestEncodeResponseMaxBodySize(t *testing.T) {
+	for _, size := range []int64{maxBodySize, maxBodySize + 1} {
+		httpResp := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(io.LimitReader(zeroReader{}, size)),
+		}
+		msg, err := encodeResponse(module.NewResponse(httpResp, 0))
+		if size > maxBodySize {
+			if err == nil {
+				t.Fatalf("No error when the response body exceeds %d bytes", maxBodySize)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when encoding response: %s", err)
+		}
+		if int64(len(msg.Body)) != size {
+			t.Fatalf("Inconsistent body size: expected: %d, actual: %d", size, len(msg.Body))
+		}
+	}
+}
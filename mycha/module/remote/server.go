@@ -0,0 +1,209 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"mycha/errors"
+	"mycha/helper/log"
+	"mycha/module"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var logger = log.DLogger()
+
+// NewHandler 用于创建在工作节点上托管组件的HTTP处理器。
+// 支持的路径如下：
+//
+//	GET  /modules             托管的组件列表
+//	POST /download?mid=<MID>  使用下载器下载，消息体为RequestMessage
+//	POST /analyze?mid=<MID>   使用分析器分析，消息体为ResponseMessage
+//	POST /send?mid=<MID>      使用条目处理管道处理条目，消息体为SendArgs
+func NewHandler(modules ...module.Module) (http.Handler, error) {
+	h := &handler{modules: map[module.MID]module.Module{}}
+	for i, m := range modules {
+		if m == nil {
+			return nil, genParameterError(fmt.Sprintf("nil module[%d]", i))
+		}
+		if _, ok := h.modules[m.ID()]; ok {
+			return nil, genParameterError(fmt.Sprintf("duplicate module %q", m.ID()))
+		}
+		h.modules[m.ID()] = m
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(PATH_MODULES, h.handleModules)
+	mux.HandleFunc(PATH_DOWNLOAD, h.handleDownload)
+	mux.HandleFunc(PATH_ANALYZE, h.handleAnalyze)
+	mux.HandleFunc(PATH_SEND, h.handleSend)
+	return mux, nil
+}
+
+// handler 代表工作节点的HTTP处理器。
+type handler struct {
+	modules map[module.MID]module.Module
+	// lock 用于保护条目处理管道的快速失败设置。
+	lock sync.Mutex
+}
+
+func (h *handler) handleModules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	infos := make([]ModuleInfo, 0, len(h.modules))
+	for _, m := range h.modules {
+		infos = append(infos, ModuleInfo{Type: moduleType(m), Summary: m.Summary()})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Summary.ID < infos[j].Summary.ID
+	})
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (h *handler) handleDownload(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.module(w, r, module.TYPE_DOWNLOADER)
+	if !ok {
+		return
+	}
+	var msg RequestMessage
+	if !readJSON(w, r, &msg) {
+		return
+	}
+	result := DownloadResult{}
+	req, err := msg.decode()
+	if err == nil {
+		var resp *module.Response
+		resp, err = m.(module.Downloader).Download(req)
+		if resp != nil && resp.Valid() {
+			result.Response, err = encodeResponseKeepError(resp, err)
+		}
+	}
+	if err != nil {
+		errMsg := encodeError(err)
+		result.Error = &errMsg
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// encodeResponseKeepError 用于转换下载得到的响应，下载本身的错误优先。
+func encodeResponseKeepError(resp *module.Response, downloadErr error) (*ResponseMessage, error) {
+	msg, err := encodeResponse(resp)
+	if downloadErr != nil {
+		return msg, downloadErr
+	}
+	return msg, err
+}
+
+func (h *handler) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.module(w, r, module.TYPE_ANALYZER)
+	if !ok {
+		return
+	}
+	var msg ResponseMessage
+	if !readJSON(w, r, &msg) {
+		return
+	}
+	resp, err := msg.decode()
+	if err != nil {
+		writeJSON(w, http.StatusOK, AnalyzeResult{Errors: []ErrorMessage{encodeError(err)}})
+		return
+	}
+	dataList, errs := m.(module.Analyzer).Analyze(resp)
+	result := AnalyzeResult{Errors: encodeErrors(errs)}
+	for _, data := range dataList {
+		switch d := data.(type) {
+		case *module.Request:
+			reqMsg, err := encodeRequest(d)
+			if err != nil {
+				result.Errors = append(result.Errors, encodeError(err))
+				continue
+			}
+			result.Data = append(result.Data, DataMessage{Request: reqMsg})
+		case module.Item:
+			result.Data = append(result.Data, DataMessage{Item: d})
+		case nil:
+		default:
+			err := errors.NewCrawlerError(errors.ERROR_TYPE_ANALYZER,
+				fmt.Sprintf("unsupported data type %T", d))
+			result.Errors = append(result.Errors, encodeError(err))
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *handler) handleSend(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.module(w, r, module.TYPE_PIPELINE)
+	if !ok {
+		return
+	}
+	var args SendArgs
+	if !readJSON(w, r, &args) {
+		return
+	}
+	pipeline := m.(module.Pipeline)
+	// 快速失败的设置以调用方为准。
+	h.lock.Lock()
+	if pipeline.FailFast() != args.FailFast {
+		pipeline.SetFailFast(args.FailFast)
+	}
+	h.lock.Unlock()
+	errs := pipeline.Send(args.Item)
+	writeJSON(w, http.StatusOK, SendResult{Errors: encodeErrors(errs)})
+}
+
+// module 用于获取请求中指定的组件，失败时会直接写入响应。
+func (h *handler) module(w http.ResponseWriter, r *http.Request,
+	mType module.Type) (module.Module, bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, nil)
+		return nil, false
+	}
+	mid := module.MID(r.URL.Query().Get("mid"))
+	m, ok := h.modules[mid]
+	if !ok {
+		writeJSON(w, http.StatusNotFound,
+			ErrorMessage{Message: fmt.Sprintf("module %q not found", mid)})
+		return nil, false
+	}
+	if !module.CheckType(mType, m) {
+		writeJSON(w, http.StatusBadRequest,
+			ErrorMessage{Message: fmt.Sprintf("module %q is not a %s", mid, mType)})
+		return nil, false
+	}
+	return m, true
+}
+
+// moduleType 用于判断组件的类型。
+func moduleType(m module.Module) module.Type {
+	for _, mType := range []module.Type{
+		module.TYPE_DOWNLOADER, module.TYPE_ANALYZER, module.TYPE_PIPELINE} {
+		if module.CheckType(mType, m) {
+			return mType
+		}
+	}
+	return ""
+}
+
+// readJSON 用于读取JSON格式的请求消息，失败时会直接写入响应。
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err := decoder.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest,
+			ErrorMessage{Message: fmt.Sprintf("invalid message: %s", err)})
+		return false
+	}
+	return true
+}
+
+// writeJSON 用于以JSON格式写入响应。
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if v == nil {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Errorf("写入远程组件的响应时出错: %s", err)
+	}
+}
@@ -0,0 +1,200 @@
+package module
+
+import (
+	"fmt"
+	"math/rand"
+	"mycha/errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 组件选择策略的名称。
+const (
+	// SELECTOR_SCORE 代表选择评分最低的组件，也是默认的策略。
+	SELECTOR_SCORE = "score"
+	// SELECTOR_ROUND_ROBIN 代表轮流选择组件。
+	SELECTOR_ROUND_ROBIN = "round_robin"
+	// SELECTOR_LEAST_IN_FLIGHT 代表选择实时处理数最少的组件。
+	SELECTOR_LEAST_IN_FLIGHT = "least_in_flight"
+	// SELECTOR_WEIGHTED_RANDOM 代表按权重随机选择组件。
+	SELECTOR_WEIGHTED_RANDOM = "weighted_random"
+	// SELECTOR_LATENCY_EWMA 代表选择耗时的指数加权移动平均值最小的组件。
+	SELECTOR_LATENCY_EWMA = "latency_ewma"
+)
+
+// Selector 代表从同类型组件中选择一个组件的策略。
+type Selector interface {
+	// Select 用于从给定的组件中选择一个，参数modules按MID排序且不为空。
+	Select(modules []Module) Module
+}
+
+// LatencyObserver 代表需要知道每次调用耗时的选择策略。
+type LatencyObserver interface {
+	// ObserveLatency 用于记录某个组件一次调用的耗时。
+	ObserveLatency(mid MID, latency time.Duration)
+	// Forget 用于删除某个组件的耗时记录，组件被注销时会被调用。
+	Forget(mid MID)
+}
+
+// Weighted 代表带有权重的组件，按权重随机选择时使用。
+// 没有实现该接口的组件的权重为1。
+type Weighted interface {
+	// Weight 用于获取组件的权重。
+	Weight() uint32
+	// SetWeight 用于设置组件的权重。
+	SetWeight(weight uint32)
+}
+
+// NewSelector 用于根据名称创建组件选择策略，名称为空时使用默认的策略。
+func NewSelector(name string) (Selector, error) {
+	switch name {
+	case "", SELECTOR_SCORE:
+		return &scoreSelector{}, nil
+	case SELECTOR_ROUND_ROBIN:
+		return &roundRobinSelector{}, nil
+	case SELECTOR_LEAST_IN_FLIGHT:
+		return &leastInFlightSelector{}, nil
+	case SELECTOR_WEIGHTED_RANDOM:
+		return &weightedRandomSelector{
+			rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+		}, nil
+	case SELECTOR_LATENCY_EWMA:
+		return &latencyEWMASelector{
+			alpha:    defaultEWMAAlpha,
+			averages: map[MID]float64{},
+		}, nil
+	}
+	return nil, errors.NewCrawlerError(errors.ERROR_TYPE_PARAMETER,
+		fmt.Sprintf("不支持的组件选择策略: %q", name))
+}
+
+// scoreSelector 代表选择评分最低的组件的策略。
+type scoreSelector struct{}
+
+func (selector *scoreSelector) Select(modules []Module) Module {
+	var selected Module
+	var minScore uint32
+	for _, module := range modules {
+		SetScore(module)
+		score := module.Score()
+		if selected == nil || score < minScore {
+			selected = module
+			minScore = score
+		}
+	}
+	return selected
+}
+
+// roundRobinSelector 代表轮流选择组件的策略。
+type roundRobinSelector struct {
+	next uint64
+}
+
+func (selector *roundRobinSelector) Select(modules []Module) Module {
+	n := atomic.AddUint64(&selector.next, 1) - 1
+	return modules[n%uint64(len(modules))]
+}
+
+// leastInFlightSelector 代表选择实时处理数最少的组件的策略。
+// 处理数相同时轮流选择，以免总是选中同一个组件。
+type leastInFlightSelector struct {
+	next uint64
+}
+
+func (selector *leastInFlightSelector) Select(modules []Module) Module {
+	var candidates []Module
+	var minHandling uint32
+	for _, module := range modules {
+		handling := module.Handling()
+		switch {
+		case candidates == nil || handling < minHandling:
+			candidates = []Module{module}
+			minHandling = handling
+		case handling == minHandling:
+			candidates = append(candidates, module)
+		}
+	}
+	n := atomic.AddUint64(&selector.next, 1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// weightedRandomSelector 代表按权重随机选择组件的策略。
+type weightedRandomSelector struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func (selector *weightedRandomSelector) Select(modules []Module) Module {
+	var total uint64
+	weights := make([]uint64, len(modules))
+	for i, module := range modules {
+		weight := uint64(1)
+		if weighted, ok := module.(Weighted); ok {
+			weight = uint64(weighted.Weight())
+		}
+		weights[i] = weight
+		total += weight
+	}
+	if total == 0 {
+		return nil
+	}
+	selector.lock.Lock()
+	n := uint64(selector.rand.Int63n(int64(total)))
+	selector.lock.Unlock()
+	for i, weight := range weights {
+		if n < weight {
+			return modules[i]
+		}
+		n -= weight
+	}
+	return modules[len(modules)-1]
+}
+
+// defaultEWMAAlpha 代表耗时的指数加权移动平均值中最新耗时的权重。
+const defaultEWMAAlpha = 0.3
+
+// latencyEWMASelector 代表选择耗时的指数加权移动平均值最小的组件的策略。
+// 还没有耗时记录的组件会被优先选择，以便尽快得到它的耗时。
+type latencyEWMASelector struct {
+	alpha    float64
+	lock     sync.Mutex
+	averages map[MID]float64
+	next     uint64
+}
+
+func (selector *latencyEWMASelector) Select(modules []Module) Module {
+	selector.lock.Lock()
+	var candidates []Module
+	var minAverage float64
+	for _, module := range modules {
+		average := selector.averages[module.ID()]
+		switch {
+		case candidates == nil || average < minAverage:
+			candidates = []Module{module}
+			minAverage = average
+		case average == minAverage:
+			candidates = append(candidates, module)
+		}
+	}
+	selector.lock.Unlock()
+	n := atomic.AddUint64(&selector.next, 1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+func (selector *latencyEWMASelector) ObserveLatency(mid MID, latency time.Duration) {
+	selector.lock.Lock()
+	defer selector.lock.Unlock()
+	average, ok := selector.averages[mid]
+	if !ok {
+		selector.averages[mid] = float64(latency)
+		return
+	}
+	selector.averages[mid] = selector.alpha*float64(latency) + (1-selector.alpha)*average
+}
+
+func (selector *latencyEWMASelector) Forget(mid MID) {
+	selector.lock.Lock()
+	defer selector.lock.Unlock()
+	delete(selector.averages, mid)
+}
@@ -0,0 +1,159 @@
+package module
+
+import (
+	"testing"
+	"time"
+)
+
+// testDownloader 代表测试用的下载器，可以指定实时处理数和权重。
+type testDownloader struct {
+	mid      MID
+	score    uint32
+	handling uint32
+	weight   uint32
+}
+
+func (d *testDownloader) ID() MID                         { return d.mid }
+func (d *testDownloader) Addr() string                    { return "" }
+func (d *testDownloader) Score() uint32                   { return d.score }
+func (d *testDownloader) SetScore(score uint32)           { d.score = score }
+func (d *testDownloader) ScoreCalculator() CalculateScore { return nil }
+func (d *testDownloader) CallCount() uint32               { return 0 }
+func (d *testDownloader) AcceptedCount() uint32           { return 0 }
+func (d *testDownloader) Completed() uint32               { return 0 }
+func (d *testDownloader) Handling() uint32                { return d.handling }
+func (d *testDownloader) Counts() Counts                  { return Counts{HandlingNum: d.handling} }
+func (d *testDownloader) Summary() SummaryStruct          { return SummaryStruct{ID: d.mid} }
+func (d *testDownloader) Weight() uint32                  { return d.weight }
+func (d *testDownloader) SetWeight(weight uint32)         { d.weight = weight }
+func (d *testDownloader) Download(req *Request) (*Response, error) {
+	return nil, nil
+}
+
+// newTestRegistrar 用于创建注册了给定下载器的注册器。
+func newTestRegistrar(t *testing.T, downloaders ...*testDownloader) Registrar {
+	registrar := NewRegistrar()
+	for _, d := range downloaders {
+		if ok, err := registrar.Register(d); !ok || err != nil {
+			t.Fatalf("Could not register downloader %s: %v", d.mid, err)
+		}
+	}
+	return registrar
+}
+
+// countSelected 用于统计n次选择中各组件被选中的次数。
+func countSelected(t *testing.T, registrar Registrar, n int) map[MID]int {
+	counts := map[MID]int{}
+	for i := 0; i < n; i++ {
+		m, err := registrar.Get(TYPE_DOWNLOADER)
+		if err != nil {
+			t.Fatalf("An error occurs when getting a downloader: %s", err)
+		}
+		counts[m.ID()]++
+	}
+	return counts
+}
+
+func TestSelectors(t *testing.T) {
+	d1 := &testDownloader{mid: "D1", weight: 1, handling: 2}
+	d2 := &testDownloader{mid: "D2", weight: 0, handling: 1}
+	d3 := &testDownloader{mid: "D3", weight: 3, handling: 1}
+	cases := []struct {
+		selector string
+		// check 用于检查400次选择的结果是否符合预期。
+		check func(counts map[MID]int) bool
+	}{
+		{SELECTOR_ROUND_ROBIN, func(counts map[MID]int) bool {
+			return counts["D1"] == 134 && counts["D2"] == 133 && counts["D3"] == 133
+		}},
+		{SELECTOR_LEAST_IN_FLIGHT, func(counts map[MID]int) bool {
+			return counts["D1"] == 0 && counts["D2"] == 200 && counts["D3"] == 200
+		}},
+		{SELECTOR_WEIGHTED_RANDOM, func(counts map[MID]int) bool {
+			return counts["D2"] == 0 && counts["D3"] > 2*counts["D1"]
+		}},
+	}
+	for _, c := range cases {
+		// 注册的顺序不影响选择的顺序。
+		registrar := newTestRegistrar(t, d3, d1, d2)
+		selector, err := NewSelector(c.selector)
+		if err != nil {
+			t.Fatalf("An error occurs when creating selector %q: %s", c.selector, err)
+		}
+		registrar.SetSelector(TYPE_DOWNLOADER, selector)
+		if counts := countSelected(t, registrar, 400); !c.check(counts) {
+			t.Fatalf("Unexpected selection of %s: %v", c.selector, counts)
+		}
+		if n := registrar.SelectedCount("D1") + registrar.SelectedCount("D2") +
+			registrar.SelectedCount("D3"); n != 400 {
+			t.Fatalf("Inconsistent selected count of %s: expected: %d, actual: %d", c.selector, 400, n)
+		}
+	}
+	if _, err := NewSelector("unknown"); err == nil {
+		t.Fatalf("No error when creating an unknown selector")
+	}
+}
+
+func TestSelectorLatencyEWMA(t *testing.T) {
+	registrar := newTestRegistrar(t,
+		&testDownloader{mid: "D1"}, &testDownloader{mid: "D2"}, &testDownloader{mid: "D3"})
+	selector, _ := NewSelector(SELECTOR_LATENCY_EWMA)
+	registrar.SetSelector(TYPE_DOWNLOADER, selector)
+	registrar.ObserveLatency("D1", 50*time.Millisecond)
+	registrar.ObserveLatency("D2", 5*time.Millisecond)
+	registrar.ObserveLatency("D3", 10*time.Millisecond)
+	if counts := countSelected(t, registrar, 10); counts["D2"] != 10 {
+		t.Fatalf("Unexpected selection: %v", counts)
+	}
+	// D2的耗时变长之后，平均耗时最小的变为D3。
+	for i := 0; i < 10; i++ {
+		registrar.ObserveLatency("D2", 100*time.Millisecond)
+	}
+	if counts := countSelected(t, registrar, 10); counts["D3"] != 10 {
+		t.Fatalf("Unexpected selection after D2 slows down: %v", counts)
+	}
+}
+
+func TestRegistrarUnregisterForgetsLatency(t *testing.T) {
+	d1 := &testDownloader{mid: "D1"}
+	registrar := newTestRegistrar(t, d1, &testDownloader{mid: "D2"})
+	selector, _ := NewSelector(SELECTOR_LATENCY_EWMA)
+	registrar.SetSelector(TYPE_DOWNLOADER, selector)
+	registrar.ObserveLatency("D1", 50*time.Millisecond)
+	registrar.ObserveLatency("D2", 5*time.Millisecond)
+	if ok, _ := registrar.Unregister("D1"); !ok {
+		t.Fatalf("Could not unregister downloader D1")
+	}
+	if ok, err := registrar.Register(d1); !ok || err != nil {
+		t.Fatalf("Could not register downloader D1 again: %v", err)
+	}
+	// 重新注册的组件没有耗时记录，会被优先选择。
+	if m, _ := registrar.Get(TYPE_DOWNLOADER); m.ID() != "D1" {
+		t.Fatalf("Inconsistent downloader: expected: %s, actual: %s", "D1", m.ID())
+	}
+}
+
+func TestRegistrarGet(t *testing.T) {
+	registrar := newTestRegistrar(t, &testDownloader{mid: "D3"}, &testDownloader{mid: "D1"})
+	selector, _ := NewSelector(SELECTOR_ROUND_ROBIN)
+	registrar.SetSelector(TYPE_DOWNLOADER, selector)
+	registrar.Register(&testDownloader{mid: "D2"})
+	registrar.Unregister("D3")
+	for i, expected := range []MID{"D1", "D2", "D1", "D2"} {
+		m, err := registrar.Get(TYPE_DOWNLOADER)
+		if err != nil {
+			t.Fatalf("An error occurs when getting a downloader: %s", err)
+		}
+		if m.ID() != expected {
+			t.Fatalf("Inconsistent downloader[%d]: expected: %s, actual: %s", i, expected, m.ID())
+		}
+	}
+	registrar.Clear()
+	if _, err := registrar.Get(TYPE_DOWNLOADER); err != ErrNotFoundModulInstance {
+		t.Fatalf("Inconsistent error after clear: expected: %v, actual: %v",
+			ErrNotFoundModulInstance, err)
+	}
+	if _, err := registrar.Get(Type("unknown")); err == nil {
+		t.Fatalf("No error when getting a module of an unknown type")
+	}
+}
@@ -4,6 +4,7 @@ import "mycha/module"
 
 type ModuleInternal interface {
 	module.Module
+	module.Weighted
 	// IncrCalledCount 会把调用计数增1。
 	IncrCalledCount()
 	// IncrAcceptedCount 会把接受计数增1。
@@ -23,6 +23,8 @@ type myModule struct {
 	completedCount uint32
 	// handlingNumber 代表实时处理数。
 	handlingNumber uint32
+	// weight 代表按权重随机选择组件时的权重。
+	weight uint32
 }
 
 func NewModuleInternal(mid module.MID,
@@ -37,6 +39,7 @@ func NewModuleInternal(mid module.MID,
 		mid:             mid,
 		addr:            parts[2],
 		scoreCalculator: scoreCalculator,
+		weight:          1,
 	}, nil
 }
 
@@ -60,6 +63,14 @@ func (m *myModule) ScoreCalculator() module.CalculateScore {
 	return m.scoreCalculator
 }
 
+func (m *myModule) Weight() uint32 {
+	return atomic.LoadUint32(&m.weight)
+}
+
+func (m *myModule) SetWeight(weight uint32) {
+	atomic.StoreUint32(&m.weight, weight)
+}
+
 func (m *myModule) CalledCount() uint32 {
 	return atomic.LoadUint32(&m.calledCount)
 }
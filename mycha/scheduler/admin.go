@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AdminArgs 代表管理接口的参数。
+type AdminArgs struct {
+	// Token 代表调用/control时需要提供的令牌，
+	// 请求需带有“Authorization: Bearer <Token>”头。
+	// 为空时/control只接受来自本机回环地址的请求。
+	Token string `json:"-"`
+}
+
+// adminResult 代表管理接口执行命令的结果。
+type adminResult struct {
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PoolsSummaryStruct 代表各缓冲池的摘要类型。
+type PoolsSummaryStruct struct {
+	ReqBufferPool   BufferPoolSummaryStruct `json:"request_buffer_pool"`
+	RespBufferPool  BufferPoolSummaryStruct `json:"response_buffer_pool"`
+	ItemBufferPool  BufferPoolSummaryStruct `json:"item_buffer_pool"`
+	ErrorBufferPool BufferPoolSummaryStruct `json:"error_buffer_pool"`
+}
+
+// NewAdminHandler 用于创建调度器的HTTP管理接口。
+// 支持的路径如下：
+//
+//	GET  /summary  调度器的摘要
+//	GET  /modules  各组件的摘要
+//	GET  /pools    各缓冲池的摘要
+//	GET  /errors   最近发生的错误
+//	GET  /metrics  Prometheus文本格式的指标
+//	POST /control?cmd=pause|resume|stop  控制调度器，需通过args中的令牌或来自本机
+func NewAdminHandler(sched scheduler, args AdminArgs) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/summary", func(w http.ResponseWriter, r *http.Request) {
+		summary, ok := adminSummary(w, r, sched)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
+	})
+	mux.HandleFunc("/modules", func(w http.ResponseWriter, r *http.Request) {
+		summary, ok := adminSummary(w, r, sched)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"downloaders": summary.Downloaders,
+			"analyzers":   summary.Analyzers,
+			"pipelines":   summary.Pipelines,
+			"evicted":     summary.Evicted,
+		})
+	})
+	mux.HandleFunc("/pools", func(w http.ResponseWriter, r *http.Request) {
+		summary, ok := adminSummary(w, r, sched)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, PoolsSummaryStruct{
+			ReqBufferPool:   summary.ReqBufferPool,
+			RespBufferPool:  summary.RespBufferPool,
+			ItemBufferPool:  summary.ItemBufferPool,
+			ErrorBufferPool: summary.ErrorBufferPool,
+		})
+	})
+	mux.HandleFunc("/errors", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		records := []ErrorRecord{}
+		if ms, ok := sched.(*myScheduler); ok && ms.errorRecorder != nil {
+			records = append(records, ms.errorRecorder.recent()...)
+		}
+		writeJSON(w, http.StatusOK, records)
+	})
+	mux.Handle("/metrics", NewMetricsHandler(sched))
+	mux.HandleFunc("/control", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		if !args.authorized(r) {
+			writeJSON(w, http.StatusForbidden, adminResult{Error: "没有权限控制调度器"})
+			return
+		}
+		cmd := r.URL.Query().Get("cmd")
+		result := adminResult{Command: cmd}
+		var err error
+		code := http.StatusOK
+		switch cmd {
+		case "stop":
+			err = sched.Stop()
+		case "pause":
+			err = sched.Pause()
+		case "resume":
+			err = sched.Resume()
+		default:
+			code = http.StatusBadRequest
+			result.Error = "不支持的命令: " + cmd
+		}
+		if err != nil {
+			code = http.StatusConflict
+			result.Error = err.Error()
+		}
+		result.Status = GetStatusDescription(sched.Status())
+		writeJSON(w, code, result)
+	})
+	return mux
+}
+
+// ServeAdmin 用于在给定的地址上启动调度器的HTTP管理接口。
+// 返回的服务器可用于关闭管理接口，启动失败的错误会记录到日志中。
+func ServeAdmin(sched scheduler, addr string, args AdminArgs) *http.Server {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: NewAdminHandler(sched, args),
+	}
+	go func() {
+		logger.Infof("调度器管理接口启动于 %s", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("调度器管理接口出错: %s", err)
+		}
+	}()
+	return server
+}
+
+// authorized 用于判断请求是否可以控制调度器。
+func (args *AdminArgs) authorized(r *http.Request) bool {
+	if args.Token == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return false
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(args.Token)) == 1
+}
+
+// adminSummary 用于获取调度器的摘要，失败时会直接写入响应。
+func adminSummary(w http.ResponseWriter, r *http.Request, sched scheduler) (SummaryStruct, bool) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, nil)
+		return SummaryStruct{}, false
+	}
+	summary := sched.Summary()
+	if summary == nil {
+		writeJSON(w, http.StatusServiceUnavailable,
+			adminResult{Error: "调度器还没有初始化"})
+		return SummaryStruct{}, false
+	}
+	return summary.Struct(), true
+}
+
+// writeJSON 用于以JSON格式写入响应。
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if v == nil {
+		return
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(v); err != nil {
+		logger.Errorf("写入管理接口的响应时出错: %s", err)
+	}
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestAdminScheduler(t *testing.T) *myScheduler {
+	requestArgs, dataArgs, moduleArgs, _ := newTestArgs()
+	sched := NewScheduler().(*myScheduler)
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	return sched
+}
+
+func TestAdminControlAuth(t *testing.T) {
+	sched := newTestAdminScheduler(t)
+	cases := []struct {
+		name       string
+		token      string
+		remoteAddr string
+		auth       string
+		expected   int
+	}{
+		{"remote without token", "", "192.0.2.1:1234", "", http.StatusForbidden},
+		{"loopback without token", "", "127.0.0.1:1234", "", http.StatusBadRequest},
+		{"ipv6 loopback without token", "", "[::1]:1234", "", http.StatusBadRequest},
+		{"missing token", "secret", "127.0.0.1:1234", "", http.StatusForbidden},
+		{"wrong token", "secret", "192.0.2.1:1234", "Bearer wrong", http.StatusForbidden},
+		{"wrong scheme", "secret", "192.0.2.1:1234", "Basic secret", http.StatusForbidden},
+		{"right token", "secret", "192.0.2.1:1234", "Bearer secret", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		handler := NewAdminHandler(sched, AdminArgs{Token: c.token})
+		// 未知的命令在通过检查后返回400，不会改变调度器的状态。
+		r := httptest.NewRequest(http.MethodPost, "/control?cmd=unknown", nil)
+		r.RemoteAddr = c.remoteAddr
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.expected {
+			t.Fatalf("Inconsistent status code of %s: expected: %d, actual: %d",
+				c.name, c.expected, w.Code)
+		}
+	}
+}
+
+func TestAdminErrorsWithoutErrorChan(t *testing.T) {
+	sched := newTestAdminScheduler(t)
+	// 没有人接收ErrorChan时，错误同样会被记录。
+	sched.sendError(errors.New("something wrong"), "")
+	w := httptest.NewRecorder()
+	NewAdminHandler(sched, AdminArgs{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/errors", nil))
+	var records []ErrorRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil {
+		t.Fatalf("An error occurs when decoding errors: %s", err)
+	}
+	if len(records) != 1 || records[0].Message == "" || records[0].Type == "" {
+		t.Fatalf("Inconsistent error records: %+v", records)
+	}
+}
@@ -1,6 +1,12 @@
 package scheduler
 
-import "mycha/module"
+import (
+	"fmt"
+	"mycha/module"
+	"mycha/tool/dedup"
+	"strings"
+	"time"
+)
 
 //Args 代表参数容器的统一接口类型
 type Args interface {
@@ -13,12 +19,31 @@ type RequestArgs struct {
 	AcceptedDomains []string `json:"accepted_primary_domains"`
 	//代表可以爬取的最大深度
 	MaxDepth uint32 `json:"max_depth"`
+	//代表一次下载中最多跟随的跳转次数 为0时为10
+	MaxRedirects uint32 `json:"max_redirects"`
+	//代表针对单个主机的礼貌爬取参数
+	Politeness PolitenessArgs `json:"politeness"`
+	//代表robots.txt相关的参数
+	Robots RobotsArgs `json:"robots"`
+	//代表下载失败时的重试策略
+	Retry RetryArgs `json:"retry"`
+	//代表空闲检测和自动停止的参数
+	Idle IdleArgs `json:"idle"`
 }
 
 func (req *RequestArgs) Check() error {
 	if req.AcceptedDomains == nil {
 		return genError("限定域名列表为空")
 	}
+	if err := req.Politeness.Check(); err != nil {
+		return err
+	}
+	if err := req.Robots.Check(); err != nil {
+		return err
+	}
+	if err := req.Retry.Check(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -30,6 +55,21 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 	if another.MaxDepth != args.MaxDepth {
 		return false
 	}
+	if another.MaxRedirects != args.MaxRedirects {
+		return false
+	}
+	if another.Politeness != args.Politeness {
+		return false
+	}
+	if another.Robots != args.Robots {
+		return false
+	}
+	if !another.Retry.Same(&args.Retry) {
+		return false
+	}
+	if another.Idle != args.Idle {
+		return false
+	}
 	anotherDomains := another.AcceptedDomains
 	anotherDomainsLen := len(another.AcceptedDomains)
 	if anotherDomainsLen != len(args.AcceptedDomains) {
@@ -45,12 +85,154 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 	return true
 }
 
+// PolitenessArgs 代表针对单个主机的礼貌爬取参数。
+// 各项为0时代表不做对应的限制。
+type PolitenessArgs struct {
+	// CrawlDelay 代表对同一主机相邻两次请求的最小间隔，单位为毫秒。
+	CrawlDelay uint32 `json:"crawl_delay"`
+	// RatePerSecond 代表每个主机每秒允许的请求数。
+	RatePerSecond float64 `json:"rate_per_second"`
+	// Burst 代表令牌桶的容量，为0时按1处理。
+	Burst uint32 `json:"burst"`
+	// MaxInFlightPerHost 代表每个主机同时进行中的最大请求数。
+	MaxInFlightPerHost uint32 `json:"max_in_flight_per_host"`
+	// MaxInFlight 代表所有主机同时进行中的最大请求数，为0时为16。
+	MaxInFlight uint32 `json:"max_in_flight"`
+}
+
+func (args *PolitenessArgs) Check() error {
+	if args.RatePerSecond < 0 {
+		return genError("每秒请求数不能为负数")
+	}
+	return nil
+}
+
+// defaultMaxRedirects 代表一次下载中默认最多跟随的跳转次数。
+const defaultMaxRedirects = 10
+
+// maxRedirects 用于获取一次下载中最多跟随的跳转次数。
+func (req *RequestArgs) maxRedirects() int {
+	if req.MaxRedirects == 0 {
+		return defaultMaxRedirects
+	}
+	return int(req.MaxRedirects)
+}
+
+// RobotsArgs 代表robots.txt相关的参数。
+// robots.txt因5xx或网络错误暂时无法获取时，请求会按Retry的策略重试，
+// 每次至少等待robots.ErrorTTL，达到最大重试次数后被丢弃。
+type RobotsArgs struct {
+	// Enabled 代表是否遵守robots.txt。
+	Enabled bool `json:"enabled"`
+	// UserAgent 代表评估规则时使用的用户代理，也会作为请求的默认User-Agent。
+	UserAgent string `json:"user_agent"`
+	// CacheTTL 代表规则的缓存时长，单位为毫秒，为0时使用默认值。
+	CacheTTL uint32 `json:"cache_ttl"`
+}
+
+// defaultRobotsCacheTTL 代表robots.txt规则默认的缓存时长。
+const defaultRobotsCacheTTL = 24 * time.Hour
+
+func (args *RobotsArgs) Check() error {
+	if args.Enabled && strings.TrimSpace(args.UserAgent) == "" {
+		return genError("启用robots.txt时必须指定用户代理")
+	}
+	return nil
+}
+
+// cacheTTL 用于获取规则的缓存时长。
+func (args *RobotsArgs) cacheTTL() time.Duration {
+	if args.CacheTTL == 0 {
+		return defaultRobotsCacheTTL
+	}
+	return time.Duration(args.CacheTTL) * time.Millisecond
+}
+
+// IdleArgs 代表空闲检测和自动停止的参数。
+type IdleArgs struct {
+	// Window 代表持续空闲多久之后自动停止调度器，单位为毫秒，为0时不自动停止。
+	Window uint32 `json:"window"`
+	// CheckInterval 代表检查空闲状态的间隔，单位为毫秒，为0时使用默认值。
+	CheckInterval uint32 `json:"check_interval"`
+}
+
+// defaultIdleCheckInterval 代表默认的空闲检查间隔。
+const defaultIdleCheckInterval = 100 * time.Millisecond
+
+// checkInterval 用于获取检查空闲状态的间隔。
+func (args *IdleArgs) checkInterval() time.Duration {
+	if args.CheckInterval == 0 {
+		return defaultIdleCheckInterval
+	}
+	return time.Duration(args.CheckInterval) * time.Millisecond
+}
+
+// RetryArgs 代表下载失败时的重试策略。
+// 第n次重试前的等待时间为BaseDelay*2^(n-1)，不超过MaxDelay，并按Jitter随机浮动。
+type RetryArgs struct {
+	// MaxRetries 代表单个请求的最大重试次数，为0时不重试。
+	MaxRetries uint32 `json:"max_retries"`
+	// BaseDelay 代表首次重试前的等待时间，单位为毫秒。
+	BaseDelay uint32 `json:"base_delay"`
+	// MaxDelay 代表重试前等待时间的上限，包括Retry-After要求的时间，单位为毫秒，为0时不设上限。
+	MaxDelay uint32 `json:"max_delay"`
+	// Jitter 代表等待时间随机浮动的比例，取值范围为[0, 1]。
+	Jitter float64 `json:"jitter"`
+	// RetryNetworkErrors 代表是否重试超时、连接失败等网络错误。
+	RetryNetworkErrors bool `json:"retry_network_errors"`
+	// RetryStatusCodes 代表需要重试的HTTP状态码，为空时使用429和5xx。
+	RetryStatusCodes []int `json:"retry_status_codes"`
+}
+
+func (args *RetryArgs) Check() error {
+	if args.Jitter < 0 || args.Jitter > 1 {
+		return genError("重试的随机浮动比例必须在0和1之间")
+	}
+	if args.MaxDelay > 0 && args.MaxDelay < args.BaseDelay {
+		return genError("重试的最大等待时间小于首次等待时间")
+	}
+	for _, code := range args.RetryStatusCodes {
+		if code < 100 || code > 999 {
+			return genError(fmt.Sprintf("不合法的重试状态码: %d", code))
+		}
+	}
+	return nil
+}
+
+//检查当前的重试策略和另外一个是否一致
+func (args *RetryArgs) Same(another *RetryArgs) bool {
+	if another == nil {
+		return false
+	}
+	if another.MaxRetries != args.MaxRetries ||
+		another.BaseDelay != args.BaseDelay ||
+		another.MaxDelay != args.MaxDelay ||
+		another.Jitter != args.Jitter ||
+		another.RetryNetworkErrors != args.RetryNetworkErrors {
+		return false
+	}
+	if len(another.RetryStatusCodes) != len(args.RetryStatusCodes) {
+		return false
+	}
+	for i, code := range another.RetryStatusCodes {
+		if code != args.RetryStatusCodes[i] {
+			return false
+		}
+	}
+	return true
+}
+
 //DataArgs 代表数据相关的参数容器
 type DataArgs struct {
 	// ReqBufferCap 代表请求缓冲器的容量。
 	ReqBufferCap uint32 `json:"req_buffer_cap"`
 	// ReqMaxBufferNumber 代表请求缓冲器的最大数量。
 	ReqMaxBufferNumber uint32 `json:"req_max_buffer_number"`
+	// ReqPoolOrder 代表请求缓冲池取出请求的顺序，为空时先进先出。
+	ReqPoolOrder string `json:"req_pool_order"`
+	// ReqLess 代表自定义的请求顺序，返回true时a先于b取出。
+	// 不为nil时优先于ReqPoolOrder。
+	ReqLess func(a, b *module.Request) bool `json:"-"`
 	// RespBufferCap 代表响应缓冲器的容量。
 	RespBufferCap uint32 `json:"resp_buffer_cap"`
 	// RespMaxBufferNumber 代表响应缓冲器的最大数量。
@@ -63,6 +245,18 @@ type DataArgs struct {
 	ErrorBufferCap uint32 `json:"error_buffer_cap"`
 	// ErrorMaxBufferNumber 代表错误缓冲器的最大数量。
 	ErrorMaxBufferNumber uint32 `json:"error_max_buffer_number"`
+	// CheckpointPath 代表检查点文件的路径，为空时不使用文件存储。
+	CheckpointPath string `json:"checkpoint_path"`
+	// CheckpointInterval 代表自动保存检查点的间隔，单位为毫秒，为0时只在停止时保存。
+	CheckpointInterval uint32 `json:"checkpoint_interval"`
+	// FrontierStore 代表自定义的爬取边界存储，优先于CheckpointPath。
+	FrontierStore FrontierStore `json:"-"`
+	// Deduper 代表链接去重器的类型，可选map或bloom，为空时使用map。
+	Deduper string `json:"deduper"`
+	// BloomExpected 代表布隆过滤器预计容纳的链接数量。
+	BloomExpected uint64 `json:"bloom_expected"`
+	// BloomFalsePositive 代表布隆过滤器的误判率，为0时使用默认值。
+	BloomFalsePositive float64 `json:"bloom_false_positive"`
 }
 
 
@@ -74,6 +268,10 @@ func (args *DataArgs) Check() error {
 	if args.ReqMaxBufferNumber == 0 {
 		return genError("请求缓冲器最大限制为空")
 	}
+	if _, ok := reqOrderLessMap[args.ReqPoolOrder]; !ok &&
+		args.ReqPoolOrder != "" && args.ReqPoolOrder != REQ_ORDER_FIFO {
+		return genError(fmt.Sprintf("不支持的请求顺序: %s", args.ReqPoolOrder))
+	}
 	if args.RespBufferCap == 0 {
 		return genError("响应缓冲器容量为空")
 	}
@@ -92,15 +290,58 @@ func (args *DataArgs) Check() error {
 	if args.ErrorMaxBufferNumber == 0 {
 		return genError("错误缓冲器最大限制为空")
 	}
+	if args.CheckpointInterval > 0 &&
+		args.CheckpointPath == "" && args.FrontierStore == nil {
+		return genError("设置了检查点间隔但没有检查点存储")
+	}
+	switch args.Deduper {
+	case "", dedup.TYPE_MAP:
+	case dedup.TYPE_BLOOM:
+		if args.BloomExpected == 0 {
+			return genError("布隆过滤器的预计容量为空")
+		}
+		if args.BloomFalsePositive < 0 || args.BloomFalsePositive >= 1 {
+			return genError("布隆过滤器的误判率不合法")
+		}
+	default:
+		return genError(fmt.Sprintf("不支持的去重器类型: %s", args.Deduper))
+	}
 	return nil
 }
 
+//检查当前的数据参数和另外一个是否一致
+//自定义的请求顺序无法比较，只比较是否设置了它
+func (args *DataArgs) Same(another *DataArgs) bool {
+	if another == nil {
+		return false
+	}
+	return another.ReqBufferCap == args.ReqBufferCap &&
+		another.ReqMaxBufferNumber == args.ReqMaxBufferNumber &&
+		another.ReqPoolOrder == args.ReqPoolOrder &&
+		(another.ReqLess == nil) == (args.ReqLess == nil) &&
+		another.RespBufferCap == args.RespBufferCap &&
+		another.RespMaxBufferNumber == args.RespMaxBufferNumber &&
+		another.ItemBufferCap == args.ItemBufferCap &&
+		another.ItemMaxBufferNumber == args.ItemMaxBufferNumber &&
+		another.ErrorBufferCap == args.ErrorBufferCap &&
+		another.ErrorMaxBufferNumber == args.ErrorMaxBufferNumber &&
+		another.CheckpointPath == args.CheckpointPath &&
+		another.CheckpointInterval == args.CheckpointInterval &&
+		another.FrontierStore == args.FrontierStore &&
+		another.Deduper == args.Deduper &&
+		another.BloomExpected == args.BloomExpected &&
+		another.BloomFalsePositive == args.BloomFalsePositive
+}
+
 
 //信息结构体
 type ModuleArgsSummary struct {
 	DownloaderListSize int `json:"downloader_list_size"`
 	AnalyzerListSize int `json:"analyzer_list_size"`
 	PipelineListSize int `json:"pipeline_list_size"`
+	Health HealthArgs `json:"health"`
+	Selectors SelectorArgs `json:"selectors"`
+	Weights map[module.MID]uint32 `json:"weights,omitempty"`
 }
 
 
@@ -112,6 +353,13 @@ type ModuleArgs struct {
 	Analyzers []module.Analyzer
 	// Pipelines 代表条目处理管道管道列表。
 	Pipelines []module.Pipeline
+	// Health 代表组件健康检查的参数。
+	Health HealthArgs
+	// Selectors 代表各类型组件的选择策略。
+	Selectors SelectorArgs
+	// Weights 代表组件的权重，键为组件ID，按权重随机选择组件时使用。
+	// 组件需要实现module.Weighted接口，未指定权重的组件保持原有的权重。
+	Weights map[module.MID]uint32
 }
 
 // Check 用于当前参数容器的有效性。
@@ -125,15 +373,199 @@ func (args *ModuleArgs) Check() error {
 	if len(args.Pipelines) == 0 {
 		return genError("empty pipeline list")
 	}
+	if err := args.Selectors.Check(); err != nil {
+		return err
+	}
+	if err := args.checkWeights(); err != nil {
+		return err
+	}
+	return args.Health.Check()
+}
+
+// modules 用于获取参数中的全部组件，键为组件ID。
+func (args *ModuleArgs) modules() map[module.MID]module.Module {
+	modules := map[module.MID]module.Module{}
+	for _, d := range args.Downloaders {
+		if d != nil {
+			modules[d.ID()] = d
+		}
+	}
+	for _, a := range args.Analyzers {
+		if a != nil {
+			modules[a.ID()] = a
+		}
+	}
+	for _, p := range args.Pipelines {
+		if p != nil {
+			modules[p.ID()] = p
+		}
+	}
+	return modules
+}
+
+// checkWeights 用于检查组件的权重。
+func (args *ModuleArgs) checkWeights() error {
+	modules := args.modules()
+	for mid, weight := range args.Weights {
+		if weight == 0 {
+			return genError(fmt.Sprintf("zero weight for module %s", mid))
+		}
+		m, ok := modules[mid]
+		if !ok {
+			return genError(fmt.Sprintf("weight for unknown module %s", mid))
+		}
+		if _, ok := m.(module.Weighted); !ok {
+			return genError(fmt.Sprintf("module %s does not support weight", mid))
+		}
+	}
 	return nil
 }
 
+// applyWeights 用于为组件设置权重，参数需要已经通过检查。
+func (args *ModuleArgs) applyWeights() {
+	modules := args.modules()
+	for mid, weight := range args.Weights {
+		modules[mid].(module.Weighted).SetWeight(weight)
+	}
+}
+
 func (args *ModuleArgs) Summary() ModuleArgsSummary {
 	return ModuleArgsSummary{
 		DownloaderListSize: len(args.Downloaders),
 		AnalyzerListSize:   len(args.Analyzers),
 		PipelineListSize:   len(args.Pipelines),
+		Health:             args.Health,
+		Selectors:          args.Selectors,
+		Weights:            args.Weights,
+	}
+}
+
+// Same 用于判断当前的组件参数摘要与另一个是否相同。
+func (summary *ModuleArgsSummary) Same(another *ModuleArgsSummary) bool {
+	if another == nil {
+		return false
+	}
+	if another.DownloaderListSize != summary.DownloaderListSize ||
+		another.AnalyzerListSize != summary.AnalyzerListSize ||
+		another.PipelineListSize != summary.PipelineListSize ||
+		another.Health != summary.Health ||
+		another.Selectors != summary.Selectors ||
+		len(another.Weights) != len(summary.Weights) {
+		return false
+	}
+	for mid, weight := range summary.Weights {
+		if w, ok := another.Weights[mid]; !ok || w != weight {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectorArgs 代表各类型组件的选择策略，可选值见module.NewSelector，为空时选择评分最低的组件。
+type SelectorArgs struct {
+	Downloader string `json:"downloader"`
+	Analyzer   string `json:"analyzer"`
+	Pipeline   string `json:"pipeline"`
+}
+
+func (args *SelectorArgs) Check() error {
+	for _, name := range []string{args.Downloader, args.Analyzer, args.Pipeline} {
+		if _, err := module.NewSelector(name); err != nil {
+			return genErrorByError(err)
+		}
+	}
+	return nil
+}
+
+// apply 用于为注册器设置各类型组件的选择策略。
+func (args *SelectorArgs) apply(registrar module.Registrar) error {
+	names := map[module.Type]string{
+		module.TYPE_DOWNLOADER: args.Downloader,
+		module.TYPE_ANALYZER:   args.Analyzer,
+		module.TYPE_PIPELINE:   args.Pipeline,
+	}
+	for mType, name := range names {
+		selector, err := module.NewSelector(name)
+		if err != nil {
+			return genErrorByError(err)
+		}
+		if err = registrar.SetSelector(mType, selector); err != nil {
+			return genErrorByError(err)
+		}
+	}
+	return nil
+}
+
+// HealthArgs 代表组件健康检查的参数。
+// 错误率或平均耗时超过上限的组件会被注销，冷却之后再重新注册。
+// 同一类型的最后一个组件不会被注销。
+type HealthArgs struct {
+	// MaxErrorRate 代表最近调用中出错的比例上限，取值范围为[0, 1]，为0时不检查。
+	MaxErrorRate float64 `json:"max_error_rate"`
+	// MaxLatency 代表最近调用的平均耗时上限，单位为毫秒，为0时不检查。
+	MaxLatency uint32 `json:"max_latency"`
+	// Window 代表参与统计的最近调用的次数，为0时使用默认值。
+	Window uint32 `json:"window"`
+	// MinCalls 代表至少要有多少次调用才进行判断，为0时与Window相同。
+	MinCalls uint32 `json:"min_calls"`
+	// CheckInterval 代表检查的间隔，单位为毫秒，为0时使用默认值。
+	CheckInterval uint32 `json:"check_interval"`
+	// Cooldown 代表被注销的组件多久之后重新注册，单位为毫秒，为0时使用默认值。
+	Cooldown uint32 `json:"cooldown"`
+}
+
+// 组件健康检查参数的默认值。
+const (
+	defaultHealthWindow        = 20
+	defaultHealthCheckInterval = time.Second
+	defaultHealthCooldown      = time.Minute
+)
+
+func (args *HealthArgs) Check() error {
+	if args.MaxErrorRate < 0 || args.MaxErrorRate > 1 {
+		return genError("组件错误率的上限必须在0和1之间")
+	}
+	if args.MinCalls > args.window() {
+		return genError("组件健康检查的最少调用次数大于统计的调用次数")
+	}
+	return nil
+}
+
+// enabled 用于判断是否需要进行健康检查。
+func (args *HealthArgs) enabled() bool {
+	return args.MaxErrorRate > 0 || args.MaxLatency > 0
+}
+
+// window 用于获取参与统计的调用次数。
+func (args *HealthArgs) window() uint32 {
+	if args.Window == 0 {
+		return defaultHealthWindow
+	}
+	return args.Window
+}
+
+// minCalls 用于获取进行判断所需的最少调用次数。
+func (args *HealthArgs) minCalls() uint32 {
+	if args.MinCalls == 0 {
+		return args.window()
+	}
+	return args.MinCalls
+}
+
+// checkInterval 用于获取检查的间隔。
+func (args *HealthArgs) checkInterval() time.Duration {
+	if args.CheckInterval == 0 {
+		return defaultHealthCheckInterval
+	}
+	return time.Duration(args.CheckInterval) * time.Millisecond
+}
+
+// cooldown 用于获取被注销的组件重新注册前的冷却时间。
+func (args *HealthArgs) cooldown() time.Duration {
+	if args.Cooldown == 0 {
+		return defaultHealthCooldown
 	}
+	return time.Duration(args.Cooldown) * time.Millisecond
 }
 
 
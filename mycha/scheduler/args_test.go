@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"mycha/module"
+	"testing"
+)
+
+// weightedDownloader 代表测试用的带有权重的下载器。
+type weightedDownloader struct {
+	fakeDownloader
+	weight uint32
+}
+
+func (d *weightedDownloader) Weight() uint32          { return d.weight }
+func (d *weightedDownloader) SetWeight(weight uint32) { d.weight = weight }
+
+func TestModuleArgsWeights(t *testing.T) {
+	_, _, moduleArgs, _ := newTestArgs()
+	d := &weightedDownloader{fakeDownloader: fakeDownloader{fakeModule: fakeModule{mid: "D2"}}, weight: 1}
+	moduleArgs.Downloaders = append(moduleArgs.Downloaders, d)
+	for _, weights := range []map[module.MID]uint32{
+		{"D2": 0},
+		{"D9": 2},
+		// D1没有实现module.Weighted接口。
+		{"D1": 2},
+	} {
+		moduleArgs.Weights = weights
+		if err := moduleArgs.Check(); err == nil {
+			t.Fatalf("No error when checking module args with weights %v", weights)
+		}
+	}
+	moduleArgs.Weights = map[module.MID]uint32{"D2": 5}
+	if err := moduleArgs.Check(); err != nil {
+		t.Fatalf("An error occurs when checking module args: %s", err)
+	}
+	moduleArgs.applyWeights()
+	if d.weight != 5 {
+		t.Fatalf("Inconsistent weight: expected: %d, actual: %d", 5, d.weight)
+	}
+	summary := moduleArgs.Summary()
+	another := moduleArgs.Summary()
+	if !summary.Same(&another) {
+		t.Fatalf("Module args summary %+v and its copy are not the same", summary)
+	}
+	another.Weights = map[module.MID]uint32{"D2": 4}
+	if summary.Same(&another) {
+		t.Fatalf("Module args summaries with different weights are the same")
+	}
+}
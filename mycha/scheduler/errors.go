@@ -3,7 +3,6 @@ package scheduler
 import (
 	"mycha/errors"
 	"mycha/module"
-	"mycha/tool/buffer"
 )
 
 //生产爬虫错误  传递字符串
@@ -23,34 +22,16 @@ func genParameterError(errMsg string) error {
 
 
 
-//发送错误到缓存池子中
-func sendError(err error, mid module.MID,errorBufferPool buffer.Pool) bool {
+//发送错误到缓存池子中 同时记录到最近错误中
+//记录不依赖ErrorChan是否有人接收
+func (sched *myScheduler) sendError(err error, mid module.MID) bool {
+	errorBufferPool := sched.errorBufferPool
 	if err == nil || errorBufferPool == nil || errorBufferPool.Closed() {
 		return false
 	}
-	var crawlerError errors.CrawlerError
-	var ok bool
-	crawlerError,ok = err.(errors.CrawlerError)
-	if !ok {
-		var moduleType module.Type
-		var errorType errors.ErrorType
-		ok,moduleType = module.GetType(mid)
-		if !ok {
-			errorType = errors.ERROR_TYPE_SCHEDULER
-		} else {
-			switch moduleType {
-			case module.TYPE_DOWNLOADER:
-				errorType = errors.ERROR_TYPE_DOWNLOADER
-			case module.TYPE_PIPELINE:
-				errorType = errors.ERROR_TYPE_PIPELINE
-			case module.TYPE_ANALYZER:
-				errorType = errors.ERROR_TYPE_ANALYZER
-			}
-		}
-		crawlerError = errors.NewCrawlerError(errorType,err.Error())
-	}
-	if errorBufferPool.Closed() {
-		return false
+	crawlerError := toCrawlerError(err, mid)
+	if sched.errorRecorder != nil {
+		sched.errorRecorder.record(crawlerError)
 	}
 	go func(crawlerError errors.CrawlerError) {
 		if err := errorBufferPool.Put(crawlerError); err != nil {
@@ -60,6 +41,25 @@ func sendError(err error, mid module.MID,errorBufferPool buffer.Pool) bool {
 	return true
 }
 
+//把错误转换为爬虫错误 错误类型按组件的类型确定
+func toCrawlerError(err error, mid module.MID) errors.CrawlerError {
+	if crawlerError, ok := err.(errors.CrawlerError); ok {
+		return crawlerError
+	}
+	errorType := errors.ERROR_TYPE_SCHEDULER
+	if ok, moduleType := module.GetType(mid); ok {
+		switch moduleType {
+		case module.TYPE_DOWNLOADER:
+			errorType = errors.ERROR_TYPE_DOWNLOADER
+		case module.TYPE_PIPELINE:
+			errorType = errors.ERROR_TYPE_PIPELINE
+		case module.TYPE_ANALYZER:
+			errorType = errors.ERROR_TYPE_ANALYZER
+		}
+	}
+	return errors.NewCrawlerError(errorType, err.Error())
+}
+
 
 
 
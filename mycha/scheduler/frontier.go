@@ -0,0 +1,332 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"mycha/module"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FrontierEntry 代表检查点中一个待处理的请求。
+// 请求的父请求不会被保存，还原后的请求的父请求为nil。
+type FrontierEntry struct {
+	URL      string                 `json:"url"`
+	Method   string                 `json:"method"`
+	Header   http.Header            `json:"header,omitempty"`
+	Body     []byte                 `json:"body,omitempty"`
+	Depth    uint32                 `json:"depth"`
+	Attempt  uint32                 `json:"attempt,omitempty"`
+	Priority int                    `json:"priority,omitempty"`
+	Callback string                 `json:"callback,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Item     module.Item            `json:"item,omitempty"`
+}
+
+// newFrontierEntry 用于根据请求生成检查点条目。
+func newFrontierEntry(req *module.Request) FrontierEntry {
+	httpReq := req.HTTPReq()
+	entry := FrontierEntry{
+		URL:      httpReq.URL.String(),
+		Method:   httpReq.Method,
+		Header:   httpReq.Header,
+		Depth:    req.Depth(),
+		Attempt:  req.Attempt(),
+		Priority: req.Priority(),
+		Callback: req.Callback(),
+		Meta:     req.Meta(),
+		Item:     req.Item(),
+		Body:     requestBody(httpReq),
+	}
+	return entry
+}
+
+// requestBody 用于获取请求体的副本，无法重新获取请求体时返回nil。
+func requestBody(httpReq *http.Request) []byte {
+	if httpReq.GetBody == nil {
+		return nil
+	}
+	body, err := httpReq.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	b, _ := ioutil.ReadAll(body)
+	return b
+}
+
+// frontierKey 用于生成请求在爬取边界中的键，由请求方法、链接和请求体的哈希值组成。
+// 这样对同一链接的不同请求，比如请求体不同的POST请求，不会互相覆盖。
+func frontierKey(method string, rawURL string, body []byte) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	key := method + " " + rawURL
+	if len(body) > 0 {
+		h := fnv.New64a()
+		h.Write(body)
+		key += fmt.Sprintf(" %016x", h.Sum64())
+	}
+	return key
+}
+
+// requestKey 用于获取请求在爬取边界中的键，见frontierKey。
+func requestKey(req *module.Request) string {
+	httpReq := req.HTTPReq()
+	return frontierKey(httpReq.Method, httpReq.URL.String(), requestBody(httpReq))
+}
+
+// Request 用于把检查点条目还原为请求。
+// 元数据和条目中的json.Number会被还原为数字，见restoreNumber。
+func (entry FrontierEntry) Request() (*module.Request, error) {
+	method := entry.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if len(entry.Body) > 0 {
+		body = bytes.NewReader(entry.Body)
+	}
+	httpReq, err := http.NewRequest(method, entry.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range entry.Header {
+		for _, value := range values {
+			httpReq.Header.Add(key, value)
+		}
+	}
+	req := module.NewRequest(httpReq, entry.Depth)
+	req.SetAttempt(entry.Attempt)
+	req.SetPriority(entry.Priority)
+	req.SetCallback(entry.Callback)
+	if entry.Item != nil {
+		item := module.Item{}
+		for key, value := range entry.Item {
+			item[key] = restoreNumber(value)
+		}
+		req.SetItem(item)
+	}
+	for key, value := range entry.Meta {
+		req.SetMeta(key, restoreNumber(value))
+	}
+	return req, nil
+}
+
+// restoreNumber 用于把JSON解码得到的json.Number还原为数字，
+// 整数形式的还原为int，其他的还原为float64，映射和切片中的值也会被还原。
+// 这样元数据中的整数在保存和恢复之后仍然是int，而不会变为float64。
+func restoreNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := strconv.Atoi(v.String()); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = restoreNumber(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = restoreNumber(elem)
+		}
+	}
+	return value
+}
+
+// FrontierSnapshot 代表爬取边界的快照，即检查点的内容。
+type FrontierSnapshot struct {
+	// CreatedAt 代表快照的生成时间。
+	CreatedAt time.Time `json:"created_at"`
+	// AcceptedDomains 代表已接受的主域名列表。
+	AcceptedDomains []string `json:"accepted_domains"`
+	// Pending 代表尚未处理完毕的请求。
+	Pending []FrontierEntry `json:"pending"`
+	// Deduper 代表链接去重器的类型。
+	Deduper string `json:"deduper"`
+	// DedupState 代表链接去重器导出的状态，即已经见过的链接。
+	DedupState []byte `json:"dedup_state"`
+}
+
+// FrontierStore 代表爬取边界存储的接口类型。
+type FrontierStore interface {
+	// Save 用于保存快照。
+	Save(snapshot *FrontierSnapshot) error
+	// Load 用于读取最近一次保存的快照。
+	Load() (*FrontierSnapshot, error)
+}
+
+// NewFileFrontierStore 用于创建一个基于文件的爬取边界存储。
+func NewFileFrontierStore(path string) (FrontierStore, error) {
+	if path == "" {
+		return nil, genParameterError("空的检查点文件路径")
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	return &fileFrontierStore{path: absPath}, nil
+}
+
+// fileFrontierStore 代表基于文件的爬取边界存储的实现类型。
+type fileFrontierStore struct {
+	path string
+	lock sync.Mutex
+}
+
+// Save 会先写入临时文件再重命名，以免写到一半时崩溃损坏已有的检查点。
+func (store *fileFrontierStore) Save(snapshot *FrontierSnapshot) error {
+	if snapshot == nil {
+		return genParameterError("空的检查点快照")
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if err = os.MkdirAll(filepath.Dir(store.path), 0700); err != nil {
+		return genErrorByError(err)
+	}
+	tmpPath := store.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	if _, err = file.Write(b); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return genErrorByError(err)
+	}
+	if err = os.Rename(tmpPath, store.path); err != nil {
+		return genErrorByError(err)
+	}
+	return nil
+}
+
+func (store *fileFrontierStore) Load() (*FrontierSnapshot, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	b, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	snapshot := &FrontierSnapshot{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	// 保留数字的原样，以便把元数据中的整数还原为int。
+	decoder.UseNumber()
+	if err = decoder.Decode(snapshot); err != nil {
+		errMsg := fmt.Sprintf("无法解析检查点文件 %s: %s", store.path, err)
+		return nil, genError(errMsg)
+	}
+	return snapshot, nil
+}
+
+// frontier 代表调度器内部对待处理请求的记录。
+// 缓冲池本身无法被遍历，所以需要单独记录一份用于生成快照。
+// 已见过的链接由去重器负责导出。
+// 请求在它的响应被分析完、由此得到的条目都被处理完之后才算处理完毕，
+// 这样在此之前停止或崩溃时，请求会在恢复后被重新下载。
+type frontier struct {
+	lock    sync.Mutex
+	domains map[string]struct{}
+	// pending 代表待处理的请求，键见frontierKey。
+	pending map[string]FrontierEntry
+	// holds 代表每个请求还未处理完的数据的数量，包括请求本身或其响应，以及得到的条目。
+	holds map[string]int
+}
+
+func newFrontier() *frontier {
+	return &frontier{
+		domains: map[string]struct{}{},
+		pending: map[string]FrontierEntry{},
+		holds:   map[string]int{},
+	}
+}
+
+// addDomain 用于记录一个已接受的主域名。
+func (f *frontier) addDomain(domain string) {
+	f.lock.Lock()
+	f.domains[domain] = struct{}{}
+	f.lock.Unlock()
+}
+
+// add 用于记录一个刚被放入请求缓冲池的请求。
+// 重新放入的同一个请求，比如重试的请求，会替换之前的记录。
+func (f *frontier) add(req *module.Request) {
+	entry := newFrontierEntry(req)
+	key := frontierKey(entry.Method, entry.URL, entry.Body)
+	f.lock.Lock()
+	f.pending[key] = entry
+	f.holds[key] = 1
+	f.lock.Unlock()
+}
+
+// hold 用于记录一个由请求得到的、还未处理完的数据，比如条目。
+// 每次调用都需要对应一次done。
+func (f *frontier) hold(req *module.Request) {
+	if req == nil || !req.Valid() {
+		return
+	}
+	key := requestKey(req)
+	f.lock.Lock()
+	if _, ok := f.pending[key]; ok {
+		f.holds[key]++
+	}
+	f.lock.Unlock()
+}
+
+// done 用于把请求本身或由它得到的一个数据标记为已处理，
+// 都处理完之后请求会从记录中移除。
+func (f *frontier) done(req *module.Request) {
+	if req == nil || !req.Valid() {
+		return
+	}
+	key := requestKey(req)
+	f.lock.Lock()
+	if f.holds[key]--; f.holds[key] <= 0 {
+		delete(f.pending, key)
+		delete(f.holds, key)
+	}
+	f.lock.Unlock()
+}
+
+// len 用于获取待处理请求的数量。
+func (f *frontier) len() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.pending)
+}
+
+// snapshot 用于生成当前的快照。
+func (f *frontier) snapshot() *FrontierSnapshot {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	snapshot := &FrontierSnapshot{
+		CreatedAt:       time.Now(),
+		AcceptedDomains: make([]string, 0, len(f.domains)),
+		Pending:         make([]FrontierEntry, 0, len(f.pending)),
+	}
+	for domain := range f.domains {
+		snapshot.AcceptedDomains = append(snapshot.AcceptedDomains, domain)
+	}
+	for _, entry := range f.pending {
+		snapshot.Pending = append(snapshot.Pending, entry)
+	}
+	return snapshot
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"mycha/module"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func newTestRequest(t *testing.T, rawURL string) *module.Request {
+	httpReq, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when new HTTP request: %s", err)
+	}
+	return module.NewRequest(httpReq, 1)
+}
+
+func TestFrontierHold(t *testing.T) {
+	f := newFrontier()
+	req := newTestRequest(t, "http://example.com/a")
+	f.add(req)
+	// 一个响应得到了两个条目。
+	f.hold(req)
+	f.hold(req)
+	steps := []int{1, 1, 0}
+	for i, expected := range steps {
+		f.done(req)
+		if f.len() != expected {
+			t.Fatalf("Inconsistent frontier length after done[%d]: expected: %d, actual: %d",
+				i, expected, f.len())
+		}
+	}
+	// 不在记录中的请求不能被持有。
+	f.hold(req)
+	if f.len() != 0 {
+		t.Fatalf("Request is held after being done")
+	}
+	// 重新放入的请求替换之前的记录。
+	f.add(req)
+	f.hold(req)
+	f.add(req)
+	f.done(req)
+	if f.len() != 0 {
+		t.Fatalf("Inconsistent frontier length after re-adding: %d", f.len())
+	}
+}
+
+func TestFrontierSameURL(t *testing.T) {
+	f := newFrontier()
+	var reqs []*module.Request
+	for _, body := range []string{"", "a=1", "a=2"} {
+		method := http.MethodPost
+		if body == "" {
+			method = http.MethodGet
+		}
+		httpReq, err := http.NewRequest(method, "http://example.com/search", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("An error occurs when new HTTP request: %s", err)
+		}
+		req := module.NewRequest(httpReq, 1)
+		reqs = append(reqs, req)
+		f.add(req)
+	}
+	// 链接相同而请求方法或请求体不同的请求不会互相覆盖。
+	if f.len() != len(reqs) {
+		t.Fatalf("Inconsistent frontier length: expected: %d, actual: %d", len(reqs), f.len())
+	}
+	f.done(reqs[1])
+	var bodies []string
+	for _, entry := range f.snapshot().Pending {
+		bodies = append(bodies, entry.Method+":"+string(entry.Body))
+	}
+	sort.Strings(bodies)
+	if strings.Join(bodies, ",") != "GET:,POST:a=2" {
+		t.Fatalf("Inconsistent pending requests: %v", bodies)
+	}
+}
+
+func TestFileFrontierStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileFrontierStore(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		t.Fatalf("An error occurs when new file frontier store: %s", err)
+	}
+	req := newTestRequest(t, "http://example.com/list?page=2")
+	req, _ = req.Retry()
+	req.SetPriority(3)
+	req.SetCallback("parseList")
+	req.SetMeta("page", 2)
+	req.SetMeta("ratio", 0.5)
+	req.SetMeta("tags", []interface{}{"a", 1})
+	req.SetMeta("nested", map[string]interface{}{"n": 7})
+	req.SetItem(module.Item{"count": 10})
+	f := newFrontier()
+	f.addDomain("example.com")
+	f.add(req)
+	if err = store.Save(f.snapshot()); err != nil {
+		t.Fatalf("An error occurs when saving snapshot: %s", err)
+	}
+	snapshot, err := store.Load()
+	if err != nil {
+		t.Fatalf("An error occurs when loading snapshot: %s", err)
+	}
+	if len(snapshot.Pending) != 1 ||
+		!reflect.DeepEqual(snapshot.AcceptedDomains, []string{"example.com"}) {
+		t.Fatalf("Inconsistent snapshot: %+v", snapshot)
+	}
+	restored, err := snapshot.Pending[0].Request()
+	if err != nil {
+		t.Fatalf("An error occurs when restoring request: %s", err)
+	}
+	if restored.HTTPReq().URL.String() != req.HTTPReq().URL.String() ||
+		restored.Depth() != req.Depth() || restored.Attempt() != 1 ||
+		restored.Priority() != 3 || restored.Callback() != "parseList" {
+		t.Fatalf("Inconsistent restored request: %+v", snapshot.Pending[0])
+	}
+	expectedMeta := map[string]interface{}{
+		"page":   2,
+		"ratio":  0.5,
+		"tags":   []interface{}{"a", 1},
+		"nested": map[string]interface{}{"n": 7},
+	}
+	if !reflect.DeepEqual(restored.Meta(), expectedMeta) {
+		t.Fatalf("Inconsistent meta: expected: %#v, actual: %#v", expectedMeta, restored.Meta())
+	}
+	if !reflect.DeepEqual(restored.Item(), module.Item{"count": 10}) {
+		t.Fatalf("Inconsistent item: %#v", restored.Item())
+	}
+}
@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"fmt"
+	"mycha/module"
+	"sort"
+	"sync"
+	"time"
+)
+
+// callOutcome 代表一次组件调用的结果。
+type callOutcome struct {
+	failed  bool
+	latency time.Duration
+}
+
+// healthStats 代表某个组件最近若干次调用的统计。
+type healthStats struct {
+	outcomes []callOutcome
+	// next 代表下一次调用结果写入的位置。
+	next int
+	// count 代表已记录的调用次数，不超过outcomes的长度。
+	count int
+}
+
+func (stats *healthStats) add(outcome callOutcome) {
+	stats.outcomes[stats.next] = outcome
+	stats.next = (stats.next + 1) % len(stats.outcomes)
+	if stats.count < len(stats.outcomes) {
+		stats.count++
+	}
+}
+
+// rates 用于计算错误率和平均耗时。
+func (stats *healthStats) rates() (errorRate float64, avgLatency time.Duration) {
+	if stats.count == 0 {
+		return 0, 0
+	}
+	var failed int
+	var total time.Duration
+	for _, outcome := range stats.outcomes[:stats.count] {
+		if outcome.failed {
+			failed++
+		}
+		total += outcome.latency
+	}
+	return float64(failed) / float64(stats.count), total / time.Duration(stats.count)
+}
+
+// evictedModule 代表因不健康而被注销的组件。
+type evictedModule struct {
+	module module.Module
+	since  time.Time
+	reason string
+}
+
+// EvictedModuleStruct 代表被注销的组件的摘要类型。
+type EvictedModuleStruct struct {
+	ID     module.MID `json:"id"`
+	Since  time.Time  `json:"since"`
+	Until  time.Time  `json:"until"`
+	Reason string     `json:"reason"`
+}
+
+// healthChecker 代表组件的健康检查器。
+type healthChecker struct {
+	args      HealthArgs
+	registrar module.Registrar
+	lock      sync.Mutex
+	stats     map[module.MID]*healthStats
+	evicted   map[module.MID]*evictedModule
+}
+
+func newHealthChecker(args HealthArgs, registrar module.Registrar) *healthChecker {
+	return &healthChecker{
+		args:      args,
+		registrar: registrar,
+		stats:     map[module.MID]*healthStats{},
+		evicted:   map[module.MID]*evictedModule{},
+	}
+}
+
+// record 用于记录一次组件调用的结果。
+func (checker *healthChecker) record(mid module.MID, latency time.Duration, failed bool) {
+	if !checker.args.enabled() {
+		return
+	}
+	checker.lock.Lock()
+	defer checker.lock.Unlock()
+	stats, ok := checker.stats[mid]
+	if !ok {
+		stats = &healthStats{outcomes: make([]callOutcome, checker.args.window())}
+		checker.stats[mid] = stats
+	}
+	stats.add(callOutcome{failed: failed, latency: latency})
+}
+
+// forget 用于清除某个组件的统计和注销记录。
+// 返回的结果代表该组件是否处于被注销的状态。
+func (checker *healthChecker) forget(mid module.MID) bool {
+	checker.lock.Lock()
+	defer checker.lock.Unlock()
+	delete(checker.stats, mid)
+	_, evicted := checker.evicted[mid]
+	delete(checker.evicted, mid)
+	return evicted
+}
+
+// check 用于注销不健康的组件，并重新注册冷却完毕的组件。
+func (checker *healthChecker) check(now time.Time) {
+	checker.lock.Lock()
+	defer checker.lock.Unlock()
+	for mid, evicted := range checker.evicted {
+		if now.Sub(evicted.since) < checker.args.cooldown() {
+			continue
+		}
+		delete(checker.evicted, mid)
+		delete(checker.stats, mid)
+		if _, err := checker.registrar.Register(evicted.module); err != nil {
+			logger.Errorf("重新注册组件 %s 失败: %s", mid, err)
+			continue
+		}
+		logger.Infof("组件 %s 冷却完毕，重新注册", mid)
+	}
+	for mid, stats := range checker.stats {
+		if uint32(stats.count) < checker.args.minCalls() {
+			continue
+		}
+		reason := checker.unhealthyReason(stats)
+		if reason == "" {
+			continue
+		}
+		m, ok := checker.lastOfItsType(mid)
+		if m == nil || ok {
+			continue
+		}
+		if deleted, _ := checker.registrar.Unregister(mid); !deleted {
+			continue
+		}
+		checker.evicted[mid] = &evictedModule{module: m, since: now, reason: reason}
+		delete(checker.stats, mid)
+		logger.Warnf("组件 %s 不健康(%s)，注销%s", mid, reason, checker.args.cooldown())
+	}
+}
+
+// unhealthyReason 用于判断组件是否健康，不健康时返回原因。
+func (checker *healthChecker) unhealthyReason(stats *healthStats) string {
+	errorRate, avgLatency := stats.rates()
+	if checker.args.MaxErrorRate > 0 && errorRate > checker.args.MaxErrorRate {
+		return fmt.Sprintf("错误率 %.2f 超过 %.2f", errorRate, checker.args.MaxErrorRate)
+	}
+	maxLatency := time.Duration(checker.args.MaxLatency) * time.Millisecond
+	if maxLatency > 0 && avgLatency > maxLatency {
+		return fmt.Sprintf("平均耗时 %s 超过 %s", avgLatency, maxLatency)
+	}
+	return ""
+}
+
+// lastOfItsType 用于获取已注册的组件，并判断它是否是同类型中的最后一个。
+// 组件未注册时返回nil。
+func (checker *healthChecker) lastOfItsType(mid module.MID) (module.Module, bool) {
+	for _, mType := range []module.Type{
+		module.TYPE_DOWNLOADER, module.TYPE_ANALYZER, module.TYPE_PIPELINE} {
+		modules, _ := checker.registrar.GetAllByType(mType)
+		if m, ok := modules[mid]; ok {
+			return m, len(modules) <= 1
+		}
+	}
+	return nil, false
+}
+
+// evictedSummary 用于获取被注销的组件的摘要。
+func (checker *healthChecker) evictedSummary() []EvictedModuleStruct {
+	checker.lock.Lock()
+	defer checker.lock.Unlock()
+	summaries := []EvictedModuleStruct{}
+	for mid, evicted := range checker.evicted {
+		summaries = append(summaries, EvictedModuleStruct{
+			ID:     mid,
+			Since:  evicted.since,
+			Until:  evicted.since.Add(checker.args.cooldown()),
+			Reason: evicted.reason,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].ID < summaries[j].ID
+	})
+	return summaries
+}
+
+// recordCall 用于记录一次组件调用，供健康检查和组件选择策略使用。
+func (sched *myScheduler) recordCall(mid module.MID, latency time.Duration, failed bool) {
+	sched.health.record(mid, latency, failed)
+	sched.registrar.ObserveLatency(mid, latency)
+}
+
+// checkHealth 会定期检查组件的健康状况，直到调度器停止。
+func (sched *myScheduler) checkHealth() {
+	if !sched.health.args.enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(sched.health.args.checkInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sched.ctx.Done():
+				return
+			case now := <-ticker.C:
+				sched.health.check(now)
+			}
+		}
+	}()
+}
+
+// RegisterModule 用于向已初始化的调度器注册组件，调度器运行时也可以调用。
+func (sched *myScheduler) RegisterModule(m module.Module) error {
+	if sched.Status() == SCHED_STATUS_UNINITIALIZED || sched.registrar == nil {
+		return genError("调度器还没有初始化")
+	}
+	if m == nil {
+		return genParameterError("nil module")
+	}
+	// 同一个组件此前被健康检查注销的话，以这次注册为准。
+	sched.health.forget(m.ID())
+	ok, err := sched.registrar.Register(m)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	if !ok {
+		return genError(fmt.Sprintf("组件 %s 已经注册过了", m.ID()))
+	}
+	logger.Infof("注册组件 %s", m.ID())
+	return nil
+}
+
+// UnregisterModule 用于从调度器注销组件，调度器运行时也可以调用。
+// 被健康检查注销、正在冷却的组件也会被移除，不再重新注册。
+func (sched *myScheduler) UnregisterModule(mid module.MID) error {
+	if sched.Status() == SCHED_STATUS_UNINITIALIZED || sched.registrar == nil {
+		return genError("调度器还没有初始化")
+	}
+	wasEvicted := sched.health.forget(mid)
+	deleted, err := sched.registrar.Unregister(mid)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	if !deleted && !wasEvicted {
+		return genError(fmt.Sprintf("组件 %s 没有注册", mid))
+	}
+	logger.Infof("注销组件 %s", mid)
+	return nil
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"errors"
+	"mycha/module"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthStatsRates(t *testing.T) {
+	stats := &healthStats{outcomes: make([]callOutcome, 4)}
+	if rate, latency := stats.rates(); rate != 0 || latency != 0 {
+		t.Fatalf("Inconsistent rates without calls: (%v, %s)", rate, latency)
+	}
+	// 超出窗口的调用结果会被覆盖。
+	outcomes := []callOutcome{
+		{true, 100 * time.Millisecond},
+		{true, 100 * time.Millisecond},
+		{false, 10 * time.Millisecond},
+		{false, 30 * time.Millisecond},
+		{true, 20 * time.Millisecond},
+		{false, 40 * time.Millisecond},
+	}
+	for _, outcome := range outcomes {
+		stats.add(outcome)
+	}
+	rate, latency := stats.rates()
+	if rate != 0.25 || latency != 25*time.Millisecond {
+		t.Fatalf("Inconsistent rates: expected: (%v, %s), actual: (%v, %s)",
+			0.25, 25*time.Millisecond, rate, latency)
+	}
+}
+
+func TestHealthCheckerCheck(t *testing.T) {
+	registrar := module.NewRegistrar()
+	d1 := &fakeDownloader{fakeModule: fakeModule{mid: "D1"}}
+	d2 := &fakeDownloader{fakeModule: fakeModule{mid: "D2"}}
+	for _, d := range []module.Module{d1, d2} {
+		if _, err := registrar.Register(d); err != nil {
+			t.Fatalf("An error occurs when registering module: %s", err)
+		}
+	}
+	checker := newHealthChecker(HealthArgs{MaxErrorRate: 0.5, MaxLatency: 100,
+		Window: 4, Cooldown: 1000}, registrar)
+	now := time.Now()
+	type calls struct {
+		mid     module.MID
+		outcome callOutcome
+		times   int
+	}
+	steps := []struct {
+		name    string
+		calls   []calls
+		offset  time.Duration
+		evicted []module.MID
+	}{
+		// 调用次数达到窗口大小才会被判断。
+		{"too few calls", []calls{{"D2", callOutcome{true, 0}, 3}}, 0, nil},
+		{"error rate", []calls{{"D2", callOutcome{true, 0}, 1}}, 0, []module.MID{"D2"}},
+		// 同类型的最后一个组件不会被注销。
+		{"last of its type", []calls{{"D1", callOutcome{false, time.Second}, 4}}, 0, []module.MID{"D2"}},
+		{"cooling down", nil, 500 * time.Millisecond, []module.MID{"D2"}},
+		// D2重新注册后，D1不再是最后一个，它的统计仍然不健康。
+		{"readmitted", nil, time.Second, []module.MID{"D1"}},
+	}
+	for _, step := range steps {
+		for _, c := range step.calls {
+			for i := 0; i < c.times; i++ {
+				checker.record(c.mid, c.outcome.latency, c.outcome.failed)
+			}
+		}
+		checker.check(now.Add(step.offset))
+		summaries := checker.evictedSummary()
+		if len(summaries) != len(step.evicted) {
+			t.Fatalf("Inconsistent evicted modules of step %q: expected: %v, actual: %+v",
+				step.name, step.evicted, summaries)
+		}
+		for i, mid := range step.evicted {
+			if summaries[i].ID != mid || summaries[i].Reason == "" {
+				t.Fatalf("Inconsistent evicted modules of step %q: expected: %v, actual: %+v",
+					step.name, step.evicted, summaries)
+			}
+			if m, _ := checker.lastOfItsType(mid); m != nil {
+				t.Fatalf("Evicted module %s is still registered at step %q", mid, step.name)
+			}
+		}
+	}
+	if m, _ := checker.lastOfItsType("D2"); m == nil {
+		t.Fatalf("Module D2 is not registered again after cooldown")
+	}
+}
+
+// failingDownloader 代表总是返回错误的下载器。
+type failingDownloader struct{ fakeModule }
+
+func (d *failingDownloader) Download(req *module.Request) (*module.Response, error) {
+	atomic.AddUint32(&d.called, 1)
+	resp, err := http.DefaultClient.Do(req.HTTPReq())
+	if err != nil {
+		return nil, err
+	}
+	return module.NewResponseFor(resp, req), errors.New("proxy unavailable")
+}
+
+func TestSchedulerHealthEviction(t *testing.T) {
+	server := newTestServer(60)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	bad := &failingDownloader{fakeModule{mid: "D2"}}
+	moduleArgs.Downloaders = append(moduleArgs.Downloaders, bad)
+	moduleArgs.Selectors = SelectorArgs{Downloader: module.SELECTOR_LEAST_IN_FLIGHT}
+	moduleArgs.Health = HealthArgs{MaxErrorRate: 0.5, Window: 4, CheckInterval: 20, Cooldown: 60000}
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sched.Summary().Struct().Evicted) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("The failing downloader is not evicted\n%s", sched.Summary())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if evicted := sched.Summary().Struct().Evicted; evicted[0].ID != "D2" {
+		t.Fatalf("Inconsistent evicted module: expected: %s, actual: %+v", "D2", evicted)
+	}
+	called := atomic.LoadUint32(&bad.called)
+	// 失败下载的响应同样会被分析，所以每个页面都只得到一个条目。
+	waitItems(t, sched, p, 60)
+	if atomic.LoadUint32(&bad.called) != called {
+		t.Fatalf("The evicted downloader is still called")
+	}
+	// 被注销的组件可以被手动注销，之后不会再被重新注册。
+	if err := sched.UnregisterModule("D2"); err != nil {
+		t.Fatalf("An error occurs when unregistering an evicted module: %s", err)
+	}
+	if err := sched.UnregisterModule("D2"); err == nil {
+		t.Fatalf("No error when unregistering a module twice")
+	}
+	stopTestScheduler(t, sched)
+}
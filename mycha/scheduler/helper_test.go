@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mycha/module"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeModule 代表测试用的组件，只实现计数相关的方法。
+type fakeModule struct {
+	mid                                   module.MID
+	called, accepted, completed, handling uint32
+}
+
+func (m *fakeModule) ID() module.MID                         { return m.mid }
+func (m *fakeModule) Addr() string                           { return "" }
+func (m *fakeModule) Score() uint32                          { return 0 }
+func (m *fakeModule) SetScore(score uint32)                  {}
+func (m *fakeModule) ScoreCalculator() module.CalculateScore { return nil }
+func (m *fakeModule) CallCount() uint32                      { return atomic.LoadUint32(&m.called) }
+func (m *fakeModule) AcceptedCount() uint32                  { return atomic.LoadUint32(&m.accepted) }
+func (m *fakeModule) Completed() uint32                      { return atomic.LoadUint32(&m.completed) }
+func (m *fakeModule) Handling() uint32                       { return atomic.LoadUint32(&m.handling) }
+func (m *fakeModule) Counts() module.Counts {
+	return module.Counts{CallNum: m.CallCount(), AcceptedNum: m.AcceptedCount(),
+		CompletedNum: m.Completed(), HandlingNum: m.Handling()}
+}
+func (m *fakeModule) Summary() module.SummaryStruct {
+	return module.SummaryStruct{ID: m.mid, Called: m.CallCount(), Accepted: m.AcceptedCount(),
+		Completed: m.Completed(), Handling: m.Handling()}
+}
+
+// fakeDownloader 代表测试用的下载器，delay不为0时每次下载前等待相应的时长。
+type fakeDownloader struct {
+	fakeModule
+	delay time.Duration
+	// maxHandling 代表同时进行的下载数的最大值。
+	maxHandling uint32
+}
+
+func (d *fakeDownloader) Download(req *module.Request) (*module.Response, error) {
+	handling := atomic.AddUint32(&d.handling, 1)
+	defer atomic.AddUint32(&d.handling, ^uint32(0))
+	for {
+		max := atomic.LoadUint32(&d.maxHandling)
+		if handling <= max || atomic.CompareAndSwapUint32(&d.maxHandling, max, handling) {
+			break
+		}
+	}
+	atomic.AddUint32(&d.called, 1)
+	time.Sleep(d.delay)
+	resp, err := http.DefaultClient.Do(req.HTTPReq())
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint32(&d.completed, 1)
+	return module.NewResponseFor(resp, req), nil
+}
+
+var testLinkPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+// fakeAnalyzer 代表测试用的分析器，会为页面上的每个链接生成请求，并为页面生成一个条目。
+type fakeAnalyzer struct{ fakeModule }
+
+func (a *fakeAnalyzer) RespParsers() []module.ParseResponse { return nil }
+func (a *fakeAnalyzer) Analyze(resp *module.Response) ([]module.Data, []error) {
+	atomic.AddUint32(&a.handling, 1)
+	defer atomic.AddUint32(&a.handling, ^uint32(0))
+	httpResp := resp.HTTPResp()
+	defer httpResp.Body.Close()
+	b, _ := ioutil.ReadAll(httpResp.Body)
+	var data []module.Data
+	for _, m := range testLinkPattern.FindAllStringSubmatch(string(b), -1) {
+		u, err := httpResp.Request.URL.Parse(m[1])
+		if err != nil {
+			continue
+		}
+		req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+		data = append(data, resp.Follow(req))
+	}
+	data = append(data, module.Item{"url": httpResp.Request.URL.String()})
+	return data, nil
+}
+
+// fakePipeline 代表测试用的条目处理管道，只统计条目的数量。
+type fakePipeline struct {
+	fakeModule
+	items int32
+}
+
+func (p *fakePipeline) ItemProcessors() []module.ProcessItem { return nil }
+func (p *fakePipeline) Send(item module.Item) []error {
+	atomic.AddInt32(&p.items, 1)
+	return nil
+}
+func (p *fakePipeline) FailFast() bool            { return false }
+func (p *fakePipeline) SetFailFast(failFast bool) {}
+
+// newTestServer 用于创建一个有pages个页面的网站。
+func newTestServer(pages int) *httptest.Server {
+	return httptest.NewServer(testPages(pages))
+}
+
+// testPages 用于生成有pages个页面的网站的处理器，页面/p<n>链接到之后的两个页面。
+func testPages(pages int) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/p%d", &n)
+		fmt.Fprintf(w, "<html>")
+		for i := n + 1; i < pages && i < n+3; i++ {
+			fmt.Fprintf(w, `<a href="/p%d">x</a>`, i)
+		}
+		fmt.Fprintf(w, "</html>")
+	})
+}
+
+// newTestArgs 用于生成测试用的调度器参数。
+func newTestArgs() (RequestArgs, DataArgs, ModuleArgs, *fakePipeline) {
+	p := &fakePipeline{fakeModule: fakeModule{mid: "P1"}}
+	return RequestArgs{AcceptedDomains: []string{}, MaxDepth: 100},
+		DataArgs{ReqBufferCap: 50, ReqMaxBufferNumber: 100, RespBufferCap: 50, RespMaxBufferNumber: 10,
+			ItemBufferCap: 50, ItemMaxBufferNumber: 100, ErrorBufferCap: 50, ErrorMaxBufferNumber: 1},
+		ModuleArgs{
+			Downloaders: []module.Downloader{&fakeDownloader{fakeModule: fakeModule{mid: "D1"}}},
+			Analyzers:   []module.Analyzer{&fakeAnalyzer{fakeModule{mid: "A1"}}},
+			Pipelines:   []module.Pipeline{p},
+		}, p
+}
+
+// startTestScheduler 用于初始化并启动调度器，从server的/p0开始爬取。
+func startTestScheduler(t *testing.T, server *httptest.Server,
+	requestArgs RequestArgs, dataArgs DataArgs, moduleArgs ModuleArgs) scheduler {
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	first, _ := http.NewRequest(http.MethodGet, server.URL+"/p0", nil)
+	if err := sched.Start(first); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	return sched
+}
+
+// waitItems 用于等待管道收到n个条目并且调度器空闲。
+func waitItems(t *testing.T, sched scheduler, p *fakePipeline, n int32) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		if atomic.LoadInt32(&p.items) == n && sched.Idle() {
+			return
+		}
+	}
+	t.Fatalf("Inconsistent number of items: expected: %d, actual: %d\n%s",
+		n, atomic.LoadInt32(&p.items), sched.Summary())
+}
+
+// stopTestScheduler 用于停止调度器并等待其结束。
+func stopTestScheduler(t *testing.T, sched scheduler) {
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	select {
+	case <-sched.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("The done channel is not closed after stop")
+	}
+}
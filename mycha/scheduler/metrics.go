@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"bytes"
+	"mycha/helper/metrics"
+	"mycha/module"
+	"net/http"
+	"sort"
+)
+
+// otherErrorType 代表不是CrawlerError的错误在指标中的类型标签。
+const otherErrorType = "other"
+
+// NewMetricsHandler 用于创建以Prometheus文本格式输出调度器指标的HTTP处理器。
+// 各项指标，包括下载耗时和下载字节数的直方图，都是每个调度器单独记录的。
+func NewMetricsHandler(sched scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, sched); err != nil {
+			logger.Errorf("生成调度器指标时出错: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// writeMetrics 用于写入调度器的全部指标。
+func writeMetrics(buf *bytes.Buffer, sched scheduler) error {
+	if summary := sched.Summary(); summary != nil {
+		if err := writeSummaryMetrics(buf, summary.Struct()); err != nil {
+			return err
+		}
+	}
+	ms, ok := sched.(*myScheduler)
+	if !ok || ms.errorRecorder == nil {
+		return nil
+	}
+	if err := writeErrorMetrics(buf, ms.errorRecorder.countsByType()); err != nil {
+		return err
+	}
+	if err := ms.downloadLatency.Write(buf); err != nil {
+		return err
+	}
+	return ms.downloadBytes.Write(buf)
+}
+
+// writeSummaryMetrics 用于写入组件、缓冲池和链接去重器的指标。
+func writeSummaryMetrics(buf *bytes.Buffer, summary SummaryStruct) error {
+	var moduleSummaries []module.SummaryStruct
+	moduleSummaries = append(moduleSummaries, summary.Downloaders...)
+	moduleSummaries = append(moduleSummaries, summary.Analyzers...)
+	moduleSummaries = append(moduleSummaries, summary.Pipelines...)
+	moduleMetrics := []struct {
+		name  string
+		help  string
+		mType string
+		value func(module.SummaryStruct) uint64
+	}{
+		{"mycha_module_called_total", "Number of calls to the module.", metrics.TYPE_COUNTER,
+			func(s module.SummaryStruct) uint64 { return uint64(s.Called) }},
+		{"mycha_module_accepted_total", "Number of calls accepted by the module.", metrics.TYPE_COUNTER,
+			func(s module.SummaryStruct) uint64 { return uint64(s.Accepted) }},
+		{"mycha_module_completed_total", "Number of calls completed by the module.", metrics.TYPE_COUNTER,
+			func(s module.SummaryStruct) uint64 { return uint64(s.Completed) }},
+		{"mycha_module_handling", "Number of calls being handled by the module.", metrics.TYPE_GAUGE,
+			func(s module.SummaryStruct) uint64 { return uint64(s.Handling) }},
+		{"mycha_module_selected_total", "Number of times the module was selected by the registrar.", metrics.TYPE_COUNTER,
+			func(s module.SummaryStruct) uint64 { return s.Selected }},
+	}
+	for _, m := range moduleMetrics {
+		samples := make([]metrics.Sample, 0, len(moduleSummaries))
+		for _, s := range moduleSummaries {
+			samples = append(samples, metrics.Sample{
+				Labels: []metrics.Label{{Name: "mid", Value: string(s.ID)}},
+				Value:  float64(m.value(s)),
+			})
+		}
+		if err := metrics.WriteMetric(buf, m.name, m.help, m.mType, samples); err != nil {
+			return err
+		}
+	}
+
+	pools := []struct {
+		name    string
+		summary BufferPoolSummaryStruct
+	}{
+		{"request", summary.ReqBufferPool},
+		{"response", summary.RespBufferPool},
+		{"item", summary.ItemBufferPool},
+		{"error", summary.ErrorBufferPool},
+	}
+	totalSamples := make([]metrics.Sample, 0, len(pools))
+	bufferSamples := make([]metrics.Sample, 0, len(pools))
+	for _, pool := range pools {
+		labels := []metrics.Label{{Name: "pool", Value: pool.name}}
+		totalSamples = append(totalSamples,
+			metrics.Sample{Labels: labels, Value: float64(pool.summary.Total)})
+		bufferSamples = append(bufferSamples,
+			metrics.Sample{Labels: labels, Value: float64(pool.summary.BufferNumber)})
+	}
+	err := metrics.WriteMetric(buf, "mycha_buffer_pool_data",
+		"Number of data in the buffer pool.", metrics.TYPE_GAUGE, totalSamples)
+	if err != nil {
+		return err
+	}
+	err = metrics.WriteMetric(buf, "mycha_buffer_pool_buffers",
+		"Number of buffers in the buffer pool.", metrics.TYPE_GAUGE, bufferSamples)
+	if err != nil {
+		return err
+	}
+
+	dedupLabels := []metrics.Label{{Name: "type", Value: summary.Deduper.Type}}
+	err = metrics.WriteMetric(buf, "mycha_dedup_urls",
+		"Number of URLs seen by the deduper.", metrics.TYPE_GAUGE,
+		[]metrics.Sample{{Labels: dedupLabels, Value: float64(summary.Deduper.Size)}})
+	if err != nil {
+		return err
+	}
+	return metrics.WriteMetric(buf, "mycha_dedup_memory_bytes",
+		"Approximate memory used by the deduper in bytes.", metrics.TYPE_GAUGE,
+		[]metrics.Sample{{Labels: dedupLabels, Value: float64(summary.Deduper.MemoryBytes)}})
+}
+
+// writeErrorMetrics 用于写入按错误类型统计的错误数量。
+func writeErrorMetrics(buf *bytes.Buffer, counts map[string]uint64) error {
+	types := make([]string, 0, len(counts))
+	for errType := range counts {
+		types = append(types, errType)
+	}
+	sort.Strings(types)
+	samples := make([]metrics.Sample, 0, len(types))
+	for _, errType := range types {
+		label := errType
+		if label == "" {
+			label = otherErrorType
+		}
+		samples = append(samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "type", Value: label}},
+			Value:  float64(counts[errType]),
+		})
+	}
+	return metrics.WriteMetric(buf, "mycha_errors_total",
+		"Number of errors reported by the scheduler by type.",
+		metrics.TYPE_COUNTER, samples)
+}
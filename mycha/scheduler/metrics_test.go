@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMetricsPerScheduler(t *testing.T) {
+	server := newTestServer(4)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	first := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	waitItems(t, first, p, 4)
+	stopTestScheduler(t, first)
+	// 另一个调度器的指标不受前一个调度器的影响。
+	second := newTestAdminScheduler(t)
+	second.sendError(errors.New("something wrong"), "")
+	cases := []struct {
+		sched    scheduler
+		expected []string
+	}{
+		{first, []string{
+			"mycha_download_latency_seconds_count 4\n",
+			"mycha_download_bytes_count 4\n",
+		}},
+		{second, []string{
+			"mycha_download_latency_seconds_count 0\n",
+			"mycha_download_bytes_count 0\n",
+			`mycha_errors_total{type="调度器错误"} 1` + "\n",
+		}},
+	}
+	for i, c := range cases {
+		var buf bytes.Buffer
+		if err := writeMetrics(&buf, c.sched); err != nil {
+			t.Fatalf("An error occurs when writing metrics: %s", err)
+		}
+		for _, line := range c.expected {
+			if !strings.Contains(buf.String(), line) {
+				t.Fatalf("Metric line %q of scheduler %d is missing:\n%s", line, i, buf.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// monitorIdle 会定期检查调度器是否空闲，
+// 持续空闲的时间达到窗口期后自动停止调度器。
+func (sched *myScheduler) monitorIdle() {
+	if sched.idleArgs.Window == 0 {
+		return
+	}
+	window := time.Duration(sched.idleArgs.Window) * time.Millisecond
+	interval := sched.idleArgs.checkInterval()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var idleSince time.Time
+		for {
+			select {
+			case <-sched.ctx.Done():
+				return
+			case now := <-ticker.C:
+				// 暂停期间不算空闲，以免暂停过久被自动停止。
+				if sched.Status() == SCHED_STATUS_PAUSED {
+					idleSince = time.Time{}
+					continue
+				}
+				if !sched.Idle() {
+					idleSince = time.Time{}
+					continue
+				}
+				if idleSince.IsZero() {
+					idleSince = now
+					continue
+				}
+				if now.Sub(idleSince) < window {
+					continue
+				}
+				logger.Infof("调度器已持续空闲%s，自动停止", now.Sub(idleSince))
+				if err := sched.Stop(); err != nil {
+					logger.Errorf("自动停止调度器失败: %s", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Done 用于获取一个在调度器停止后会被关闭的通道。
+// 调度器停止后再次初始化时，之后获取的是新的通道。
+func (sched *myScheduler) Done() <-chan struct{} {
+	sched.doneLock.Lock()
+	defer sched.doneLock.Unlock()
+	return sched.done
+}
+
+// FinalSummary 用于获取调度器停止时的摘要快照，调度器未停止时返回nil。
+func (sched *myScheduler) FinalSummary() SchedSummary {
+	sched.doneLock.Lock()
+	defer sched.doneLock.Unlock()
+	return sched.finalSummary
+}
+
+// finish 会在调度器停止后记录最终的摘要并关闭Done通道。
+func (sched *myScheduler) finish() {
+	var final SchedSummary
+	if sched.summary != nil {
+		final = &staticSchedSummary{summary: sched.summary.Struct()}
+	}
+	sched.doneLock.Lock()
+	defer sched.doneLock.Unlock()
+	sched.finalSummary = final
+	close(sched.done)
+}
+
+// staticSchedSummary 代表不再变化的调度器摘要。
+type staticSchedSummary struct {
+	summary SummaryStruct
+}
+
+func (ss *staticSchedSummary) Struct() SummaryStruct {
+	return ss.summary
+}
+
+func (ss *staticSchedSummary) String() string {
+	b, err := json.MarshalIndent(ss.summary, "", "    ")
+	if err != nil {
+		logger.Errorf("无法将调度器中的实例组件成字符串: %s\n", err)
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerDoneBeforeInit(t *testing.T) {
+	server := newTestServer(5)
+	defer server.Close()
+	sched := NewScheduler()
+	// 在Init之前获取的通道同样会在调度器停止后被关闭。
+	done := sched.Done()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if sched.Done() != done {
+		t.Fatalf("Init replaces the done channel of a scheduler that has not run")
+	}
+	first := newTestRequest(t, server.URL+"/p0").HTTPReq()
+	if err := sched.Start(first); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitItems(t, sched, p, 5)
+	if sched.FinalSummary() != nil {
+		t.Fatalf("The final summary is not nil before stop")
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("The done channel got before Init is not closed after stop")
+	}
+	if sched.FinalSummary() == nil {
+		t.Fatalf("The final summary is nil after stop")
+	}
+	// 再次初始化后换用新的通道。
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when re-initializing scheduler: %s", err)
+	}
+	select {
+	case <-sched.Done():
+		t.Fatalf("The done channel is closed after re-initializing")
+	default:
+	}
+	if sched.FinalSummary() != nil {
+		t.Fatalf("The final summary is not reset after re-initializing")
+	}
+}
+
+func TestSchedulerIdleStop(t *testing.T) {
+	server := newTestServer(5)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	requestArgs.Idle = IdleArgs{Window: 200, CheckInterval: 20}
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	select {
+	case <-sched.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The scheduler is not stopped after being idle\n%s", sched.Summary())
+	}
+	if sched.Status() != SCHED_STATUS_STOPPED {
+		t.Fatalf("Inconsistent status: expected: %v, actual: %v",
+			SCHED_STATUS_STOPPED, sched.Status())
+	}
+	if p.items != 5 {
+		t.Fatalf("Inconsistent number of items: expected: %d, actual: %d", 5, p.items)
+	}
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"mycha/module"
+	"mycha/tool/buffer"
+)
+
+// 请求缓冲池取出请求的顺序。
+const (
+	// REQ_ORDER_FIFO 代表先进先出，为默认顺序，空字符串与之等价。
+	REQ_ORDER_FIFO = "fifo"
+	// REQ_ORDER_BREADTH_FIRST 代表广度优先，深度小的先取出。
+	REQ_ORDER_BREADTH_FIRST = "breadth_first"
+	// REQ_ORDER_DEPTH_FIRST 代表深度优先，深度大的先取出。
+	REQ_ORDER_DEPTH_FIRST = "depth_first"
+	// REQ_ORDER_PRIORITY 代表按请求的优先级取出，优先级由解析函数设置。
+	REQ_ORDER_PRIORITY = "priority"
+)
+
+// reqOrderLessMap 代表各顺序与比较函数的映射。
+// 深度相同时按优先级比较，优先级相同时按深度比较。
+var reqOrderLessMap = map[string]buffer.Less{
+	REQ_ORDER_BREADTH_FIRST: func(a, b interface{}) bool {
+		ra, rb := toRequest(a), toRequest(b)
+		if ra.Depth() != rb.Depth() {
+			return ra.Depth() < rb.Depth()
+		}
+		return ra.Priority() > rb.Priority()
+	},
+	REQ_ORDER_DEPTH_FIRST: func(a, b interface{}) bool {
+		ra, rb := toRequest(a), toRequest(b)
+		if ra.Depth() != rb.Depth() {
+			return ra.Depth() > rb.Depth()
+		}
+		return ra.Priority() > rb.Priority()
+	},
+	REQ_ORDER_PRIORITY: func(a, b interface{}) bool {
+		ra, rb := toRequest(a), toRequest(b)
+		if ra.Priority() != rb.Priority() {
+			return ra.Priority() > rb.Priority()
+		}
+		return ra.Depth() < rb.Depth()
+	},
+}
+
+// toRequest 用于把缓冲池中的数据转换为请求，无法转换时返回空请求。
+func toRequest(datum interface{}) *module.Request {
+	if req, ok := datum.(*module.Request); ok && req != nil {
+		return req
+	}
+	return &module.Request{}
+}
+
+// newReqBufferPool 用于按照给定的顺序创建请求缓冲池。
+// less不为nil时按它排序，否则按order对应的顺序排序。
+func newReqBufferPool(order string, less func(a, b *module.Request) bool,
+	bufferCap uint32, maxBufferNumber uint32) (buffer.Pool, error) {
+	if less != nil {
+		return buffer.NewPriorityPool(bufferCap, maxBufferNumber, func(a, b interface{}) bool {
+			return less(toRequest(a), toRequest(b))
+		})
+	}
+	orderLess, ok := reqOrderLessMap[order]
+	if !ok {
+		return buffer.NewPool(bufferCap, maxBufferNumber)
+	}
+	return buffer.NewPriorityPool(bufferCap, maxBufferNumber, orderLess)
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"mycha/module"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReqBufferPoolOrder(t *testing.T) {
+	// 每个请求的链接路径为“/<名字>”。
+	specs := []struct {
+		name     string
+		depth    uint32
+		priority int
+	}{
+		{"a", 2, 0},
+		{"bb", 0, 1},
+		{"ccc", 1, 5},
+		{"dddd", 1, 0},
+		{"e", 0, 0},
+	}
+	byPathLen := func(a, b *module.Request) bool {
+		return len(a.HTTPReq().URL.Path) > len(b.HTTPReq().URL.Path)
+	}
+	cases := []struct {
+		order    string
+		less     func(a, b *module.Request) bool
+		expected string
+	}{
+		{"", nil, "a bb ccc dddd e"},
+		{REQ_ORDER_FIFO, nil, "a bb ccc dddd e"},
+		{REQ_ORDER_BREADTH_FIRST, nil, "bb e ccc dddd a"},
+		{REQ_ORDER_DEPTH_FIRST, nil, "a ccc dddd bb e"},
+		{REQ_ORDER_PRIORITY, nil, "ccc bb e dddd a"},
+		// 自定义的顺序优先于给定的顺序，相等时先进先出。
+		{REQ_ORDER_PRIORITY, byPathLen, "dddd ccc bb a e"},
+	}
+	for _, c := range cases {
+		pool, err := newReqBufferPool(c.order, c.less, 10, 1)
+		if err != nil {
+			t.Fatalf("An error occurs when new request buffer pool: %s", err)
+		}
+		for _, spec := range specs {
+			httpReq, _ := http.NewRequest(http.MethodGet, "http://example.com/"+spec.name, nil)
+			req := module.NewRequest(httpReq, spec.depth)
+			req.SetPriority(spec.priority)
+			if err := pool.Put(req); err != nil {
+				t.Fatalf("An error occurs when putting request: %s", err)
+			}
+		}
+		var names []string
+		for range specs {
+			datum, err := pool.Get()
+			if err != nil {
+				t.Fatalf("An error occurs when getting request: %s", err)
+			}
+			names = append(names, strings.TrimPrefix(datum.(*module.Request).HTTPReq().URL.Path, "/"))
+		}
+		if actual := strings.Join(names, " "); actual != c.expected {
+			t.Fatalf("Inconsistent order of %q (custom: %v): expected: %s, actual: %s",
+				c.order, c.less != nil, c.expected, actual)
+		}
+	}
+}
+
+func TestDataArgsSame(t *testing.T) {
+	_, args, _, _ := newTestArgs()
+	another := args
+	if !args.Same(&another) {
+		t.Fatalf("Data args %+v and its copy are not the same", args)
+	}
+	another.ReqLess = func(a, b *module.Request) bool { return false }
+	if args.Same(&another) {
+		t.Fatalf("Data args with and without a custom order are the same")
+	}
+	another = args
+	another.ReqPoolOrder = REQ_ORDER_PRIORITY
+	if args.Same(&another) {
+		t.Fatalf("Data args with different orders are the same")
+	}
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import "sync"
+
+// pauseGate 代表暂停闸门，暂停时处理循环会在这里等待。
+// 暂停不会关闭缓冲池，所以已经排队的请求、响应和条目都会被保留。
+type pauseGate struct {
+	lock sync.Mutex
+	// resumed 会在继续时被关闭，未暂停时为nil。
+	resumed chan struct{}
+}
+
+// close 用于关上闸门。
+func (gate *pauseGate) close() {
+	gate.lock.Lock()
+	if gate.resumed == nil {
+		gate.resumed = make(chan struct{})
+	}
+	gate.lock.Unlock()
+}
+
+// open 用于打开闸门，唤醒所有正在等待的处理循环。
+func (gate *pauseGate) open() {
+	gate.lock.Lock()
+	if gate.resumed != nil {
+		close(gate.resumed)
+		gate.resumed = nil
+	}
+	gate.lock.Unlock()
+}
+
+// wait 会在闸门关闭时一直等待，直到闸门被打开或done被关闭。
+// 返回false代表等待期间done被关闭。
+func (gate *pauseGate) wait(done <-chan struct{}) bool {
+	gate.lock.Lock()
+	resumed := gate.resumed
+	gate.lock.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// waitIfPaused 会在调度器暂停时等待，直到继续或停止。
+// 返回false代表调度器已经停止。
+func (sched *myScheduler) waitIfPaused() bool {
+	return sched.pauseGate.wait(sched.ctx.Done())
+}
+
+// Pause 用于暂停调度器。
+// 暂停后下载、分析和条目处理循环不再从缓冲池取出数据，
+// 正在处理中的数据会在处理完毕后停下，缓冲池和其中的数据保持不变。
+func (sched *myScheduler) Pause() (err error) {
+	logger.Info("Pause scheduler...")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_PAUSING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_PAUSED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+	sched.pauseGate.close()
+	logger.Info("Scheduler has been paused.")
+	return nil
+}
+
+// Resume 用于让已暂停的调度器继续运行。
+func (sched *myScheduler) Resume() error {
+	logger.Info("Resume scheduler...")
+	sched.statusLock.Lock()
+	defer sched.statusLock.Unlock()
+	if sched.status != SCHED_STATUS_PAUSED {
+		errMsg := "系统不在暂停状态，不允许继续 (当前状态: " +
+			GetStatusDescription(sched.status) + ")"
+		return genError(errMsg)
+	}
+	sched.pauseGate.open()
+	sched.status = SCHED_STATUS_STARTED
+	logger.Info("Scheduler has been resumed.")
+	return nil
+}
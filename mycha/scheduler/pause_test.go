@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := &pauseGate{}
+	done := make(chan struct{})
+	if !gate.wait(done) {
+		t.Fatalf("An open gate blocks")
+	}
+	gate.close()
+	// 重复关闭不会替换等待中的通道。
+	gate.close()
+	result := make(chan bool, 1)
+	go func() { result <- gate.wait(done) }()
+	select {
+	case <-result:
+		t.Fatalf("A closed gate does not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	gate.open()
+	if !<-result {
+		t.Fatalf("Inconsistent result after opening: expected: true, actual: false")
+	}
+	gate.close()
+	go func() { result <- gate.wait(done) }()
+	close(done)
+	if <-result {
+		t.Fatalf("Inconsistent result after done: expected: false, actual: true")
+	}
+}
+
+func TestSchedulerPause(t *testing.T) {
+	server := newTestServer(30)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	if sched.Status() != SCHED_STATUS_PAUSED {
+		t.Fatalf("Inconsistent status: expected: %v, actual: %v",
+			SCHED_STATUS_PAUSED, sched.Status())
+	}
+	if err := sched.Pause(); err == nil {
+		t.Fatalf("No error when pausing a paused scheduler")
+	}
+	first := newTestRequest(t, server.URL+"/p0").HTTPReq()
+	if err := sched.Start(first); err == nil {
+		t.Fatalf("No error when starting a paused scheduler")
+	}
+	// 正在处理中的数据处理完毕后不再有进展。
+	time.Sleep(300 * time.Millisecond)
+	before := atomic.LoadInt32(&p.items)
+	time.Sleep(300 * time.Millisecond)
+	if after := atomic.LoadInt32(&p.items); after != before || after >= 30 {
+		t.Fatalf("The scheduler makes progress while paused: %d -> %d", before, after)
+	}
+	if err := sched.Resume(); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	if err := sched.Resume(); err == nil {
+		t.Fatalf("No error when resuming a running scheduler")
+	}
+	waitItems(t, sched, p, 30)
+	// 暂停中的调度器可以直接停止。
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	stopTestScheduler(t, sched)
+}
@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// politeness 代表按主机进行的下载限制，包括爬取间隔、令牌桶限速和并发上限。
+type politeness struct {
+	args  PolitenessArgs
+	lock  sync.Mutex
+	hosts map[string]*hostState
+	// pruned 代表上一次清理主机状态的时间。
+	pruned time.Time
+}
+
+// hostState 代表单个主机的限制状态。
+type hostState struct {
+	// tokens 代表令牌桶中剩余的令牌数。
+	tokens float64
+	// refilled 代表上一次补充令牌的时间。
+	refilled time.Time
+	// nextAllowed 代表按爬取间隔计算的下一次允许请求的时间。
+	nextAllowed time.Time
+	// crawlDelay 代表针对该主机单独设置的爬取间隔。
+	crawlDelay time.Duration
+	// inFlight 代表进行中的请求数。
+	inFlight uint32
+	// lastUsed 代表上一次请求开始或结束的时间。
+	lastUsed time.Time
+}
+
+// 下载限制相关的默认值。
+const (
+	// defaultMaxInFlight 代表默认的全局并发下载数上限。
+	defaultMaxInFlight = 16
+	// hostBusyDelay 代表主机的并发请求数已满时，请求被推迟的时长。
+	hostBusyDelay = 50 * time.Millisecond
+	// hostIdleTTL 代表主机的状态在空闲多久之后被清理。
+	hostIdleTTL = 10 * time.Minute
+)
+
+func newPoliteness(args PolitenessArgs) *politeness {
+	return &politeness{
+		args:   args,
+		hosts:  map[string]*hostState{},
+		pruned: time.Now(),
+	}
+}
+
+// maxInFlight 用于获取全局的并发下载数上限。
+func (p *politeness) maxInFlight() int {
+	if p.args.MaxInFlight == 0 {
+		return defaultMaxInFlight
+	}
+	return int(p.args.MaxInFlight)
+}
+
+// state 用于获取主机的状态，调用方需持有锁。
+func (p *politeness) state(host string, now time.Time) *hostState {
+	hs, ok := p.hosts[host]
+	if !ok {
+		hs = &hostState{
+			tokens:   float64(p.burst()),
+			refilled: now,
+			lastUsed: now,
+		}
+		p.hosts[host] = hs
+	}
+	return hs
+}
+
+func (p *politeness) burst() uint32 {
+	if p.args.Burst == 0 {
+		return 1
+	}
+	return p.args.Burst
+}
+
+// setCrawlDelay 用于为某个主机单独设置爬取间隔，
+// 实际生效的是它与全局爬取间隔中较大的那个。
+func (p *politeness) setCrawlDelay(host string, delay time.Duration) {
+	p.lock.Lock()
+	p.state(normalizeHost(host), time.Now()).crawlDelay = delay
+	p.lock.Unlock()
+}
+
+// tryAcquire 用于在不等待的情况下获取向给定的主机发出请求的许可。
+// 允许时返回的release函数必须在请求结束后调用；
+// 不允许时返回还需等待的时长，调用方应推迟该请求，而不是阻塞其他主机的请求。
+func (p *politeness) tryAcquire(host string, now time.Time) (release func(), wait time.Duration, ok bool) {
+	host = normalizeHost(host)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if now.Sub(p.pruned) >= hostIdleTTL {
+		p.prune(now)
+	}
+	hs := p.state(host, now)
+	if max := p.args.MaxInFlightPerHost; max > 0 && hs.inFlight >= max {
+		return nil, hostBusyDelay, false
+	}
+	if wait = p.reserve(hs, now); wait > 0 {
+		return nil, wait, false
+	}
+	hs.inFlight++
+	hs.lastUsed = now
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			p.lock.Lock()
+			hs.inFlight--
+			hs.lastUsed = time.Now()
+			p.lock.Unlock()
+		})
+	}
+	return release, 0, true
+}
+
+// prune 用于清理空闲了hostIdleTTL以上的主机的状态，调用方需持有锁。
+// 被清理的主机再次被请求时，会和新的主机一样从满的令牌桶开始。
+func (p *politeness) prune(now time.Time) {
+	for host, hs := range p.hosts {
+		if hs.inFlight == 0 && now.Sub(hs.lastUsed) >= hostIdleTTL && !now.Before(hs.nextAllowed) {
+			delete(p.hosts, host)
+		}
+	}
+	p.pruned = now
+}
+
+// reserve 会在条件满足时消耗一个令牌并返回0，否则返回还需等待的时长。
+// 调用方需持有锁。
+func (p *politeness) reserve(hs *hostState, now time.Time) time.Duration {
+	var wait time.Duration
+	if now.Before(hs.nextAllowed) {
+		wait = hs.nextAllowed.Sub(now)
+	}
+	if rate := p.args.RatePerSecond; rate > 0 {
+		hs.tokens += now.Sub(hs.refilled).Seconds() * rate
+		if max := float64(p.burst()); hs.tokens > max {
+			hs.tokens = max
+		}
+		hs.refilled = now
+		if hs.tokens < 1 {
+			tokenWait := time.Duration((1 - hs.tokens) / rate * float64(time.Second))
+			if tokenWait > wait {
+				wait = tokenWait
+			}
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	if p.args.RatePerSecond > 0 {
+		hs.tokens--
+	}
+	delay := time.Duration(p.args.CrawlDelay) * time.Millisecond
+	if hs.crawlDelay > delay {
+		delay = hs.crawlDelay
+	}
+	hs.nextAllowed = now.Add(delay)
+	return 0
+}
+
+// normalizeHost 用于统一主机名的大小写。
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSpace(host))
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolitenessTryAcquire(t *testing.T) {
+	start := time.Now()
+	type attempt struct {
+		host   string
+		offset time.Duration
+		wait   time.Duration
+		ok     bool
+	}
+	cases := []struct {
+		name     string
+		args     PolitenessArgs
+		attempts []attempt
+	}{
+		{
+			name: "crawl delay",
+			args: PolitenessArgs{CrawlDelay: 1000},
+			attempts: []attempt{
+				{"a.com", 0, 0, true},
+				{"a.com", 400 * time.Millisecond, 600 * time.Millisecond, false},
+				// 其他主机不受影响。
+				{"b.com", 400 * time.Millisecond, 0, true},
+				{"A.com", time.Second, 0, true},
+			},
+		},
+		{
+			name: "rate and burst",
+			args: PolitenessArgs{RatePerSecond: 2, Burst: 2},
+			attempts: []attempt{
+				{"a.com", 0, 0, true},
+				{"a.com", 0, 0, true},
+				{"a.com", 0, 500 * time.Millisecond, false},
+				{"a.com", 500 * time.Millisecond, 0, true},
+			},
+		},
+		{
+			name: "max in flight per host",
+			args: PolitenessArgs{MaxInFlightPerHost: 1},
+			attempts: []attempt{
+				{"a.com", 0, 0, true},
+				{"a.com", 0, hostBusyDelay, false},
+				{"b.com", 0, 0, true},
+			},
+		},
+	}
+	for _, c := range cases {
+		p := newPoliteness(c.args)
+		for i, a := range c.attempts {
+			_, wait, ok := p.tryAcquire(a.host, start.Add(a.offset))
+			if ok != a.ok || wait != a.wait {
+				t.Fatalf("Inconsistent result of %s[%d]: expected: (%v, %v), actual: (%v, %v)",
+					c.name, i, a.wait, a.ok, wait, ok)
+			}
+		}
+	}
+}
+
+func TestPolitenessRelease(t *testing.T) {
+	p := newPoliteness(PolitenessArgs{MaxInFlightPerHost: 1})
+	now := time.Now()
+	release, _, ok := p.tryAcquire("a.com", now)
+	if !ok {
+		t.Fatalf("Could not acquire for a new host")
+	}
+	release()
+	// 重复调用不应使计数出错。
+	release()
+	if _, _, ok = p.tryAcquire("a.com", now); !ok {
+		t.Fatalf("Could not acquire after release")
+	}
+	if _, _, ok = p.tryAcquire("a.com", now); ok {
+		t.Fatalf("Acquired beyond the in-flight limit after double release")
+	}
+}
+
+func TestPolitenessPrune(t *testing.T) {
+	p := newPoliteness(PolitenessArgs{CrawlDelay: 10})
+	now := time.Now()
+	release, _, _ := p.tryAcquire("idle.com", now)
+	release()
+	p.tryAcquire("busy.com", now)
+	p.tryAcquire("other.com", now.Add(hostIdleTTL+time.Second))
+	if _, ok := p.hosts["idle.com"]; ok {
+		t.Fatalf("The state of an idle host is not pruned")
+	}
+	if _, ok := p.hosts["busy.com"]; !ok {
+		t.Fatalf("The state of a host with requests in flight is pruned")
+	}
+}
+
+func TestSchedulerConcurrentDownload(t *testing.T) {
+	server := newTestServer(30)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	requestArgs.Politeness = PolitenessArgs{MaxInFlight: 3}
+	d := moduleArgs.Downloaders[0].(*fakeDownloader)
+	d.delay = 20 * time.Millisecond
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	waitItems(t, sched, p, 30)
+	stopTestScheduler(t, sched)
+	if max := d.maxHandling; max < 2 || max > 3 {
+		t.Fatalf("Inconsistent max number of concurrent downloads: expected: 2~3, actual: %d", max)
+	}
+}
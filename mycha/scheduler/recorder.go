@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"mycha/errors"
+	"sync"
+	"time"
+)
+
+// defaultRecentErrorNumber 代表默认保留的最近错误的数量。
+const defaultRecentErrorNumber = 100
+
+// ErrorRecord 代表一条错误记录。
+type ErrorRecord struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// errorRecorder 代表最近错误的记录器，只保留最新的若干条。
+type errorRecorder struct {
+	lock    sync.Mutex
+	records []ErrorRecord
+	// next 代表下一条记录写入的位置。
+	next int
+	// full 代表记录是否已经写满一轮。
+	full bool
+	// counts 代表按错误类型累计的错误数量，不受保留条数的限制。
+	counts map[string]uint64
+}
+
+func newErrorRecorder(size int) *errorRecorder {
+	if size <= 0 {
+		size = defaultRecentErrorNumber
+	}
+	return &errorRecorder{
+		records: make([]ErrorRecord, size),
+		counts:  map[string]uint64{},
+	}
+}
+
+// record 用于记录一个错误。
+func (recorder *errorRecorder) record(err error) {
+	if err == nil {
+		return
+	}
+	record := ErrorRecord{
+		Time:    time.Now(),
+		Message: err.Error(),
+	}
+	if crawlerError, ok := err.(errors.CrawlerError); ok {
+		record.Type = string(crawlerError.Type())
+	}
+	recorder.lock.Lock()
+	recorder.counts[record.Type]++
+	recorder.records[recorder.next] = record
+	recorder.next++
+	if recorder.next == len(recorder.records) {
+		recorder.next = 0
+		recorder.full = true
+	}
+	recorder.lock.Unlock()
+}
+
+// recent 用于按时间先后获取最近的错误。
+func (recorder *errorRecorder) recent() []ErrorRecord {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	var result []ErrorRecord
+	if recorder.full {
+		result = append(result, recorder.records[recorder.next:]...)
+	}
+	result = append(result, recorder.records[:recorder.next]...)
+	return result
+}
+
+// countsByType 用于获取按错误类型累计的错误数量。
+// 不是CrawlerError的错误会被计入类型为空字符串的一项。
+func (recorder *errorRecorder) countsByType() map[string]uint64 {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	result := make(map[string]uint64, len(recorder.counts))
+	for errType, count := range recorder.counts {
+		result[errType] = count
+	}
+	return result
+}
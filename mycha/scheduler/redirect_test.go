@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"mycha/module"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	requestArgs, dataArgs, moduleArgs, _ := newTestArgs()
+	requestArgs.AcceptedDomains = []string{"example.com"}
+	requestArgs.MaxRedirects = 2
+	sched := NewScheduler().(*myScheduler)
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	parse := func(rawURL string) *url.URL {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("An error occurs when parsing URL %q: %s", rawURL, err)
+		}
+		return u
+	}
+	req := newTestRequest(t, "http://www.example.com/a")
+	sched.deduper.Add("http://www.example.com/a")
+	sched.deduper.Add("http://www.example.com/seen")
+	policy := sched.redirectPolicy(req)
+	cases := []struct {
+		from, to string
+		hops     int
+		ok       bool
+	}{
+		{"http://www.example.com/a", "http://other.org/x", 1, false},
+		{"http://www.example.com/a", "ftp://www.example.com/x", 1, false},
+		{"http://www.example.com/a", "http://www.example.com/x", 3, false},
+		{"http://www.example.com/a", "http://www.example.com/seen", 1, false},
+		{"http://www.example.com/a", "http://www.example.com/b", 1, true},
+		// 跳回请求本身的链接，比如设置Cookie后跳回原页面。
+		{"http://www.example.com/b", "http://www.example.com/a", 2, true},
+		// 同一请求再次经过自己登记的链接。
+		{"http://www.example.com/a", "http://www.example.com/b", 1, true},
+	}
+	for i, c := range cases {
+		err := policy(parse(c.from), parse(c.to), c.hops)
+		if (err == nil) != c.ok {
+			t.Fatalf("Inconsistent result of case %d (%s -> %s): expected ok: %v, actual error: %v",
+				i, c.from, c.to, c.ok, err)
+		}
+	}
+	rejected := sched.rejected.summary()
+	if rejected.Domain != 1 || rejected.Scheme != 1 ||
+		rejected.Redirects != 1 || rejected.Duplicate != 1 {
+		t.Fatalf("Inconsistent rejected summary: %+v", rejected)
+	}
+
+	// 重试的请求沿用原来的检查函数，可以再次经过上次登记的跳转，
+	// 但不能经过其他请求登记的链接。
+	req.SetRedirectPolicy(policy)
+	retried, err := req.Retry()
+	if err != nil {
+		t.Fatalf("An error occurs when retrying request: %s", err)
+	}
+	retryPolicy := retried.RedirectPolicy()
+	if err := retryPolicy(parse("http://www.example.com/a"),
+		parse("http://www.example.com/b"), 1); err != nil {
+		t.Fatalf("The retried request could not follow its own redirect: %s", err)
+	}
+	if err := retryPolicy(parse("http://www.example.com/a"),
+		parse("http://www.example.com/seen"), 1); err == nil {
+		t.Fatalf("The retried request follows a redirect to a URL requested before")
+	}
+	other := sched.redirectPolicy(newTestRequest(t, "http://www.example.com/c"))
+	if err := other(parse("http://www.example.com/c"),
+		parse("http://www.example.com/b"), 1); err == nil {
+		t.Fatalf("Another request follows a redirect claimed by the first request")
+	}
+}
+
+// remoteLikeDownloader 代表像远程下载器那样不调用跳转检查函数的下载器，
+// 它会为/p1的响应附上一次跳到其他域名的跳转。
+type remoteLikeDownloader struct{ fakeDownloader }
+
+func (d *remoteLikeDownloader) Download(req *module.Request) (*module.Response, error) {
+	resp, err := d.fakeDownloader.Download(req)
+	if err != nil || !strings.HasSuffix(req.HTTPReq().URL.Path, "/p1") {
+		return resp, err
+	}
+	resp.SetRedirects([]module.Redirect{{Kind: module.REDIRECT_HTTP,
+		From: req.HTTPReq().URL.String(), To: "http://other.org/p1"}})
+	return resp, nil
+}
+
+func TestSchedulerRemoteRedirects(t *testing.T) {
+	server := newTestServer(3)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	moduleArgs.Downloaders = []module.Downloader{
+		&remoteLikeDownloader{fakeDownloader{fakeModule: fakeModule{mid: "D1"}}}}
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	// 跳到其他域名的/p1的响应被丢弃，只有/p0和/p2得到条目。
+	waitItems(t, sched, p, 2)
+	summary := sched.Summary().Struct()
+	stopTestScheduler(t, sched)
+	if summary.Rejected.Domain != 1 {
+		t.Fatalf("Inconsistent number of redirects rejected by domain: expected: %d, actual: %d",
+			1, summary.Rejected.Domain)
+	}
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import "sync/atomic"
+
+// rejectStats 代表请求在进入请求缓冲池之前被过滤的计数。
+type rejectStats struct {
+	// scheme 代表因协议不是http或https而被过滤的数量。
+	scheme uint64
+	// duplicate 代表因链接重复而被过滤的数量。
+	duplicate uint64
+	// domain 代表因主域名不被接受而被过滤的数量。
+	domain uint64
+	// depth 代表因超过最大深度而被过滤的数量。
+	depth uint64
+	// robots 代表因robots.txt禁止而被过滤的数量。
+	robots uint64
+	// redirects 代表因跳转次数超过上限而被拒绝的跳转数量。
+	redirects uint64
+}
+
+// incr 用于把某项计数增1。
+func (rs *rejectStats) incr(counter *uint64) {
+	atomic.AddUint64(counter, 1)
+}
+
+// RejectedSummaryStruct 代表被过滤请求的摘要类型。
+type RejectedSummaryStruct struct {
+	Scheme    uint64 `json:"scheme"`
+	Duplicate uint64 `json:"duplicate"`
+	Domain    uint64 `json:"domain"`
+	Depth     uint64 `json:"depth"`
+	Robots    uint64 `json:"robots"`
+	Redirects uint64 `json:"redirects"`
+}
+
+// summary 用于生成计数的摘要。
+func (rs *rejectStats) summary() RejectedSummaryStruct {
+	return RejectedSummaryStruct{
+		Scheme:    atomic.LoadUint64(&rs.scheme),
+		Duplicate: atomic.LoadUint64(&rs.duplicate),
+		Domain:    atomic.LoadUint64(&rs.domain),
+		Depth:     atomic.LoadUint64(&rs.depth),
+		Robots:    atomic.LoadUint64(&rs.robots),
+		Redirects: atomic.LoadUint64(&rs.redirects),
+	}
+}
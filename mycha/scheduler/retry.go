@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"math/rand"
+	"mycha/module"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// minRequeueDelay 代表因调度器自身的原因没能下载的请求在重试前的最短等待时间。
+// 比如没有可用的下载器时，立即重试只会再次失败。
+const minRequeueDelay = time.Second
+
+// retryPolicy 代表下载失败时的重试策略。
+type retryPolicy struct {
+	args        RetryArgs
+	statusCodes map[int]bool
+}
+
+func newRetryPolicy(args RetryArgs) *retryPolicy {
+	policy := &retryPolicy{
+		args:        args,
+		statusCodes: map[int]bool{},
+	}
+	for _, code := range args.RetryStatusCodes {
+		policy.statusCodes[code] = true
+	}
+	return policy
+}
+
+// retryableStatus 用于判断状态码是否需要重试。
+func (policy *retryPolicy) retryableStatus(code int) bool {
+	if len(policy.statusCodes) == 0 {
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	return policy.statusCodes[code]
+}
+
+// retryableError 用于判断下载错误是否需要重试。
+// 只有HTTP客户端返回的网络错误才会被重试，参数错误等不会。
+func (policy *retryPolicy) retryableError(err error) bool {
+	if !policy.args.RetryNetworkErrors {
+		return false
+	}
+	if _, ok := err.(*url.Error); ok {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// shouldRetry 用于判断下载结果是否需要重试。
+func (policy *retryPolicy) shouldRetry(
+	req *module.Request, resp *module.Response, err error) bool {
+	if req.Attempt() >= policy.args.MaxRetries {
+		return false
+	}
+	if err != nil {
+		return policy.retryableError(err)
+	}
+	if resp != nil && resp.HTTPResp() != nil {
+		return policy.retryableStatus(resp.HTTPResp().StatusCode)
+	}
+	return false
+}
+
+// delay 用于计算第attempt次重试前的等待时间。
+// 若响应带有Retry-After头且要求的时间更长，则以它为准，但都不超过MaxDelay。
+func (policy *retryPolicy) delay(attempt uint32, resp *module.Response) time.Duration {
+	delay := time.Duration(policy.args.BaseDelay) * time.Millisecond
+	maxDelay := time.Duration(policy.args.MaxDelay) * time.Millisecond
+	for i := uint32(1); i < attempt && delay > 0; i++ {
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			break
+		}
+	}
+	if jitter := policy.args.Jitter; jitter > 0 && delay > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * jitter * float64(delay))
+	}
+	if after := retryAfter(resp); after > delay {
+		delay = after
+	}
+	// 服务器要求的等待时间同样不能超过上限，否则一个响应就能让请求挂起很久。
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// retryAfter 用于解析响应中以秒为单位的Retry-After头。
+func retryAfter(resp *module.Response) time.Duration {
+	if resp == nil || resp.HTTPResp() == nil {
+		return 0
+	}
+	value := resp.HTTPResp().Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"errors"
+	"mycha/module"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestRetryResponse(statusCode int, retryAfter string) *module.Response {
+	httpResp := &http.Response{StatusCode: statusCode, Header: http.Header{}}
+	if retryAfter != "" {
+		httpResp.Header.Set("Retry-After", retryAfter)
+	}
+	return module.NewResponse(httpResp, 0)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     RetryArgs
+		attempt  uint32
+		resp     *module.Response
+		expected time.Duration
+	}{
+		{"first", RetryArgs{BaseDelay: 100}, 1, nil, 100 * time.Millisecond},
+		{"third", RetryArgs{BaseDelay: 100}, 3, nil, 400 * time.Millisecond},
+		{"capped", RetryArgs{BaseDelay: 100, MaxDelay: 300}, 5, nil, 300 * time.Millisecond},
+		{"retry after", RetryArgs{BaseDelay: 100}, 1,
+			newTestRetryResponse(http.StatusServiceUnavailable, "2"), 2 * time.Second},
+		{"retry after shorter", RetryArgs{BaseDelay: 3000}, 1,
+			newTestRetryResponse(http.StatusServiceUnavailable, "2"), 3 * time.Second},
+		{"retry after capped", RetryArgs{BaseDelay: 100, MaxDelay: 1000}, 1,
+			newTestRetryResponse(http.StatusTooManyRequests, "86400"), time.Second},
+		{"retry after date capped", RetryArgs{BaseDelay: 100, MaxDelay: 1000}, 1,
+			newTestRetryResponse(http.StatusTooManyRequests,
+				time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), time.Second},
+		{"invalid retry after", RetryArgs{BaseDelay: 100}, 1,
+			newTestRetryResponse(http.StatusTooManyRequests, "soon"), 100 * time.Millisecond},
+	}
+	for _, c := range cases {
+		actual := newRetryPolicy(c.args).delay(c.attempt, c.resp)
+		if actual != c.expected {
+			t.Fatalf("Inconsistent delay of %s: expected: %s, actual: %s",
+				c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	policy := newRetryPolicy(RetryArgs{BaseDelay: 1000, MaxDelay: 1100, Jitter: 0.5})
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(1, nil)
+		if delay < 500*time.Millisecond || delay > 1100*time.Millisecond {
+			t.Fatalf("Delay %s is out of range [500ms, 1100ms]", delay)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	req := newTestRequest(t, "http://example.com/")
+	retried, _ := req.Retry()
+	netErr := &url.Error{Op: "Get", URL: "http://example.com/", Err: errors.New("connection refused")}
+	cases := []struct {
+		name     string
+		args     RetryArgs
+		req      *module.Request
+		resp     *module.Response
+		err      error
+		expected bool
+	}{
+		{"server error", RetryArgs{MaxRetries: 1}, req,
+			newTestRetryResponse(http.StatusBadGateway, ""), nil, true},
+		{"too many requests", RetryArgs{MaxRetries: 1}, req,
+			newTestRetryResponse(http.StatusTooManyRequests, ""), nil, true},
+		{"not found", RetryArgs{MaxRetries: 1}, req,
+			newTestRetryResponse(http.StatusNotFound, ""), nil, false},
+		{"custom codes", RetryArgs{MaxRetries: 1, RetryStatusCodes: []int{http.StatusNotFound}}, req,
+			newTestRetryResponse(http.StatusNotFound, ""), nil, true},
+		{"exhausted", RetryArgs{MaxRetries: 1}, retried,
+			newTestRetryResponse(http.StatusBadGateway, ""), nil, false},
+		{"network error", RetryArgs{MaxRetries: 1, RetryNetworkErrors: true}, req, nil, netErr, true},
+		{"network error disabled", RetryArgs{MaxRetries: 1}, req, nil, netErr, false},
+		{"other error", RetryArgs{MaxRetries: 1, RetryNetworkErrors: true}, req,
+			nil, errors.New("bad request"), false},
+	}
+	for _, c := range cases {
+		actual := newRetryPolicy(c.args).shouldRetry(c.req, c.resp, c.err)
+		if actual != c.expected {
+			t.Fatalf("Inconsistent result of %s: expected: %v, actual: %v",
+				c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestSchedulerNoDownloader(t *testing.T) {
+	server := newTestServer(1)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, _ := newTestArgs()
+	requestArgs.Retry = RetryArgs{MaxRetries: 1}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.UnregisterModule("D1"); err != nil {
+		t.Fatalf("An error occurs when unregistering the downloader: %s", err)
+	}
+	first, _ := http.NewRequest(http.MethodGet, server.URL+"/p0", nil)
+	if err := sched.Start(first); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	// 没有下载器时请求同样按重试策略重试，达到最大重试次数后被丢弃，只产生一个错误。
+	errCh := sched.ErrorChan()
+	start := time.Now()
+	var errs []error
+	var droppedAt time.Time
+	timeout := time.After(3 * time.Second)
+	for done := false; !done; {
+		select {
+		case err := <-errCh:
+			errs = append(errs, err)
+			droppedAt = time.Now()
+		case <-timeout:
+			done = true
+		}
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Inconsistent number of errors: expected: %d, actual: %d (%v)", 1, len(errs), errs)
+	}
+	if droppedAt.Sub(start) < minRequeueDelay {
+		t.Fatalf("The request is retried without delay")
+	}
+	if !sched.Idle() {
+		t.Fatalf("The scheduler is not idle after the request is dropped\n%s", sched.Summary())
+	}
+	stopTestScheduler(t, sched)
+}
@@ -6,12 +6,18 @@ import (
 	"golang.org/x/net/context"
 	"gopcp.v2/chapter5/cmap"
 	"strings"
+	"time"
 
 	"mycha/helper/log"
+	"mycha/helper/metrics"
 	"mycha/module"
 	"mycha/tool/buffer"
+	"mycha/tool/dedup"
+	"mycha/tool/robots"
 	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
 )
 
 var logger = log.DLogger()
@@ -28,39 +34,87 @@ type scheduler interface {
 	ErrorChan() <-chan error  //错误通道?
 	Idle() bool //用来判断所有的模块都处于空闲状态
 	Summary() SchedSummary
+	Checkpoint() error //保存当前的爬取边界到检查点
+	ResumeFrom(checkpointPath string) (err error) //从检查点恢复并启动调度器
+	Pause() (err error) //暂停调度器 不关闭缓冲池
+	Resume() error //让已暂停的调度器继续运行
+	RegisterModule(m module.Module) error //向运行中的调度器注册组件
+	UnregisterModule(mid module.MID) error //从运行中的调度器注销组件
+	Done() <-chan struct{} //调度器停止后会被关闭的通道
+	FinalSummary() SchedSummary //调度器停止时的摘要快照
 }
 
 func NewScheduler() scheduler{
-	return &myScheduler{}
+	return &myScheduler{done: make(chan struct{})}
 }
 
 type myScheduler struct {
 	//最大的深度
 	maxDepth uint32
+	//一次下载中最多跟随的跳转次数
+	maxRedirects int
 	//允许接受的域名哈希
 	acceptedDomainMap cmap.ConcurrentMap  //用了第三方的 并发安全的map
 	//registrar 代表组件注册器
 	registrar module.Registrar
+	//组件的健康检查器
+	health *healthChecker
 	//请求缓冲池
 	regBufferPool buffer.Pool
+	//请求缓冲池取出请求的顺序
+	reqPoolOrder string
+	//自定义的请求顺序 不为nil时优先于reqPoolOrder
+	reqLess func(a, b *module.Request) bool
 	//响应缓冲池
 	respBufferPool buffer.Pool
 	//代表条目缓冲池
 	itemBufferPool buffer.Pool
 	//错误缓冲池
 	errorBufferPool buffer.Pool
-	//链接的字典
-	urlMap cmap.ConcurrentMap
+	//链接去重器
+	deduper dedup.Deduper
 	//ctx 代表上下文 用于感知调度器停止
 	ctx context.Context
 	// cancelFunc 代表取消函数，用于停止调度器。
 	cancelFunc context.CancelFunc
 	//状态
 	status Status
+	//暂停闸门 暂停时处理循环在此等待
+	pauseGate *pauseGate
 	//锁
 	statusLock sync.RWMutex
 	//摘要
 	summary SchedSummary
+	//爬取边界的记录 用于生成检查点
+	frontier *frontier
+	//检查点的存储
+	frontierStore FrontierStore
+	//自动保存检查点的间隔
+	checkpointInterval time.Duration
+	//按主机进行的下载限制
+	politeness *politeness
+	//下载失败时的重试策略
+	retryPolicy *retryPolicy
+	//正在等待重新放入请求缓冲池的请求数 包括等待重试和因主机限制而推迟的请求
+	retrying int64
+	//空闲检测和自动停止的参数
+	idleArgs IdleArgs
+	//调度器停止后会被关闭的通道
+	done chan struct{}
+	//调度器停止时的摘要快照
+	finalSummary SchedSummary
+	//保护done和finalSummary的锁
+	doneLock sync.Mutex
+	//最近错误的记录器
+	errorRecorder *errorRecorder
+	//下载耗时的直方图
+	downloadLatency *metrics.Histogram
+	//下载的响应体大小的直方图
+	downloadBytes *metrics.Histogram
+	//robots.txt规则的缓存 为nil时不检查
+	robots *robots.Cache
+	//被过滤请求的计数
+	rejected *rejectStats
 }
 
 func (sched *myScheduler) Stop() (err error) {
@@ -78,10 +132,18 @@ func (sched *myScheduler) Stop() (err error) {
 			sched.status = SCHED_STATUS_STOPPED
 		}
 		sched.statusLock.Unlock()
+		if err == nil {
+			sched.finish()
+		}
 	}()
 	if err != nil {
 		return
 	}
+	if sched.frontierStore != nil {
+		if err := sched.Checkpoint(); err != nil {
+			logger.Errorf("停止时保存检查点失败: %s", err)
+		}
+	}
 	sched.cancelFunc()
 	sched.regBufferPool.Close()
 	sched.respBufferPool.Close()
@@ -140,15 +202,66 @@ func (sched *myScheduler) Init(
 	} else {
 		sched.registrar.Clear()
 	}
+	if err = moduleArgs.Selectors.apply(sched.registrar); err != nil {
+		return err
+	}
+	logger.Infof("--组件选择策略:%+v", moduleArgs.Selectors)
+	moduleArgs.applyWeights()
+	logger.Infof("--组件权重:%v", moduleArgs.Weights)
+	sched.health = newHealthChecker(moduleArgs.Health, sched.registrar)
+	logger.Infof("--组件健康检查参数:%+v", moduleArgs.Health)
 	sched.maxDepth = requestArgs.MaxDepth
 	logger.Infof("--最大爬取深度:%d",sched.maxDepth)
+	sched.maxRedirects = requestArgs.maxRedirects()
+	logger.Infof("--最多跟随的跳转次数:%d", sched.maxRedirects)
+	sched.politeness = newPoliteness(requestArgs.Politeness)
+	logger.Infof("--礼貌爬取参数:%+v", requestArgs.Politeness)
+	sched.retryPolicy = newRetryPolicy(requestArgs.Retry)
+	logger.Infof("--重试策略:%+v", requestArgs.Retry)
+	sched.idleArgs = requestArgs.Idle
+	logger.Infof("--空闲检测参数:%+v", requestArgs.Idle)
+	// 在Init之前获取的Done通道仍然有效，只有上一次运行结束后才换用新的通道。
+	sched.doneLock.Lock()
+	select {
+	case <-sched.done:
+		sched.done = make(chan struct{})
+	default:
+	}
+	sched.finalSummary = nil
+	sched.doneLock.Unlock()
+	sched.robots = nil
+	if requestArgs.Robots.Enabled {
+		sched.robots = robots.NewCache(&http.Client{Timeout: 30 * time.Second},
+			requestArgs.Robots.UserAgent, requestArgs.Robots.cacheTTL())
+	}
+	logger.Infof("--robots.txt参数:%+v", requestArgs.Robots)
+	sched.rejected = &rejectStats{}
+	sched.pauseGate = &pauseGate{}
+	sched.errorRecorder = newErrorRecorder(defaultRecentErrorNumber)
+	sched.downloadLatency = metrics.NewDownloadLatency()
+	sched.downloadBytes = metrics.NewDownloadBytes()
 	sched.acceptedDomainMap,_ = cmap.NewConcurrentMap(1,nil)
 	for _,domain := range requestArgs.AcceptedDomains {
 		sched.acceptedDomainMap.Put(domain, struct{}{}) //为每个域名填充上一个空的结构体
 	}
 	logger.Infof("--允许的域名名单:%v",requestArgs.AcceptedDomains)
-	sched.urlMap,_ = cmap.NewConcurrentMap(16,nil)
-	logger.Infof("--链接的的队列长度长度:%d concurrency %d",sched.urlMap.Len(),sched.urlMap.Concurrency())
+	sched.frontier = newFrontier()
+	for _, domain := range requestArgs.AcceptedDomains {
+		sched.frontier.addDomain(domain)
+	}
+	sched.frontierStore = dataArgs.FrontierStore
+	if sched.frontierStore == nil && dataArgs.CheckpointPath != "" {
+		if sched.frontierStore, err = NewFileFrontierStore(dataArgs.CheckpointPath); err != nil {
+			return err
+		}
+	}
+	sched.checkpointInterval = time.Duration(dataArgs.CheckpointInterval) * time.Millisecond
+	logger.Infof("--检查点路径:%q 间隔:%s", dataArgs.CheckpointPath, sched.checkpointInterval)
+	sched.deduper, err = dedup.New(dataArgs.Deduper, dataArgs.BloomExpected, dataArgs.BloomFalsePositive)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	logger.Infof("--链接去重器:%s", sched.deduper.Type())
 	sched.initBufferPool(dataArgs)   //一个填充数据到调度器中的方法
 	sched.resetContext()  //重置上下文
 	sched.summary =
@@ -223,9 +336,12 @@ func (sched *myScheduler) initBufferPool(dataArgs DataArgs) {
 	if sched.regBufferPool != nil && !sched.regBufferPool.Closed() {   //请求缓存池不为空 且不为关闭状态 关闭 并重置
 		sched.regBufferPool.Close() //关闭
 	}
-	sched.regBufferPool,_ = buffer.NewPool(dataArgs.ReqBufferCap,dataArgs.ReqMaxBufferNumber)
-	logger.Infof("-- 请求缓存池子: bufferCap(容量): %d, maxBufferNumber(当前数): %d",
-		sched.regBufferPool.BufferCap(), sched.regBufferPool.MaxBufferNumber())
+	sched.reqPoolOrder = dataArgs.ReqPoolOrder
+	sched.reqLess = dataArgs.ReqLess
+	sched.regBufferPool,_ = newReqBufferPool(sched.reqPoolOrder, sched.reqLess,
+		dataArgs.ReqBufferCap,dataArgs.ReqMaxBufferNumber)
+	logger.Infof("-- 请求缓存池子: bufferCap(容量): %d, maxBufferNumber(当前数): %d, order(顺序): %q",
+		sched.regBufferPool.BufferCap(), sched.regBufferPool.MaxBufferNumber(), sched.reqPoolOrder)
 	// 初始化响应缓冲池。
 	if sched.respBufferPool != nil && !sched.respBufferPool.Closed() {
 		sched.respBufferPool.Close()
@@ -295,12 +411,16 @@ func (sched *myScheduler) Start(firstHTTPReq *http.Request) (err error) {
 	}
 	logger.Infof("主域名为:%s",primaryDomain)
 	sched.acceptedDomainMap.Put(primaryDomain, struct {}{})
+	sched.frontier.addDomain(primaryDomain)
 	if err  = sched.checkBufferPoolForStart(); err != nil {
 		return
 	}
 	sched.download()   //循环的读取缓存池子的参数
 	sched.analyze()
 	sched.pick()
+	sched.autoCheckpoint()
+	sched.monitorIdle()
+	sched.checkHealth()
 	logger.Info("调度器启动成功")
 	firstReq := module.NewRequest(firstHTTPReq,0)
 	sched.sendReq(firstReq)  //读取第一个请求放入池子中
@@ -308,23 +428,58 @@ func (sched *myScheduler) Start(firstHTTPReq *http.Request) (err error) {
 }
 
 
+// download 会从请求缓冲池取出请求并下载，每个请求在单独的goroutine中下载。
+// 同时进行的下载数受全局的并发上限限制；
+// 目标主机暂时不允许请求时，请求会被推迟并放回请求缓冲池，不会阻塞其他主机的请求。
 func (sched *myScheduler) download() {
 	go func() {
+		slots := make(chan struct{}, sched.politeness.maxInFlight())
 		for {
 			if sched.canceled() {  //检查上下文是否关闭 如果关闭代表取消全部的goroutine
 				break
 			}
-			datum,err := sched.respBufferPool.Get()  //从池子中获取到一个节点
+			if !sched.waitIfPaused() {
+				break
+			}
+			// 先等到有空闲的并发名额，再取出请求。
+			select {
+			case slots <- struct{}{}:
+			case <-sched.ctx.Done():
+				return
+			}
+			datum,err := sched.regBufferPool.Get()  //从池子中获取到一个节点
 			if err != nil {
 				logger.Warnf("请求的缓存池子被关闭了")
 				break
 			}
+			// 取出时刚好被暂停的请求会保留到继续之后再处理。
+			if !sched.waitIfPaused() {
+				break
+			}
 			req,ok := datum.(*module.Request)
-			if !ok {
+			if !ok || req == nil || !req.Valid() {
 				errMsg := fmt.Sprintf("缓存池子中的节点无法转换成正常的请求类型 该类型为 %T",datum)
-				sendError(errors.New(errMsg),"",sched.errorBufferPool)
+				sched.sendError(errors.New(errMsg),"")
+				<-slots
+				continue
 			}
-			sched.downloadOne(req)
+			if !sched.checkRobots(req) {
+				<-slots
+				continue
+			}
+			release, wait, ok := sched.politeness.tryAcquire(req.HTTPReq().URL.Host, time.Now())
+			if !ok {
+				<-slots
+				sched.requeue(req, wait)
+				continue
+			}
+			go func(req *module.Request) {
+				defer func() {
+					release()
+					<-slots
+				}()
+				sched.downloadOne(req)
+			}(req)
 		}
 	}()
 }
@@ -340,24 +495,67 @@ func (sched *myScheduler) downloadOne(req *module.Request) {
 	m,err := sched.registrar.Get(module.TYPE_DOWNLOADER)
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("无法获取到下载器: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sched.sendReq(req)
+		sched.retryLater(req, nil, minRequeueDelay, errors.New(errMsg), "")
 		return
 	}
 	downloader,ok := m.(module.Downloader)  //类型断言 断言为Downloader 方法 同个结构体继承多个接口时需要
 	if !ok {
 		errMsg := fmt.Sprintf("断言下载器类型是 类型和编号为: %T (MID: %s)",
 			m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sched.sendReq(req)
+		sched.retryLater(req, nil, minRequeueDelay, errors.New(errMsg), m.ID())
 		return
 	}
+	// 跳转后的链接同样需要经过过滤。
+	// 重试的请求沿用上一次下载时的检查函数，以便再次经过上次登记的跳转。
+	if req.RedirectPolicy() == nil {
+		req.SetRedirectPolicy(sched.redirectPolicy(req))
+	}
+	start := time.Now()
 	resp,err := downloader.Download(req)
+	latency := time.Since(start)
+	sched.downloadLatency.Observe(latency.Seconds())
+	if _, ok := err.(*module.RedirectError); !ok && resp != nil {
+		if redirectErr := checkRedirects(req, resp); redirectErr != nil {
+			if resp.HTTPResp() != nil && resp.HTTPResp().Body != nil {
+				resp.HTTPResp().Body.Close()
+			}
+			resp, err = nil, redirectErr
+		}
+	}
+	if resp != nil && resp.HTTPResp() != nil && resp.HTTPResp().Body != nil {
+		resp.HTTPResp().Body = sched.downloadBytes.ObserveBody(resp.HTTPResp().Body)
+	}
+	// 被拒绝的跳转已经计入过滤的数量，不算作下载失败。
+	redirectErr, redirectRejected := err.(*module.RedirectError)
+	sched.recordCall(m.ID(), latency, (err != nil && !redirectRejected) ||
+		(resp != nil && resp.HTTPResp() != nil && resp.HTTPResp().StatusCode >= 500))
+	if redirectRejected && redirectErr.Err == errRobotsUnavailable {
+		// 跳转后的主机的robots.txt暂时无法获取，稍后重新下载。
+		sched.retryLater(req, nil, robots.ErrorTTL, err, m.ID())
+		return
+	}
+	if sched.retryPolicy.shouldRetry(req, resp, err) {
+		if next, retryErr := req.Retry(); retryErr == nil {
+			delay := sched.retryPolicy.delay(next.Attempt(), resp)
+			logger.Warnf("下载失败，%s后进行第%d次重试 (URL: %s, 错误: %v)\n",
+				delay, next.Attempt(), req.HTTPReq().URL, err)
+			if resp != nil && resp.HTTPResp() != nil && resp.HTTPResp().Body != nil {
+				resp.HTTPResp().Body.Close()
+			}
+			sched.requeue(next, delay)
+			return
+		}
+	}
 	if resp != nil {
-		sendResq(resp,sched.respBufferPool)
+		// 请求在响应被分析完之后才算处理完毕。
+		sendResq(resp, req, sched.respBufferPool)
+	} else {
+		sched.frontier.done(req)
 	}
-	if err != nil {
-		sendError(err, m.ID(), sched.errorBufferPool)
+	if redirectRejected {
+		logger.Warnf("忽略这个响应! %s\n", err)
+	} else if err != nil {
+		sched.sendError(err, m.ID())
 	}
 
 }
@@ -374,23 +572,30 @@ func (sched *myScheduler) analyze()  {
 			if sched.canceled() {
 				break
 			}
+			if !sched.waitIfPaused() {
+				break
+			}
 			datum,err := sched.respBufferPool.Get()
 			if err != nil {
 				logger.Warnln("响应缓存池已经关闭，丢弃这个响应请求")
 				break
 			}
-			resp,ok := datum.(*module.Response)
+			if !sched.waitIfPaused() {
+				break
+			}
+			pr, ok := datum.(pendingResponse)
 			if !ok {
 				errMsg := fmt.Sprintf("无法转换 response type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+				sched.sendError(errors.New(errMsg), "")
+				continue
 			}
-			sched.analyzeOne(resp)
+			sched.analyzeOne(pr.resp, pr.req)
 		}
 	}()
 }
 
-//每次实际的处理
-func (sched *myScheduler) analyzeOne(resp *module.Response)  {
+//每次实际的处理 req为产生该响应的请求
+func (sched *myScheduler) analyzeOne(resp *module.Response, req *module.Request)  {
 	if resp == nil {
 		return
 	}
@@ -401,19 +606,21 @@ func (sched *myScheduler) analyzeOne(resp *module.Response)  {
 	m, err := sched.registrar.Get(module.TYPE_ANALYZER)
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("无法获取到分析器: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sendResq(resp, sched.respBufferPool)
+		sched.sendError(errors.New(errMsg), "")
+		sendResq(resp, req, sched.respBufferPool)
 		return
 	}
 	analyzer, ok := m.(module.Analyzer)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect analyzer type: %T (MID: %s)",
 			m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sendResq(resp, sched.respBufferPool)
+		sched.sendError(errors.New(errMsg), m.ID())
+		sendResq(resp, req, sched.respBufferPool)
 		return
 	}
+	start := time.Now()
 	dataList, errs := analyzer.Analyze(resp)
+	sched.recordCall(m.ID(), time.Since(start), len(errs) > 0)
 	if dataList != nil {
 		for _, data := range dataList {
 			if data == nil {
@@ -423,30 +630,44 @@ func (sched *myScheduler) analyzeOne(resp *module.Response)  {
 			case *module.Request:
 				sched.sendReq(d)
 			case module.Item:
-				sendItem(d, sched.itemBufferPool)
+				sched.frontier.hold(req)
+				if !sendItem(d, req, sched.itemBufferPool) {
+					sched.frontier.done(req)
+				}
 			default:
 				errMsg := fmt.Sprintf("Unsupported data type %T! (data: %#v)", d, d)
-				sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
+				sched.sendError(errors.New(errMsg), m.ID())
 			}
 		}
 	}
 
 	if errs != nil {
 		for _, err := range errs {
-			sendError(err, m.ID(), sched.errorBufferPool)
+			sched.sendError(err, m.ID())
 		}
 	}
+	sched.frontier.done(req)
 }
 
+// pendingResponse 代表响应缓冲池中的响应，以及产生它的请求。
+type pendingResponse struct {
+	resp *module.Response
+	req  *module.Request
+}
 
+// pendingItem 代表条目缓冲池中的条目，以及得到它的响应所对应的请求。
+type pendingItem struct {
+	item module.Item
+	req  *module.Request
+}
 
-// sendItem 会向条目缓冲池发送条目。
-func sendItem(item module.Item, itemBufferPool buffer.Pool) bool {
+// sendItem 会向条目缓冲池发送条目，req为得到该条目的响应所对应的请求。
+func sendItem(item module.Item, req *module.Request, itemBufferPool buffer.Pool) bool {
 	if item == nil || itemBufferPool == nil || itemBufferPool.Closed() {
 		return false
 	}
 	go func(item module.Item) {
-		if err := itemBufferPool.Put(item); err != nil {
+		if err := itemBufferPool.Put(pendingItem{item: item, req: req}); err != nil {
 			logger.Warnln("The item buffer pool was closed. Ignore item sending.")
 		}
 	}(item)
@@ -461,6 +682,13 @@ func (sched *myScheduler) Idle() bool {
 			return false
 		}
 	}
+	if atomic.LoadInt64(&sched.retrying) > 0 {
+		return false
+	}
+	// 已从请求缓冲池取出但还没下载完的请求也算在内。
+	if sched.frontier.len() > 0 {
+		return false
+	}
 	if sched.regBufferPool.Total() > 0 ||
 		sched.respBufferPool.Total() > 0 ||
 		sched.itemBufferPool.Total() > 0 {
@@ -487,47 +715,57 @@ func (sched *myScheduler) pick() {
 			if sched.canceled() {
 				break
 			}
+			if !sched.waitIfPaused() {
+				break
+			}
 			datum, err := sched.itemBufferPool.Get()
 			if err != nil {
 				logger.Warnln("The item buffer pool was closed. Break item reception.")
 				break
 			}
-			item, ok := datum.(module.Item)
+			if !sched.waitIfPaused() {
+				break
+			}
+			pi, ok := datum.(pendingItem)
 			if !ok {
 				errMsg := fmt.Sprintf("incorrect item type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+				sched.sendError(errors.New(errMsg), "")
+				continue
 			}
-			sched.pickOne(item)
+			sched.pickOne(pi.item, pi.req)
 		}
 	}()
 }
 
-// pickOne 会处理给定的条目。
-func (sched *myScheduler) pickOne(item module.Item) {
+// pickOne 会处理给定的条目，req为得到该条目的响应所对应的请求。
+func (sched *myScheduler) pickOne(item module.Item, req *module.Request) {
 	if sched.canceled() {
 		return
 	}
 	m, err := sched.registrar.Get(module.TYPE_PIPELINE)
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("couldn't get a pipeline pipline: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sched.sendError(errors.New(errMsg), "")
+		sendItem(item, req, sched.itemBufferPool)
 		return
 	}
 	pipeline, ok := m.(module.Pipeline)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect pipeline type: %T (MID: %s)",
 			m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sched.sendError(errors.New(errMsg), m.ID())
+		sendItem(item, req, sched.itemBufferPool)
 		return
 	}
+	start := time.Now()
 	errs := pipeline.Send(item)
+	sched.recordCall(m.ID(), time.Since(start), len(errs) > 0)
 	if errs != nil {
 		for _, err := range errs {
-			sendError(err, m.ID(), sched.errorBufferPool)
+			sched.sendError(err, m.ID())
 		}
 	}
+	sched.frontier.done(req)
 }
 
 
@@ -536,13 +774,13 @@ func (sched *myScheduler) pickOne(item module.Item) {
 
 
 
-//发送响应的内容到响应缓存池
-func sendResq(resp *module.Response,respBufferPool buffer.Pool) bool {
+//发送响应的内容到响应缓存池 req为产生该响应的请求
+func sendResq(resp *module.Response, req *module.Request, respBufferPool buffer.Pool) bool {
 	if resp == nil || respBufferPool == nil || respBufferPool.Closed() {
 		return false
 	}
 	go func(resp *module.Response) {
-		if err := respBufferPool.Put(resp); err != nil {
+		if err := respBufferPool.Put(pendingResponse{resp: resp, req: req}); err != nil {
 			logger.Warnln("响应缓存池子意外关闭")
 		}
 	} (resp)
@@ -571,10 +809,13 @@ func (sched *myScheduler) sendReq(req *module.Request) bool {
 	if scheme != "http" && scheme != "https" {
 		logger.Warnf("忽悠这个请求! 链接的前缀为 %q, 但必须是 %q or %q. (URL: %s)\n",
 			scheme, "http", "https", reqURL)
+		sched.rejected.incr(&sched.rejected.scheme)
 		return false
 	}
-	if v := sched.urlMap.Get(reqURL.String()); v != nil { //一个链接对应一个请求的内容 如果获取到的话 就说明成功
+	dedupKey := dedup.Normalize(reqURL)
+	if sched.deduper.Contains(dedupKey) { //规范化后的链接已经存在 就说明请求过
 		logger.Warnf("忽略这个请求! 请求的链接已经请求过 . (URL: %s)\n", reqURL)
+		sched.rejected.incr(&sched.rejected.duplicate)
 		return false
 	}
 	pd, _ := getPrimaryDomain(httpReq.Host)   //获取到全部的完整域名
@@ -584,23 +825,213 @@ func (sched *myScheduler) sendReq(req *module.Request) bool {
 		}
 		logger.Warnf("Ignore the request! Its host %q is not in accepted primary domain map. (URL: %s)\n",
 			httpReq.Host, reqURL)
+		sched.rejected.incr(&sched.rejected.domain)
 		return false
 	}
 	if req.Depth() > sched.maxDepth {   //请求的深度
 		logger.Warnf("Ignore the request! Its depth %d is greater than %d. (URL: %s)\n",
 			req.Depth(), sched.maxDepth, reqURL)
+		sched.rejected.incr(&sched.rejected.depth)
+		return false
+	}
+	// robots.txt的规则在下载前检查，这里只设置用户代理，并提前开始获取规则。
+	if sched.robots != nil {
+		sched.robots.Lookup(reqURL)
+		if httpReq.Header.Get("User-Agent") == "" {
+			httpReq.Header.Set("User-Agent", sched.robots.UserAgent())
+		}
+	}
+	if !sched.deduper.Add(dedupKey) { //其他goroutine抢先登记了同一个链接
+		logger.Warnf("忽略这个请求! 请求的链接已经请求过 . (URL: %s)\n", reqURL)
+		sched.rejected.incr(&sched.rejected.duplicate)
+		return false
+	}
+	sched.putReq(req)
+	return true
+
+
+}
+
+// checkRedirects 用于按请求的跳转检查函数检查响应经过的每一次跳转，最后一次跳转的目标即最终的链接。
+// 远程下载器收不到检查函数，它跟随的跳转只能在下载之后检查；
+// 本地下载器已经检查过的跳转再次检查时总会通过，不会被重复登记或计数。
+// 有跳转不通过时返回*module.RedirectError，此时响应应被丢弃。
+func checkRedirects(req *module.Request, resp *module.Response) error {
+	policy := req.RedirectPolicy()
+	if policy == nil {
+		return nil
+	}
+	for i, redirect := range resp.Redirects() {
+		from, err := url.Parse(redirect.From)
+		var to *url.URL
+		if err == nil {
+			to, err = url.Parse(redirect.To)
+		}
+		if err == nil {
+			err = policy(from, to, i+1)
+		}
+		if err != nil {
+			return &module.RedirectError{Redirect: redirect, Err: err}
+		}
+	}
+	return nil
+}
+
+// errRobotsUnavailable 代表因robots.txt暂时无法获取而推迟请求的原因。
+var errRobotsUnavailable = errors.New("robots.txt is temporarily unavailable")
+
+// redirectPolicy 用于生成检查请求下载过程中跳转的函数。
+// 跳转后的链接和新请求一样需要通过协议、主域名、robots.txt和去重的检查，
+// 通过后会被登记到去重器中，之后指向它的请求会被当作重复的请求。
+// 跳转后的主机的robots.txt暂时无法获取时返回errRobotsUnavailable，请求会在之后重试。
+// 跳回请求本身的链接，以及同一请求之前的下载中由它登记的链接，不会因重复而被拒绝，
+// 所以重试的请求可以再次经过上次登记的跳转。
+func (sched *myScheduler) redirectPolicy(req *module.Request) module.RedirectPolicy {
+	origin := dedup.Normalize(req.HTTPReq().URL)
+	var lock sync.Mutex
+	// claimed 代表由这个请求的跳转登记到去重器中的链接。
+	claimed := map[string]bool{}
+	return func(from, to *url.URL, hops int) error {
+		if hops > sched.maxRedirects {
+			sched.rejected.incr(&sched.rejected.redirects)
+			return fmt.Errorf("stopped after %d redirects", sched.maxRedirects)
+		}
+		scheme := strings.ToLower(to.Scheme)
+		if scheme != "http" && scheme != "https" {
+			sched.rejected.incr(&sched.rejected.scheme)
+			return fmt.Errorf("unsupported scheme %q", scheme)
+		}
+		pd, _ := getPrimaryDomain(to.Host)
+		if sched.acceptedDomainMap.Get(pd) == nil {
+			sched.rejected.incr(&sched.rejected.domain)
+			return fmt.Errorf("host %q is not in accepted primary domain map", to.Host)
+		}
+		if sched.robots != nil {
+			// 跳转在下载请求的goroutine中检查，等待规则只会推迟这一次下载。
+			rules := sched.robots.Rules(to)
+			if !rules.Allowed(robots.RequestPath(to)) {
+				if rules.Temporary() {
+					return errRobotsUnavailable
+				}
+				sched.rejected.incr(&sched.rejected.robots)
+				return errors.New("disallowed by robots.txt")
+			}
+		}
+		dedupKey := dedup.Normalize(to)
+		if dedupKey == origin || dedupKey == dedup.Normalize(from) {
+			// 跳转到规范化后相同的链接，比如设置Cookie后跳回原页面。
+			return nil
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if claimed[dedupKey] {
+			return nil
+		}
+		if !sched.deduper.Add(dedupKey) {
+			sched.rejected.incr(&sched.rejected.duplicate)
+			return errors.New("already requested")
+		}
+		claimed[dedupKey] = true
+		return nil
+	}
+}
+
+// checkRobots 用于在下载前按robots.txt检查请求，返回请求是否可以立即下载。
+// 规则还没有获取到时，请求会在获取完成后被放回请求缓冲池，不会阻塞其他请求；
+// 被禁止的请求会被丢弃；robots.txt暂时无法获取时，请求会按重试策略在之后重试。
+func (sched *myScheduler) checkRobots(req *module.Request) bool {
+	if sched.robots == nil {
+		return true
+	}
+	reqURL := req.HTTPReq().URL
+	rules, ready := sched.robots.Lookup(reqURL)
+	if rules == nil {
+		atomic.AddInt64(&sched.retrying, 1)
+		go func() {
+			defer atomic.AddInt64(&sched.retrying, -1)
+			select {
+			case <-ready:
+			case <-sched.ctx.Done():
+				return
+			}
+			if err := sched.regBufferPool.Put(req); err != nil {
+				logger.Warnln("The request buffer pool was closed. Ignore request sending.")
+			}
+		}()
 		return false
 	}
+	if !rules.Allowed(robots.RequestPath(reqURL)) {
+		if rules.Temporary() {
+			// 请求已经登记在去重器中，丢弃后就不会再被下载了。
+			sched.retryLater(req, nil, robots.ErrorTTL, errRobotsUnavailable, "")
+			return false
+		}
+		logger.Warnf("忽略这个请求! robots.txt禁止访问. (URL: %s)\n", reqURL)
+		sched.rejected.incr(&sched.rejected.robots)
+		sched.frontier.done(req)
+		return false
+	}
+	if delay := rules.CrawlDelay(); delay > 0 {
+		sched.politeness.setCrawlDelay(reqURL.Host, delay)
+	}
+	return true
+}
+
+// retryLater 用于处理因调度器自身的原因而没能下载的请求，比如没有可用的下载器。
+// 它和下载失败一样计入一次尝试，并按重试策略等待之后重新放入请求缓冲池，
+// 等待时间不少于minDelay，以免请求在缓冲池和下载之间空转。
+// 达到最大重试次数时请求会被丢弃，并把原因发送到错误缓冲池，此时返回false。
+func (sched *myScheduler) retryLater(req *module.Request, resp *module.Response,
+	minDelay time.Duration, reason error, mid module.MID) bool {
+	if req.Attempt() < sched.retryPolicy.args.MaxRetries {
+		if next, err := req.Retry(); err == nil {
+			delay := sched.retryPolicy.delay(next.Attempt(), resp)
+			if delay < minDelay {
+				delay = minDelay
+			}
+			logger.Warnf("%s，%s后进行第%d次重试 (URL: %s)\n",
+				reason, delay, next.Attempt(), req.HTTPReq().URL)
+			sched.requeue(next, delay)
+			return true
+		}
+	}
+	sched.frontier.done(req)
+	errMsg := fmt.Sprintf("%s，尝试%d次后放弃 (URL: %s)",
+		reason, req.Attempt()+1, req.HTTPReq().URL)
+	sched.sendError(errors.New(errMsg), mid)
+	return false
+}
+
+// requeue 会在等待delay之后把请求重新放入请求缓冲池，不再经过去重等检查。
+func (sched *myScheduler) requeue(req *module.Request, delay time.Duration) {
+	if delay <= 0 {
+		sched.putReq(req)
+		return
+	}
+	sched.frontier.add(req)
+	atomic.AddInt64(&sched.retrying, 1)
+	time.AfterFunc(delay, func() {
+		defer atomic.AddInt64(&sched.retrying, -1)
+		if sched.canceled() {
+			return
+		}
+		if err := sched.regBufferPool.Put(req); err != nil {
+			logger.Warnln("The request buffer pool was closed. Ignore request sending.")
+		}
+	})
+}
+
+// putReq 会把已通过检查的请求放入请求缓冲池，并登记到爬取边界。
+func (sched *myScheduler) putReq(req *module.Request) {
+	sched.frontier.add(req)
 	go func(req *module.Request) {
 		if err := sched.regBufferPool.Put(req); err != nil {
 			logger.Warnln("The request buffer pool was closed. Ignore request sending.")
 		}
 	}(req)
-	sched.urlMap.Put(reqURL.String(), struct{}{})
-	return true
+}
 
 
-}
 
 
 
@@ -620,7 +1051,126 @@ func (sched *myScheduler) sendReq(req *module.Request) bool {
 
 
 
+// Checkpoint 用于把当前的爬取边界保存到检查点存储。
+func (sched *myScheduler) Checkpoint() error {
+	if sched.frontierStore == nil {
+		return genError("没有配置检查点存储")
+	}
+	if sched.frontier == nil {
+		return genError("调度器还没有初始化")
+	}
+	snapshot := sched.frontier.snapshot()
+	state, err := sched.deduper.Snapshot()
+	if err != nil {
+		return genErrorByError(err)
+	}
+	snapshot.Deduper = sched.deduper.Type()
+	snapshot.DedupState = state
+	if err := sched.frontierStore.Save(snapshot); err != nil {
+		return err
+	}
+	logger.Infof("检查点已保存 (待处理: %d, 已见链接: %d)",
+		len(snapshot.Pending), sched.deduper.Len())
+	return nil
+}
 
+// ResumeFrom 用于从检查点恢复爬取边界并启动调度器。
+// 参数checkpointPath为空时使用初始化时配置的检查点存储。
+func (sched *myScheduler) ResumeFrom(checkpointPath string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			errMsg := fmt.Sprintf("恢复调度器时出现错误: %s", p)
+			logger.Fatal(errMsg)
+			err = genError(errMsg)
+		}
+	}()
+	logger.Info("从检查点恢复调度器")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_STARTING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_STARTED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+	store := sched.frontierStore
+	if checkpointPath != "" {
+		if store, err = NewFileFrontierStore(checkpointPath); err != nil {
+			return
+		}
+	}
+	if store == nil {
+		err = genParameterError("没有可用的检查点")
+		return
+	}
+	var snapshot *FrontierSnapshot
+	if snapshot, err = store.Load(); err != nil {
+		return
+	}
+	if snapshot.Deduper != sched.deduper.Type() {
+		err = genError(fmt.Sprintf("检查点的去重器类型 %q 与当前的 %q 不一致",
+			snapshot.Deduper, sched.deduper.Type()))
+		return
+	}
+	if err = sched.deduper.Restore(snapshot.DedupState); err != nil {
+		err = genErrorByError(err)
+		return
+	}
+	sched.frontierStore = store
+	logger.Infof("读取检查点 (生成于: %s, 待处理: %d, 已见链接: %d)",
+		snapshot.CreatedAt, len(snapshot.Pending), sched.deduper.Len())
+	for _, domain := range snapshot.AcceptedDomains {
+		sched.acceptedDomainMap.Put(domain, struct{}{})
+		sched.frontier.addDomain(domain)
+	}
+	if err = sched.checkBufferPoolForStart(); err != nil {
+		return
+	}
+	sched.download()
+	sched.analyze()
+	sched.pick()
+	sched.autoCheckpoint()
+	sched.monitorIdle()
+	sched.checkHealth()
+	for _, entry := range snapshot.Pending {
+		req, err := entry.Request()
+		if err != nil {
+			sched.sendError(err, "")
+			continue
+		}
+		// 待处理的请求已经登记在去重器中，所以直接放入请求缓冲池。
+		sched.putReq(req)
+	}
+	logger.Info("调度器已从检查点恢复")
+	return nil
+}
+
+// autoCheckpoint 会按照配置的间隔定期保存检查点，直到调度器停止。
+func (sched *myScheduler) autoCheckpoint() {
+	if sched.frontierStore == nil || sched.checkpointInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(sched.checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sched.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sched.Checkpoint(); err != nil {
+					logger.Errorf("自动保存检查点失败: %s", err)
+				}
+			}
+		}
+	}()
+}
 
 //检查上下文是否取消
 func (sched *myScheduler) canceled() bool {
@@ -642,7 +1192,7 @@ func (sched *myScheduler) checkBufferPoolForStart() error {
 		return genError("空的请求缓存池")
 	}
 	if sched.regBufferPool != nil && sched.regBufferPool.Closed() {
-		sched.regBufferPool,_ = buffer.NewPool(
+		sched.regBufferPool,_ = newReqBufferPool(sched.reqPoolOrder, sched.reqLess,
 			sched.regBufferPool.BufferCap(),
 			sched.regBufferPool.MaxBufferNumber())
 	}
@@ -710,7 +1260,7 @@ func (sched *myScheduler) ErrorChan() <-chan error {
 			err, ok := datum.(error)
 			if !ok {
 				errMsg := fmt.Sprintf("incorrect error type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+				sched.sendError(errors.New(errMsg), "")
 				continue
 			}
 			if sched.canceled() {
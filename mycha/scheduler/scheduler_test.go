@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRobots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		// 获取规则较慢时，请求应等待规则而不是被直接下载。
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("User-agent: mycha\nDisallow: /p3\n"))
+	})
+	mux.Handle("/", testPages(10))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "mycha/1.0"}
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	waitItems(t, sched, p, 9)
+	summary := sched.Summary().Struct()
+	stopTestScheduler(t, sched)
+	if summary.Rejected.Robots != 1 {
+		t.Fatalf("Inconsistent number of requests rejected by robots.txt: expected: %d, actual: %d",
+			1, summary.Rejected.Robots)
+	}
+}
+
+func TestSchedulerRobotsUnavailable(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	mux.Handle("/", testPages(10))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	requestArgs, dataArgs, moduleArgs, p := newTestArgs()
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "mycha/1.0"}
+	requestArgs.Retry = RetryArgs{MaxRetries: 1}
+	sched := startTestScheduler(t, server, requestArgs, dataArgs, moduleArgs)
+	time.Sleep(300 * time.Millisecond)
+	summary := sched.Summary().Struct()
+	idle := sched.Idle()
+	stopTestScheduler(t, sched)
+	// robots.txt暂时无法获取时，请求被推迟而不是被丢弃。
+	if items := atomic.LoadInt32(&p.items); summary.Rejected.Robots != 0 || items != 0 {
+		t.Fatalf("Inconsistent result: expected: (%d, %d), actual: (%d, %d)",
+			0, 0, summary.Rejected.Robots, items)
+	}
+	if idle {
+		t.Fatalf("The scheduler is idle while the request is waiting for robots.txt")
+	}
+}
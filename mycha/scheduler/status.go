@@ -24,6 +24,10 @@ const   (
 	SCHED_STATUS_STOPPING Status = 5
 	// SCHED_STATUS_STOPPED 代表已停止的状态。
 	SCHED_STATUS_STOPPED Status = 6
+	// SCHED_STATUS_PAUSING 代表正在暂停的状态。
+	SCHED_STATUS_PAUSING Status = 7
+	// SCHED_STATUS_PAUSED 代表已暂停的状态。
+	SCHED_STATUS_PAUSED Status = 8
 )
 
 
@@ -31,36 +35,38 @@ const   (
 // 参数currentStatus代表当前的状态。
 // 参数wantedStatus代表想要的状态。
 // 检查规则：
-//     1. 处于正在初始化、正在启动或正在停止状态时，不能从外部改变状态。
-//     2. 想要的状态只能是正在初始化、正在启动或正在停止状态中的一个。
+//     1. 处于正在初始化、正在启动、正在停止或正在暂停状态时，不能从外部改变状态。
+//     2. 想要的状态只能是正在初始化、正在启动、正在停止或正在暂停状态中的一个。
 //     3. 处于未初始化状态时，不能变为正在启动或正在停止状态。
-//     4. 处于已启动状态时，不能变为正在初始化或正在启动状态。
-//     5. 只要未处于已启动状态就不能变为正在停止状态。
+//     4. 处于已启动或已暂停状态时，不能变为正在初始化或正在启动状态。
+//     5. 只要未处于已启动或已暂停状态就不能变为正在停止状态。
+//     6. 只要未处于已启动状态就不能变为正在暂停状态。
+// 从已暂停状态继续由Resume方法单独检查，不经过这里。
 func checkStatus(currentStatus Status,wantedStatus Status,lock sync.Locker) (err error) {
 	if lock != nil {
 		lock.Lock()
 		defer lock.Unlock()
 	}
 	switch currentStatus {
-	case SCHED_STATUS_INITIALIZED: //正在初始化 不能改变状态
+	case SCHED_STATUS_INITIALIZING: //正在初始化 不能改变状态
 		err = genError("调度器正在初始化")
 	case SCHED_STATUS_STARTING:
 		err = genError("调度器正在启动")
 	case SCHED_STATUS_STOPPING:
 		err = genError("调度器正在停止")
+	case SCHED_STATUS_PAUSING:
+		err = genError("调度器正在暂停")
 	}
 	if err != nil {
 		return err
 	}
-	if wantedStatus != SCHED_STATUS_STARTING || wantedStatus != SCHED_STATUS_STOPPING || wantedStatus != SCHED_STATUS_INITIALIZED {
-		err = genError("想要的状态不是允许的类型")
-		return err
-	}
 	switch wantedStatus {
 	case SCHED_STATUS_INITIALIZING:
 		switch currentStatus {
 		case SCHED_STATUS_STARTED:
 			err = genError("当前的系统已经启动，不允许获取到启动状态")
+		case SCHED_STATUS_PAUSED:
+			err = genError("当前的系统已经暂停，不允许重新初始化")
 		}
 	case SCHED_STATUS_STARTING:
 		switch currentStatus {
@@ -68,10 +74,16 @@ func checkStatus(currentStatus Status,wantedStatus Status,lock sync.Locker) (err
 			err = genError("档期系统没初始化，无法获取到开启状态")
 		case SCHED_STATUS_STARTED:
 			err = genError("当前的系统已经启动结束，无法获取到启动中的状态")
+		case SCHED_STATUS_PAUSED:
+			err = genError("当前的系统已经暂停，请使用Resume继续")
 		}
 	case SCHED_STATUS_STOPPING:
+		if currentStatus != SCHED_STATUS_STARTED && currentStatus != SCHED_STATUS_PAUSED {
+			err = genError("系统不在启动结束或暂停状态，不允许停止")
+		}
+	case SCHED_STATUS_PAUSING:
 		if currentStatus != SCHED_STATUS_STARTED {
-			err = genError("系统不在启动结束状态，不允许停止")
+			err = genError("系统不在启动结束状态，不允许暂停")
 		}
 	default:
 		errMsg :=
@@ -101,6 +113,10 @@ func GetStatusDescription(status Status) string {
 		return "停止中"
 	case SCHED_STATUS_STOPPED:
 		return "停止成功"
+	case SCHED_STATUS_PAUSING:
+		return "暂停中"
+	case SCHED_STATUS_PAUSED:
+		return "已暂停"
 	default:
 		return "不明白的信息"
 	}
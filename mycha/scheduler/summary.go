@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"mycha/module"
 	"mycha/tool/buffer"
+	"mycha/tool/dedup"
 	"sort"
 )
 
@@ -45,6 +46,9 @@ type SummaryStruct struct {
 	ItemBufferPool  BufferPoolSummaryStruct `json:"item_buffer_pool"`
 	ErrorBufferPool BufferPoolSummaryStruct `json:"error_buffer_pool"`
 	NumURL          uint64                  `json:"url_number"`
+	Deduper         DeduperSummaryStruct    `json:"deduper"`
+	Rejected        RejectedSummaryStruct   `json:"rejected"`
+	Evicted         []EvictedModuleStruct   `json:"evicted_modules"`
 }
 
 
@@ -68,10 +72,10 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 	if !another.RequestArgs.Same(&one.RequestArgs) {
 		return false
 	}
-	if another.DataArgs != one.DataArgs {
+	if !another.DataArgs.Same(&one.DataArgs) {
 		return false
 	}
-	if another.ModuleArgs != one.ModuleArgs {
+	if !another.ModuleArgs.Same(&one.ModuleArgs) {
 		return false
 	}
 	if another.Status != one.Status {
@@ -116,6 +120,20 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 	if another.NumURL != one.NumURL {
 		return false
 	}
+	if another.Deduper != one.Deduper {
+		return false
+	}
+	if another.Rejected != one.Rejected {
+		return false
+	}
+	if len(another.Evicted) != len(one.Evicted) {
+		return false
+	}
+	for i, es := range another.Evicted {
+		if es != one.Evicted[i] {
+			return false
+		}
+	}
 	return true
 }
 
@@ -130,11 +148,14 @@ func (ss *mySchedSummary) Struct() SummaryStruct {
 		Downloaders:     getModuleSummaries(registrar, module.TYPE_DOWNLOADER),
 		Analyzers:       getModuleSummaries(registrar, module.TYPE_ANALYZER),
 		Pipelines:       getModuleSummaries(registrar, module.TYPE_PIPELINE),
-		ReqBufferPool:   getBufferPoolSummary(ss.sched.reqBufferPool),
+		ReqBufferPool:   getBufferPoolSummary(ss.sched.regBufferPool),
 		RespBufferPool:  getBufferPoolSummary(ss.sched.respBufferPool),
 		ItemBufferPool:  getBufferPoolSummary(ss.sched.itemBufferPool),
 		ErrorBufferPool: getBufferPoolSummary(ss.sched.errorBufferPool),
-		NumURL:          ss.sched.urlMap.Len(),
+		NumURL:          ss.sched.deduper.Len(),
+		Deduper:         getDeduperSummary(ss.sched.deduper),
+		Rejected:        ss.sched.rejected.summary(),
+		Evicted:         ss.sched.health.evictedSummary(),
 	}
 }
 
@@ -166,13 +187,31 @@ func getBufferPoolSummary(bufferPool buffer.Pool) BufferPoolSummaryStruct {
 	}
 }
 
+// DeduperSummaryStruct 代表链接去重器的摘要类型。
+type DeduperSummaryStruct struct {
+	Type        string `json:"type"`
+	Size        uint64 `json:"size"`
+	MemoryBytes uint64 `json:"memory_bytes"`
+}
+
+// getDeduperSummary 用于生成和返回链接去重器的摘要信息。
+func getDeduperSummary(deduper dedup.Deduper) DeduperSummaryStruct {
+	return DeduperSummaryStruct{
+		Type:        deduper.Type(),
+		Size:        deduper.Len(),
+		MemoryBytes: deduper.MemoryBytes(),
+	}
+}
+
 // getModuleSummaries 用于获取已注册的某类组件的摘要。
 func getModuleSummaries(registrar module.Registrar, mType module.Type) []module.SummaryStruct {
 	moduleMap, _ := registrar.GetAllByType(mType)
 	summaries := []module.SummaryStruct{}
 	if len(moduleMap) > 0 {
 		for _, module := range moduleMap {
-			summaries = append(summaries, module.Summary())
+			summary := module.Summary()
+			summary.Selected = registrar.SelectedCount(module.ID())
+			summaries = append(summaries, summary)
 		}
 	}
 	if len(summaries) > 1 {
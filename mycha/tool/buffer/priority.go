@@ -0,0 +1,182 @@
+package buffer
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+
+	"gopcp.v2/chapter6/webcrawler/errors"
+)
+
+// Less 代表比较两个数据先后顺序的函数。
+// 若a应先于b被取出则返回true。
+type Less func(a, b interface{}) bool
+
+// priorityPool 代表按优先级取出数据的缓冲池的实现类型。
+// 它不真正划分缓冲器，只是按照bufferCap*maxBufferNumber限制总容量，
+// 以便与FIFO的缓冲池互换使用。
+type priorityPool struct {
+	// bufferCap 代表缓冲器的统一容量。
+	bufferCap uint32
+	// maxBufferNumber 代表缓冲器的最大数量。
+	maxBufferNumber uint32
+	// items 代表按优先级组织的数据堆。
+	items *priorityHeap
+	// closed 代表缓冲池是否已关闭。
+	closed bool
+	// lock 代表保护内部共享资源的互斥锁。
+	lock sync.Mutex
+	// notEmpty 用于在有数据可取时通知等待者。
+	notEmpty *sync.Cond
+	// notFull 用于在有空间可放时通知等待者。
+	notFull *sync.Cond
+}
+
+// NewPriorityPool 用于创建一个按优先级取出数据的缓冲池。
+// 参数less决定数据被取出的先后顺序，顺序相同的数据按放入的先后取出。
+func NewPriorityPool(
+	bufferCap uint32,
+	maxBufferNumber uint32,
+	less Less) (Pool, error) {
+	if bufferCap == 0 {
+		errMsg := fmt.Sprintf("illegal buffer cap for buffer pool: %d", bufferCap)
+		return nil, errors.NewIllegalParameterError(errMsg)
+	}
+	if maxBufferNumber == 0 {
+		errMsg := fmt.Sprintf("illegal max buffer number for buffer pool: %d", maxBufferNumber)
+		return nil, errors.NewIllegalParameterError(errMsg)
+	}
+	if less == nil {
+		return nil, errors.NewIllegalParameterError("nil less function for priority pool")
+	}
+	pool := &priorityPool{
+		bufferCap:       bufferCap,
+		maxBufferNumber: maxBufferNumber,
+		items:           &priorityHeap{less: less},
+	}
+	pool.notEmpty = sync.NewCond(&pool.lock)
+	pool.notFull = sync.NewCond(&pool.lock)
+	return pool, nil
+}
+
+func (pool *priorityPool) BufferCap() uint32 {
+	return pool.bufferCap
+}
+
+func (pool *priorityPool) MaxBufferNumber() uint32 {
+	return pool.maxBufferNumber
+}
+
+// BufferNumber 会按数据总数折算出缓冲器的数量，至少为1。
+func (pool *priorityPool) BufferNumber() uint32 {
+	total := pool.Total()
+	number := uint32((total + uint64(pool.bufferCap) - 1) / uint64(pool.bufferCap))
+	if number == 0 {
+		number = 1
+	}
+	return number
+}
+
+func (pool *priorityPool) Total() uint64 {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return uint64(pool.items.Len())
+}
+
+// capacity 用于获取缓冲池的总容量。
+func (pool *priorityPool) capacity() int {
+	return int(pool.bufferCap) * int(pool.maxBufferNumber)
+}
+
+func (pool *priorityPool) Put(datum interface{}) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for !pool.closed && pool.items.Len() >= pool.capacity() {
+		pool.notFull.Wait()
+	}
+	if pool.closed {
+		return ErrClosedBufferPool
+	}
+	heap.Push(pool.items, datum)
+	pool.notEmpty.Signal()
+	return nil
+}
+
+func (pool *priorityPool) Get() (datum interface{}, err error) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for !pool.closed && pool.items.Len() == 0 {
+		pool.notEmpty.Wait()
+	}
+	if pool.closed {
+		return nil, ErrClosedBufferPool
+	}
+	datum = heap.Pop(pool.items)
+	pool.notFull.Signal()
+	return datum, nil
+}
+
+func (pool *priorityPool) Close() bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if pool.closed {
+		return false
+	}
+	pool.closed = true
+	pool.items.entries = nil
+	pool.notEmpty.Broadcast()
+	pool.notFull.Broadcast()
+	return true
+}
+
+func (pool *priorityPool) Closed() bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return pool.closed
+}
+
+// priorityEntry 代表堆中的一个数据。
+type priorityEntry struct {
+	datum interface{}
+	// seq 代表放入的序号，用于让顺序相同的数据先进先出。
+	seq uint64
+}
+
+// priorityHeap 代表实现了heap.Interface的数据堆。
+type priorityHeap struct {
+	entries []priorityEntry
+	less    Less
+	seq     uint64
+}
+
+func (h *priorityHeap) Len() int {
+	return len(h.entries)
+}
+
+func (h *priorityHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.less(a.datum, b.datum) {
+		return true
+	}
+	if h.less(b.datum, a.datum) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (h *priorityHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+}
+
+func (h *priorityHeap) Push(x interface{}) {
+	h.entries = append(h.entries, priorityEntry{datum: x, seq: h.seq})
+	h.seq++
+}
+
+func (h *priorityHeap) Pop() interface{} {
+	last := len(h.entries) - 1
+	entry := h.entries[last]
+	h.entries[last] = priorityEntry{}
+	h.entries = h.entries[:last]
+	return entry.datum
+}
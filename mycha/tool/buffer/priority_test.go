@@ -0,0 +1,99 @@
+package buffer
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func lessInt(a, b interface{}) bool {
+	return a.(int) < b.(int)
+}
+
+func TestPriorityPoolNew(t *testing.T) {
+	pool, err := NewPriorityPool(10, 2, lessInt)
+	if err != nil {
+		t.Fatalf("An error occurs when new a priority pool: %s", err)
+	}
+	if pool.BufferCap() != 10 || pool.MaxBufferNumber() != 2 {
+		t.Fatalf("Inconsistent pool params: expected: (%d, %d), actual: (%d, %d)",
+			10, 2, pool.BufferCap(), pool.MaxBufferNumber())
+	}
+	if _, err = NewPriorityPool(0, 1, lessInt); err == nil {
+		t.Fatal("No error when new a priority pool with zero buffer cap!")
+	}
+	if _, err = NewPriorityPool(1, 0, lessInt); err == nil {
+		t.Fatal("No error when new a priority pool with zero max buffer number!")
+	}
+	if _, err = NewPriorityPool(1, 1, nil); err == nil {
+		t.Fatal("No error when new a priority pool with nil less function!")
+	}
+}
+
+func TestPriorityPoolOrder(t *testing.T) {
+	pool, _ := NewPriorityPool(5, 2, lessInt)
+	data := []int{5, 3, 9, 1, 3, 7, 0}
+	for _, datum := range data {
+		if err := pool.Put(datum); err != nil {
+			t.Fatalf("An error occurs when putting a datum to the pool: %s (datum: %d)",
+				err, datum)
+		}
+	}
+	if pool.Total() != uint64(len(data)) {
+		t.Fatalf("Inconsistent data total: expected: %d, actual: %d",
+			len(data), pool.Total())
+	}
+	if pool.BufferNumber() != 2 {
+		t.Fatalf("Inconsistent buffer number: expected: %d, actual: %d",
+			2, pool.BufferNumber())
+	}
+	expected := []int{0, 1, 3, 3, 5, 7, 9}
+	for _, e := range expected {
+		datum, err := pool.Get()
+		if err != nil {
+			t.Fatalf("An error occurs when getting a datum from the pool: %s", err)
+		}
+		if datum.(int) != e {
+			t.Fatalf("Inconsistent datum: expected: %d, actual: %d", e, datum)
+		}
+	}
+}
+
+func TestPriorityPoolBlockAndClose(t *testing.T) {
+	pool, _ := NewPriorityPool(1, 1, lessInt)
+	pool.Put(1)
+	sign := addExtraDatum(pool, 2)
+	select {
+	case err := <-sign:
+		t.Fatalf("Put into a full pool didn't block! (err: %v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if datum, _ := pool.Get(); datum.(int) != 1 {
+		t.Fatalf("Inconsistent datum: expected: %d, actual: %d", 1, datum)
+	}
+	if err := <-sign; err != nil {
+		t.Fatalf("An error occurs when putting a datum to the pool: %s", err)
+	}
+	pool.Get()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := pool.Get(); err != ErrClosedBufferPool {
+			t.Errorf("Inconsistent error after closing: expected: %s, actual: %v",
+				ErrClosedBufferPool, err)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if !pool.Close() {
+		t.Fatal("Couldn't close the pool!")
+	}
+	wg.Wait()
+	if pool.Close() {
+		t.Fatal("Closed the pool twice!")
+	}
+	if err := pool.Put(3); err != ErrClosedBufferPool {
+		t.Fatalf("Inconsistent error after closing: expected: %s, actual: %v",
+			ErrClosedBufferPool, err)
+	}
+}
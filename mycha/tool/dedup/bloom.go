@@ -0,0 +1,150 @@
+package dedup
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// DefaultFalsePositive 代表布隆过滤器默认的误判率。
+const DefaultFalsePositive = 0.001
+
+// NewBloomDeduper 用于创建一个基于布隆过滤器的去重器。
+// 参数expected代表预计记录的键的数量，
+// 参数falsePositive代表达到预计数量时可接受的误判率，为0时使用默认值。
+// 误判只会导致少量新链接被当作重复链接，而不会导致重复爬取。
+func NewBloomDeduper(expected uint64, falsePositive float64) (Deduper, error) {
+	if expected == 0 {
+		return nil, errors.New("dedup: zero expected number for bloom filter")
+	}
+	if falsePositive == 0 {
+		falsePositive = DefaultFalsePositive
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, fmt.Errorf("dedup: illegal false positive rate %v", falsePositive)
+	}
+	m := math.Ceil(-float64(expected) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(expected) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	words := (uint64(m) + 63) / 64
+	return &bloomDeduper{
+		bits: make([]uint64, words),
+		m:    words * 64,
+		k:    uint32(k),
+	}, nil
+}
+
+// bloomDeduper 代表基于布隆过滤器的去重器的实现类型。
+type bloomDeduper struct {
+	lock sync.RWMutex
+	// bits 代表位数组。
+	bits []uint64
+	// m 代表位数。
+	m uint64
+	// k 代表哈希函数的个数。
+	k uint32
+	// count 代表成功加入的键的数量。
+	count uint64
+}
+
+func (d *bloomDeduper) Type() string {
+	return TYPE_BLOOM
+}
+
+// hashes 用于计算双重哈希所需的两个基础哈希值。
+func hashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+	// 第二个哈希值必须是奇数，以保证各位置分布均匀。
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+func (d *bloomDeduper) Add(key string) bool {
+	h1, h2 := hashes(key)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	added := false
+	for i := uint32(0); i < d.k; i++ {
+		pos := (h1 + uint64(i)*h2) % d.m
+		word, mask := pos/64, uint64(1)<<(pos%64)
+		if d.bits[word]&mask == 0 {
+			d.bits[word] |= mask
+			added = true
+		}
+	}
+	if added {
+		d.count++
+	}
+	return added
+}
+
+func (d *bloomDeduper) Contains(key string) bool {
+	h1, h2 := hashes(key)
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for i := uint32(0); i < d.k; i++ {
+		pos := (h1 + uint64(i)*h2) % d.m
+		if d.bits[pos/64]&(uint64(1)<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *bloomDeduper) Len() uint64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.count
+}
+
+func (d *bloomDeduper) MemoryBytes() uint64 {
+	return uint64(len(d.bits)) * 8
+}
+
+// bloomHeaderLen 代表快照头部的长度：位数、哈希函数个数和键的数量。
+const bloomHeaderLen = 8 + 4 + 8
+
+// Snapshot 会导出位数组及其参数。
+func (d *bloomDeduper) Snapshot() ([]byte, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	data := make([]byte, bloomHeaderLen+len(d.bits)*8)
+	binary.BigEndian.PutUint64(data[0:], d.m)
+	binary.BigEndian.PutUint32(data[8:], d.k)
+	binary.BigEndian.PutUint64(data[12:], d.count)
+	for i, word := range d.bits {
+		binary.BigEndian.PutUint64(data[bloomHeaderLen+i*8:], word)
+	}
+	return data, nil
+}
+
+// Restore 会恢复位数组，快照的参数必须与当前过滤器一致。
+func (d *bloomDeduper) Restore(data []byte) error {
+	if len(data) < bloomHeaderLen {
+		return errors.New("dedup: truncated bloom snapshot")
+	}
+	m := binary.BigEndian.Uint64(data[0:])
+	k := binary.BigEndian.Uint32(data[8:])
+	count := binary.BigEndian.Uint64(data[12:])
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if m != d.m || k != d.k {
+		return fmt.Errorf("dedup: inconsistent bloom snapshot (m: %d, k: %d), expected (m: %d, k: %d)",
+			m, k, d.m, d.k)
+	}
+	if uint64(len(data)-bloomHeaderLen) != m/8 {
+		return errors.New("dedup: truncated bloom snapshot")
+	}
+	for i := range d.bits {
+		d.bits[i] = binary.BigEndian.Uint64(data[bloomHeaderLen+i*8:])
+	}
+	d.count = count
+	return nil
+}
@@ -0,0 +1,128 @@
+package dedup
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 去重器的类型。
+const (
+	// TYPE_MAP 代表基于哈希表的精确去重器。
+	TYPE_MAP = "map"
+	// TYPE_BLOOM 代表基于布隆过滤器的去重器。
+	TYPE_BLOOM = "bloom"
+)
+
+// Deduper 代表链接去重器的接口类型。
+type Deduper interface {
+	// Type 用于获取去重器的类型。
+	Type() string
+	// Add 用于记录一个键。
+	// 若该键之前已被记录（对布隆过滤器来说是可能已被记录）则返回false。
+	Add(key string) bool
+	// Contains 用于判断一个键是否已被记录。
+	Contains(key string) bool
+	// Len 用于获取已记录的键的数量。
+	Len() uint64
+	// MemoryBytes 用于获取去重器占用内存的估计值。
+	MemoryBytes() uint64
+	// Snapshot 用于导出去重器的状态，以便保存到检查点。
+	Snapshot() ([]byte, error)
+	// Restore 用于从导出的状态恢复去重器。
+	Restore(data []byte) error
+}
+
+// New 用于根据类型创建去重器。
+// 参数expected和falsePositive只对布隆过滤器有效。
+func New(dedupType string, expected uint64, falsePositive float64) (Deduper, error) {
+	switch dedupType {
+	case "", TYPE_MAP:
+		return NewMapDeduper(), nil
+	case TYPE_BLOOM:
+		return NewBloomDeduper(expected, falsePositive)
+	default:
+		return nil, fmt.Errorf("dedup: unsupported deduper type %q", dedupType)
+	}
+}
+
+// mapEntryOverhead 代表哈希表中每个键除字符串内容以外的大致开销。
+const mapEntryOverhead = 48
+
+// NewMapDeduper 用于创建一个基于哈希表的精确去重器。
+func NewMapDeduper() Deduper {
+	return &mapDeduper{keys: map[string]struct{}{}}
+}
+
+// mapDeduper 代表基于哈希表的去重器的实现类型。
+type mapDeduper struct {
+	lock     sync.RWMutex
+	keys     map[string]struct{}
+	keyBytes uint64
+}
+
+func (d *mapDeduper) Type() string {
+	return TYPE_MAP
+}
+
+func (d *mapDeduper) Add(key string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.keys[key]; ok {
+		return false
+	}
+	d.keys[key] = struct{}{}
+	d.keyBytes += uint64(len(key))
+	return true
+}
+
+func (d *mapDeduper) Contains(key string) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	_, ok := d.keys[key]
+	return ok
+}
+
+func (d *mapDeduper) Len() uint64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return uint64(len(d.keys))
+}
+
+func (d *mapDeduper) MemoryBytes() uint64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.keyBytes + uint64(len(d.keys))*mapEntryOverhead
+}
+
+// Snapshot 会把全部的键按行导出。
+func (d *mapDeduper) Snapshot() ([]byte, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	var buf bytes.Buffer
+	for key := range d.keys {
+		buf.WriteString(key)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *mapDeduper) Restore(data []byte) error {
+	keys := map[string]struct{}{}
+	var keyBytes uint64
+	for _, key := range strings.Split(string(data), "\n") {
+		if key == "" {
+			continue
+		}
+		if _, ok := keys[key]; !ok {
+			keys[key] = struct{}{}
+			keyBytes += uint64(len(key))
+		}
+	}
+	d.lock.Lock()
+	d.keys = keys
+	d.keyBytes = keyBytes
+	d.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,94 @@
+package dedup
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"HTTP://Example.COM:80/a?b=2&a=1#frag": "http://example.com/a?a=1&b=2",
+		"https://example.com:443":              "https://example.com/",
+		"https://example.com:8443/x":           "https://example.com:8443/x",
+		"http://example.com/A?a=1&&a=0":        "http://example.com/A?a=0&a=1",
+		"http://[::1]:80/":                     "http://[::1]/",
+	}
+	for raw, expected := range cases {
+		actual, err := NormalizeString(raw)
+		if err != nil {
+			t.Fatalf("An error occurs when normalizing %q: %s", raw, err)
+		}
+		if actual != expected {
+			t.Fatalf("Inconsistent normalized URL for %q: expected: %s, actual: %s",
+				raw, expected, actual)
+		}
+	}
+}
+
+func testDeduper(t *testing.T, d Deduper) {
+	number := 1000
+	for i := 0; i < number; i++ {
+		if !d.Add(fmt.Sprintf("http://example.com/%d", i)) {
+			t.Fatalf("Couldn't add key %d to %s deduper!", i, d.Type())
+		}
+	}
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://example.com/%d", i)
+		if d.Add(key) {
+			t.Fatalf("Duplicate key %q is added to %s deduper!", key, d.Type())
+		}
+		if !d.Contains(key) {
+			t.Fatalf("Key %q is missing in %s deduper!", key, d.Type())
+		}
+	}
+	if d.Len() != uint64(number) {
+		t.Fatalf("Inconsistent length for %s deduper: expected: %d, actual: %d",
+			d.Type(), number, d.Len())
+	}
+	if d.MemoryBytes() == 0 {
+		t.Fatalf("Zero memory bytes for %s deduper!", d.Type())
+	}
+	data, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("An error occurs when taking snapshot of %s deduper: %s", d.Type(), err)
+	}
+	restored, _ := New(d.Type(), uint64(number), 0)
+	if err = restored.Restore(data); err != nil {
+		t.Fatalf("An error occurs when restoring %s deduper: %s", d.Type(), err)
+	}
+	if restored.Len() != d.Len() {
+		t.Fatalf("Inconsistent length for restored %s deduper: expected: %d, actual: %d",
+			d.Type(), d.Len(), restored.Len())
+	}
+	if !restored.Contains("http://example.com/1") {
+		t.Fatalf("Key is missing in restored %s deduper!", d.Type())
+	}
+}
+
+func TestMapDeduper(t *testing.T) {
+	testDeduper(t, NewMapDeduper())
+}
+
+func TestBloomDeduper(t *testing.T) {
+	d, err := NewBloomDeduper(1000, 0)
+	if err != nil {
+		t.Fatalf("An error occurs when new a bloom deduper: %s", err)
+	}
+	testDeduper(t, d)
+	var falsePositives int
+	tries := 10000
+	for i := 0; i < tries; i++ {
+		if d.Contains(fmt.Sprintf("http://example.org/%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / float64(tries); rate > DefaultFalsePositive*5 {
+		t.Fatalf("Too high false positive rate: %v", rate)
+	}
+	if _, err = NewBloomDeduper(0, 0.01); err == nil {
+		t.Fatal("No error when new a bloom deduper with zero expected number!")
+	}
+	if _, err = NewBloomDeduper(10, 1.5); err == nil {
+		t.Fatal("No error when new a bloom deduper with illegal false positive rate!")
+	}
+}
@@ -0,0 +1,79 @@
+package dedup
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultPorts 代表各协议的默认端口。
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize 用于把链接规范化为去重使用的键。
+// 规则如下：
+//  1. 协议和主机名转为小写，并去掉协议的默认端口。
+//  2. 去掉片段（#之后的部分）。
+//  3. 空路径视为“/”。
+//  4. 查询参数按名称和值排序。
+func Normalize(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if defaultPorts[scheme] == port {
+			host = h
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+		}
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteString("@")
+	}
+	b.WriteString(host)
+	b.WriteString(path)
+	if query := normalizeQuery(u.RawQuery); query != "" {
+		b.WriteString("?")
+		b.WriteString(query)
+	}
+	return b.String()
+}
+
+// NormalizeString 用于规范化字符串形式的链接。
+func NormalizeString(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return Normalize(u), nil
+}
+
+// normalizeQuery 用于对查询参数排序，无法解析时保持原样。
+func normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if pair != "" {
+			kept = append(kept, pair)
+		}
+	}
+	sort.Strings(kept)
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,241 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mycha/module"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 导出文件的格式。
+const (
+	FORMAT_JSON_LINES = "jsonl"
+	FORMAT_CSV        = "csv"
+)
+
+// defaultFlushInterval 代表默认的刷新间隔。
+const defaultFlushInterval = time.Second
+
+// Options 代表导出器的选项。
+type Options struct {
+	// Path 代表输出文件的路径，开启压缩且路径不以.gz结尾时会自动加上.gz。
+	// 路径相同的导出器会共用同一个文件，可以在多个条目处理管道中同时使用，
+	// 这些导出器的格式、列、MaxSize、MaxAge、Gzip和FlushInterval都必须相同。
+	// 文件已经存在时会在末尾追加，CSV文件开头的列名必须与当前的列相同。
+	Path string
+	// MaxSize 代表文件大小的上限，单位为字节，达到后会轮转文件，为0时不限制。
+	// 开启压缩时为压缩后的大小。
+	MaxSize int64
+	// MaxAge 代表文件的最长使用时间，达到后会轮转文件，为0时不限制。
+	MaxAge time.Duration
+	// Gzip 代表是否用gzip压缩输出。
+	Gzip bool
+	// FlushInterval 代表把缓冲的数据写入文件的间隔，为0时为1秒。
+	FlushInterval time.Duration
+	// Columns 代表CSV文件的列，条目中不在其中的键会被忽略。
+	// 为空时使用第一个条目中的键并排序，之后的条目中有其他的键时会返回错误。
+	Columns []string
+}
+
+func (options Options) flushInterval() time.Duration {
+	if options.FlushInterval <= 0 {
+		return defaultFlushInterval
+	}
+	return options.FlushInterval
+}
+
+// Exporter 代表把条目写入文件的导出器。
+// 轮转后的文件会被改名为带时间戳的文件，比如items-20060102-150405.jsonl。
+type Exporter struct {
+	format  string
+	options Options
+	// lock 用于保护file、columns和closed。
+	lock sync.Mutex
+	// file 代表输出文件，CSV导出器在写入第一个条目时才会打开文件。
+	file *rotatingFile
+	// columns 代表CSV文件的列。
+	columns []string
+	// inferred 代表CSV文件的列是否是根据第一个条目确定的。
+	inferred bool
+	// closed 代表导出器是否已被关闭。
+	closed bool
+}
+
+// NewJSONLines 用于创建一个以JSON Lines格式导出条目的导出器。
+func NewJSONLines(options Options) (*Exporter, error) {
+	file, err := acquireFile(options, FORMAT_JSON_LINES, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{format: FORMAT_JSON_LINES, options: options, file: file}, nil
+}
+
+// NewCSV 用于创建一个以CSV格式导出条目的导出器。
+// 每个文件的第一行为列名。
+func NewCSV(options Options) (*Exporter, error) {
+	exporter := &Exporter{format: FORMAT_CSV, options: options}
+	if len(options.Columns) > 0 {
+		if err := exporter.openCSV(options.Columns); err != nil {
+			return nil, err
+		}
+	} else if options.Path == "" {
+		return nil, fmt.Errorf("empty output path")
+	}
+	return exporter, nil
+}
+
+// openCSV 用于确定CSV文件的列并打开文件。
+func (exporter *Exporter) openCSV(columns []string) error {
+	header, err := csvRecord(columns)
+	if err != nil {
+		return err
+	}
+	file, err := acquireFile(exporter.options, FORMAT_CSV, header)
+	if err != nil {
+		return err
+	}
+	exporter.columns = columns
+	exporter.file = file
+	return nil
+}
+
+// Process 用于导出条目，并原样返回条目，以便之后的条目处理器继续处理。
+func (exporter *Exporter) Process(item module.Item) (module.Item, error) {
+	if item == nil {
+		return nil, fmt.Errorf("nil item")
+	}
+	var record []byte
+	var err error
+	switch exporter.format {
+	case FORMAT_JSON_LINES:
+		if record, err = json.Marshal(item); err == nil {
+			record = append(record, '\n')
+		}
+	case FORMAT_CSV:
+		var columns []string
+		if columns, err = exporter.csvColumns(item); err != nil {
+			return nil, err
+		}
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = csvValue(item[column])
+		}
+		record, err = csvRecord(values)
+	}
+	if err != nil {
+		return nil, err
+	}
+	exporter.lock.Lock()
+	file := exporter.file
+	exporter.lock.Unlock()
+	if file == nil {
+		return nil, fmt.Errorf("exporter is closed")
+	}
+	if err = file.write(record); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// csvColumns 用于获取CSV文件的列。
+// 没有指定列时，会根据第一个条目确定列并打开文件。
+func (exporter *Exporter) csvColumns(item module.Item) ([]string, error) {
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+	if exporter.closed {
+		return nil, fmt.Errorf("exporter is closed")
+	}
+	if exporter.columns != nil {
+		if exporter.inferred {
+			// 根据第一个条目确定的列可能不完整，不能像指定的列那样忽略其他的键。
+			for key := range item {
+				if !containsString(exporter.columns, key) {
+					return nil, fmt.Errorf("key %q is not in the columns %q inferred from the first item",
+						key, exporter.columns)
+				}
+			}
+		}
+		return exporter.columns, nil
+	}
+	columns := make([]string, 0, len(item))
+	for key := range item {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	if err := exporter.openCSV(columns); err != nil {
+		return nil, err
+	}
+	exporter.inferred = true
+	return columns, nil
+}
+
+// containsString 用于判断字符串是否在有序的列表中。
+func containsString(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
+
+// Flush 用于把缓冲的数据写入文件。
+func (exporter *Exporter) Flush() error {
+	exporter.lock.Lock()
+	file := exporter.file
+	exporter.lock.Unlock()
+	if file == nil {
+		return nil
+	}
+	return file.flush()
+}
+
+// Close 用于关闭导出器。
+// 共用同一个文件的导出器都关闭后，文件才会被关闭。
+func (exporter *Exporter) Close() error {
+	exporter.lock.Lock()
+	file := exporter.file
+	exporter.file = nil
+	exporter.closed = true
+	exporter.lock.Unlock()
+	if file == nil {
+		return nil
+	}
+	return file.release()
+}
+
+// csvRecord 用于生成CSV格式的一行。
+func csvRecord(values []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(values); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvValue 用于把条目中的值转换为CSV中的字符串。
+// 时间会被转换为RFC3339格式，列表和映射会被转换为JSON。
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return fmt.Sprint(v)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
@@ -0,0 +1,292 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mycha/module"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readLines 用于读取目录中所有文件的内容，按行返回。
+func readLines(t *testing.T, dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("An error occurs when listing files: %s", err)
+	}
+	var lines []string
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("An error occurs when opening %s: %s", path, err)
+		}
+		var r io.Reader = file
+		if filepath.Ext(path) == ".gz" {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatalf("An error occurs when decompressing %s: %s", path, err)
+			}
+			r = gz
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("An error occurs when reading %s: %s", path, err)
+		}
+		file.Close()
+	}
+	return lines
+}
+
+func TestJSONLinesConcurrent(t *testing.T) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	options := Options{Path: filepath.Join(dir, "items.jsonl"), MaxSize: 512}
+	// 模拟多个条目处理管道各自创建导出器的情况。
+	exporters := make([]*Exporter, 4)
+	for i := range exporters {
+		if exporters[i], err = NewJSONLines(options); err != nil {
+			t.Fatalf("An error occurs when creating the exporter: %s", err)
+		}
+	}
+	var wg sync.WaitGroup
+	number := 100
+	for i, exporter := range exporters {
+		wg.Add(1)
+		go func(i int, exporter *Exporter) {
+			defer wg.Done()
+			for j := 0; j < number; j++ {
+				item := module.Item{"pipeline": i, "seq": j}
+				if _, err := exporter.Process(item); err != nil {
+					t.Errorf("An error occurs when exporting the item: %s", err)
+				}
+			}
+		}(i, exporter)
+	}
+	wg.Wait()
+	for _, exporter := range exporters {
+		if err := exporter.Close(); err != nil {
+			t.Fatalf("An error occurs when closing the exporter: %s", err)
+		}
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "items-*.jsonl"))
+	if len(paths) == 0 {
+		t.Fatalf("The file is not rotated")
+	}
+	lines := readLines(t, dir)
+	if len(lines) != number*len(exporters) {
+		t.Fatalf("Inconsistent number of lines: expected: %d, actual: %d",
+			number*len(exporters), len(lines))
+	}
+	seen := map[[2]int]bool{}
+	for _, line := range lines {
+		var item struct{ Pipeline, Seq int }
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.Fatalf("Invalid line %q: %s", line, err)
+		}
+		seen[[2]int{item.Pipeline, item.Seq}] = true
+	}
+	if len(seen) != number*len(exporters) {
+		t.Fatalf("Inconsistent number of distinct items: expected: %d, actual: %d",
+			number*len(exporters), len(seen))
+	}
+}
+
+func TestCSVGzip(t *testing.T) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	exporter, err := NewCSV(Options{Path: filepath.Join(dir, "items.csv"), Gzip: true})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the exporter: %s", err)
+	}
+	published := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := []module.Item{
+		{"title": "Go, in Action", "price": 12.5, "tags": []interface{}{"a", "b"}, "published": published},
+		{"title": "Untitled"},
+	}
+	for _, item := range items {
+		if _, err := exporter.Process(item); err != nil {
+			t.Fatalf("An error occurs when exporting the item: %s", err)
+		}
+	}
+	// 列是根据第一个条目确定的，其他的键不能被悄悄丢弃。
+	if _, err := exporter.Process(module.Item{"title": "Extra", "extra": "x"}); err == nil {
+		t.Fatalf("No error when exporting an item with a key out of the inferred columns")
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the exporter: %s", err)
+	}
+	if _, err := exporter.Process(items[0]); err == nil {
+		t.Fatalf("No error when exporting with a closed exporter")
+	}
+	file, err := os.Open(filepath.Join(dir, "items.csv.gz"))
+	if err != nil {
+		t.Fatalf("An error occurs when opening the output: %s", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("An error occurs when decompressing the output: %s", err)
+	}
+	records, err := csv.NewReader(gz).ReadAll()
+	if err != nil {
+		t.Fatalf("An error occurs when reading the output: %s", err)
+	}
+	expected := [][]string{
+		{"price", "published", "tags", "title"},
+		{"12.5", "2016-01-02T03:04:05Z", `["a","b"]`, "Go, in Action"},
+		{"", "", "", "Untitled"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Inconsistent number of records: expected: %d, actual: %d", len(expected), len(records))
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if records[i][j] != expected[i][j] {
+				t.Fatalf("Inconsistent record[%d]: expected: %q, actual: %q", i, expected[i], records[i])
+			}
+		}
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	exporter, err := NewCSV(Options{
+		Path:    filepath.Join(dir, "items.csv"),
+		Columns: []string{"id"},
+		MaxAge:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the exporter: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := exporter.Process(module.Item{"id": i}); err != nil {
+			t.Fatalf("An error occurs when exporting the item: %s", err)
+		}
+		time.Sleep(60 * time.Millisecond)
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the exporter: %s", err)
+	}
+	lines := readLines(t, dir)
+	sort.Strings(lines)
+	// 每个文件都以列名开头。
+	expected := []string{"0", "1", "2", "id", "id", "id"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Inconsistent lines: expected: %q, actual: %q", expected, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Fatalf("Inconsistent lines: expected: %q, actual: %q", expected, lines)
+		}
+	}
+}
+
+func TestSharedPathFormat(t *testing.T) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "items.out")
+	jsonExporter, err := NewJSONLines(Options{Path: path})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the exporter: %s", err)
+	}
+	defer jsonExporter.Close()
+	if _, err := NewCSV(Options{Path: path, Columns: []string{"a"}}); err == nil {
+		t.Fatalf("No error when sharing a path between different formats")
+	}
+	if _, err := NewJSONLines(Options{}); err == nil {
+		t.Fatalf("No error when creating an exporter without path")
+	}
+}
+
+func TestSharedPathOptions(t *testing.T) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	options := Options{Path: filepath.Join(dir, "items.jsonl.gz"), MaxSize: 1024, Gzip: true}
+	exporter, err := NewJSONLines(options)
+	if err != nil {
+		t.Fatalf("An error occurs when creating the exporter: %s", err)
+	}
+	defer exporter.Close()
+	another, err := NewJSONLines(options)
+	if err != nil {
+		t.Fatalf("An error occurs when sharing the path with the same options: %s", err)
+	}
+	another.Close()
+	for i, change := range []func(o *Options){
+		func(o *Options) { o.MaxSize = 2048 },
+		func(o *Options) { o.MaxAge = time.Hour },
+		func(o *Options) { o.Gzip = false },
+		func(o *Options) { o.FlushInterval = time.Minute },
+	} {
+		o := options
+		change(&o)
+		if _, err := NewJSONLines(o); err == nil {
+			t.Fatalf("No error when sharing the path with other options[%d]: %+v", i, o)
+		}
+	}
+}
+
+func TestCSVAppend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, gz := range []bool{false, true} {
+		options := Options{Path: filepath.Join(dir, "items.csv"), Columns: []string{"a", "b"}, Gzip: gz}
+		for i := 0; i < 2; i++ {
+			exporter, err := NewCSV(options)
+			if err != nil {
+				t.Fatalf("An error occurs when creating the exporter (gzip: %v): %s", gz, err)
+			}
+			if _, err := exporter.Process(module.Item{"a": i, "b": i}); err != nil {
+				t.Fatalf("An error occurs when exporting the item: %s", err)
+			}
+			if err := exporter.Close(); err != nil {
+				t.Fatalf("An error occurs when closing the exporter: %s", err)
+			}
+		}
+		options.Columns = []string{"a", "c"}
+		if _, err := NewCSV(options); err == nil {
+			t.Fatalf("No error when appending to a file with other columns (gzip: %v)", gz)
+		}
+	}
+	lines := readLines(t, dir)
+	sort.Strings(lines)
+	// 追加时不会再写入列名。
+	expected := []string{"0,0", "0,0", "1,1", "1,1", "a,b", "a,b"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Inconsistent lines: expected: %q, actual: %q", expected, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Fatalf("Inconsistent lines: expected: %q, actual: %q", expected, lines)
+		}
+	}
+}
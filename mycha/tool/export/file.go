@@ -0,0 +1,287 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFile 代表会按大小和时间轮转的输出文件。
+// 路径相同的导出器共用同一个实例，写入时会加锁。
+type rotatingFile struct {
+	path    string
+	options Options
+	// format 代表文件的格式，路径相同的导出器的格式必须相同。
+	format string
+	// header 代表每个新文件开头需要写入的内容，比如CSV的列名。
+	header []byte
+	lock   sync.Mutex
+	file   *os.File
+	// counter 代表写入文件的字节数，开启压缩时为压缩后的字节数。
+	counter *countingWriter
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	// openedAt 代表当前文件的打开时间。
+	openedAt time.Time
+	// refs 代表共用该实例的导出器的数量。
+	refs int
+	// stop 会在文件被最终关闭时关闭，用于停止定时刷新。
+	stop chan struct{}
+	// closed 代表文件是否已被最终关闭。
+	closed bool
+}
+
+// files 代表所有打开着的输出文件，键为绝对路径。
+var (
+	files     = map[string]*rotatingFile{}
+	filesLock sync.Mutex
+)
+
+// acquireFile 用于打开或共用输出文件。
+func acquireFile(options Options, format string, header []byte) (*rotatingFile, error) {
+	if options.Path == "" {
+		return nil, fmt.Errorf("empty output path")
+	}
+	path, err := filepath.Abs(options.Path)
+	if err != nil {
+		return nil, err
+	}
+	if options.Gzip && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+	}
+	filesLock.Lock()
+	defer filesLock.Unlock()
+	if f, ok := files[path]; ok {
+		if f.format != format || string(f.header) != string(header) {
+			return nil, fmt.Errorf("%s is already used by an exporter with another format", path)
+		}
+		if !f.sameOptions(options) {
+			return nil, fmt.Errorf("%s is already used by an exporter with other options", path)
+		}
+		f.refs++
+		return f, nil
+	}
+	f := &rotatingFile{
+		path:    path,
+		options: options,
+		format:  format,
+		header:  header,
+		refs:    1,
+		stop:    make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	files[path] = f
+	go f.flushPeriodically(options.flushInterval())
+	return f, nil
+}
+
+// sameOptions 用于判断选项中与文件相关的部分是否与该文件的相同。
+func (f *rotatingFile) sameOptions(options Options) bool {
+	return options.MaxSize == f.options.MaxSize &&
+		options.MaxAge == f.options.MaxAge &&
+		options.Gzip == f.options.Gzip &&
+		options.flushInterval() == f.options.flushInterval()
+}
+
+// checkHeader 用于检查已有文件的开头是否与header相同。
+func (f *rotatingFile) checkHeader() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if f.options.Gzip {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	head := make([]byte, len(f.header))
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != string(f.header) {
+		return fmt.Errorf("%s does not begin with %q", f.path, f.header)
+	}
+	return nil
+}
+
+// open 用于以追加的方式打开当前文件。
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if info.Size() > 0 && len(f.header) > 0 {
+		// 追加到已有的文件时不再写入列名，所以已有的列名必须相同。
+		if err := f.checkHeader(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	f.file = file
+	f.counter = &countingWriter{w: file, n: info.Size()}
+	var w io.Writer = f.counter
+	if f.options.Gzip {
+		// 追加到已有的压缩文件时会形成多个gzip成员，gzip工具可以正常解压。
+		f.gz = gzip.NewWriter(f.counter)
+		w = f.gz
+	}
+	f.buf = bufio.NewWriter(w)
+	f.openedAt = time.Now()
+	if info.Size() == 0 && len(f.header) > 0 {
+		if _, err := f.buf.Write(f.header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write 用于写入一条记录，需要时会先轮转文件。
+func (f *rotatingFile) write(record []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return fmt.Errorf("%s is closed", f.path)
+	}
+	if f.needRotate() {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	_, err := f.buf.Write(record)
+	return err
+}
+
+// needRotate 用于判断当前文件是否需要轮转。
+func (f *rotatingFile) needRotate() bool {
+	if f.options.MaxAge > 0 && time.Since(f.openedAt) >= f.options.MaxAge {
+		return f.size() > int64(len(f.header))
+	}
+	return f.options.MaxSize > 0 && f.size() >= f.options.MaxSize
+}
+
+// size 用于获取当前文件的大致大小，包括还在缓冲区中的数据。
+func (f *rotatingFile) size() int64 {
+	return f.counter.n + int64(f.buf.Buffered())
+}
+
+// rotate 用于把当前文件改名为带时间戳的文件，并打开一个新的文件。
+func (f *rotatingFile) rotate() error {
+	if err := f.closeCurrent(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.rotatedPath(time.Now())); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// rotatedPath 用于生成轮转后的文件路径，比如items-20060102-150405.jsonl.gz。
+func (f *rotatingFile) rotatedPath(now time.Time) string {
+	dir, name := filepath.Split(f.path)
+	base, ext := name, ""
+	if i := strings.Index(name[1:], "."); i >= 0 {
+		base, ext = name[:i+1], name[i+1:]
+	}
+	stamp := now.Format("20060102-150405")
+	path := filepath.Join(dir, base+"-"+stamp+ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%s-%d%s", base, stamp, i, ext))
+	}
+}
+
+// flush 用于把缓冲区中的数据写入文件。
+func (f *rotatingFile) flush() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return nil
+	}
+	return f.flushLocked()
+}
+
+func (f *rotatingFile) flushLocked() error {
+	if err := f.buf.Flush(); err != nil {
+		return err
+	}
+	if f.gz != nil {
+		return f.gz.Flush()
+	}
+	return nil
+}
+
+// flushPeriodically 用于定时刷新缓冲区，直到文件被最终关闭。
+func (f *rotatingFile) flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.flush()
+		}
+	}
+}
+
+// closeCurrent 用于关闭当前文件。
+func (f *rotatingFile) closeCurrent() error {
+	err := f.buf.Flush()
+	if f.gz != nil {
+		if gzErr := f.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// release 用于释放对输出文件的引用，最后一个引用释放时文件会被关闭。
+func (f *rotatingFile) release() error {
+	filesLock.Lock()
+	defer filesLock.Unlock()
+	f.refs--
+	if f.refs > 0 {
+		return f.flush()
+	}
+	delete(files, f.path)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+	close(f.stop)
+	return f.closeCurrent()
+}
+
+// countingWriter 代表会统计写入的字节数的写入器。
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 字段值的类型。
+const (
+	// TYPE_AUTO 代表保持提取到的值，HTML中的值为字符串，JSON中的值保持原有的类型。
+	TYPE_AUTO = ""
+	// TYPE_STRING 代表字符串。
+	TYPE_STRING = "string"
+	// TYPE_INT 代表整数，值的类型为int64。
+	TYPE_INT = "int"
+	// TYPE_FLOAT 代表浮点数，值的类型为float64。
+	TYPE_FLOAT = "float"
+	// TYPE_BOOL 代表布尔值。
+	TYPE_BOOL = "bool"
+	// TYPE_TIME 代表时间，值的类型为time.Time。
+	TYPE_TIME = "time"
+)
+
+// validType 用于判断类型是否受支持。
+func validType(t string) bool {
+	switch t {
+	case TYPE_AUTO, TYPE_STRING, TYPE_INT, TYPE_FLOAT, TYPE_BOOL, TYPE_TIME:
+		return true
+	}
+	return false
+}
+
+// coerce 用于把提取到的值转换为指定的类型。
+// 参数layout代表时间的格式，仅在类型为TYPE_TIME时使用。
+func coerce(value interface{}, t string, layout string) (interface{}, error) {
+	if number, ok := value.(json.Number); ok {
+		value = normalizeNumber(number)
+	}
+	switch t {
+	case TYPE_AUTO:
+		return value, nil
+	case TYPE_STRING:
+		return toString(value), nil
+	case TYPE_INT:
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v != float64(int64(v)) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			return int64(v), nil
+		case string:
+			return strconv.ParseInt(cleanNumber(v), 10, 64)
+		}
+	case TYPE_FLOAT:
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			return strconv.ParseFloat(cleanNumber(v), 64)
+		}
+	case TYPE_BOOL:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(v))
+		}
+	case TYPE_TIME:
+		if v, ok := value.(string); ok {
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			return time.Parse(layout, strings.TrimSpace(v))
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", value, t)
+}
+
+// normalizeNumber 用于把JSON数值转换为int64或float64。
+func normalizeNumber(number json.Number) interface{} {
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return number.String()
+}
+
+// cleanNumber 用于去掉数字中的空白和千位分隔符。
+func cleanNumber(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '\t' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// toString 用于把值转换为字符串，对象和数组会被转换为JSON。
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// sortedKeys 用于获取排好序的键的列表。
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,320 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mycha/module"
+	"mycha/tool/htmlquery"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Field 代表条目中一个字段的提取规则。
+// Selector和JSONPath分别用于HTML和JSON响应，Regexp会作用于它们取得的值；
+// 只设置了Regexp时，会作用于条目所在的整段内容。
+type Field struct {
+	// Name 代表字段名。
+	Name string
+	// Selector 代表CSS选择器或XPath（以"/"开头），相对于条目所在的元素。
+	Selector string
+	// Attr 代表取值的属性名，为空时取元素的文本。
+	Attr string
+	// JSONPath 代表JSON路径，其中的"$"代表条目所在的值。
+	JSONPath string
+	// Regexp 代表正则表达式，有子匹配时取第一个子匹配，否则取整个匹配。
+	Regexp string
+	// Type 代表值的类型，即TYPE_开头的常量之一。
+	Type string
+	// Layout 代表时间的格式，为空时为time.RFC3339。
+	Layout string
+	// Multiple 代表是否取所有的值组成列表，否则只取第一个值。
+	Multiple bool
+	// Required 代表字段是否必须有值，没有值的条目会被丢弃并报告错误。
+	Required bool
+	// Default 代表字段没有值时使用的默认值，为nil时不设置该字段。
+	Default interface{}
+}
+
+// Schema 代表条目的提取规则。
+type Schema struct {
+	// Root 代表条目所在的位置，以"$"开头时为JSON路径，否则为CSS选择器或XPath。
+	// 每个匹配的元素或值都会生成一个条目，为空时整个响应生成一个条目。
+	Root string
+	// Fields 代表字段的提取规则。
+	Fields []Field
+	// URLField 代表存放响应链接的字段名，为空时不存放。
+	URLField string
+	// Kind 代表条目的种类，为空时不设置。
+	Kind string
+}
+
+// field 代表编译后的字段提取规则。
+type field struct {
+	Field
+	selector htmlquery.Selector
+	jsonPath *jsonPath
+	regexp   *regexp.Regexp
+}
+
+// Extractor 代表条目提取器。
+type Extractor struct {
+	rootSelector htmlquery.Selector
+	rootJSONPath *jsonPath
+	fields       []*field
+	urlField     string
+	kind         string
+}
+
+// New 用于根据规则创建一个条目提取器。
+func New(schema Schema) (*Extractor, error) {
+	if len(schema.Fields) == 0 {
+		return nil, errors.New("empty field list")
+	}
+	ext := &Extractor{urlField: schema.URLField, kind: schema.Kind}
+	var err error
+	if root := strings.TrimSpace(schema.Root); strings.HasPrefix(root, "$") {
+		ext.rootJSONPath, err = compileJSONPath(root)
+	} else if root != "" {
+		ext.rootSelector, err = htmlquery.Compile(root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for i, f := range schema.Fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("empty name of field[%d]", i)
+		}
+		if names[f.Name] {
+			return nil, fmt.Errorf("duplicate field: %s", f.Name)
+		}
+		names[f.Name] = true
+		if f.Selector == "" && f.JSONPath == "" && f.Regexp == "" {
+			return nil, fmt.Errorf("field %s: no selector, JSON path or regexp", f.Name)
+		}
+		if !validType(f.Type) {
+			return nil, fmt.Errorf("field %s: unsupported type %q", f.Name, f.Type)
+		}
+		compiled := &field{Field: f}
+		if f.Selector != "" {
+			if compiled.selector, err = htmlquery.Compile(f.Selector); err != nil {
+				return nil, fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		}
+		if f.JSONPath != "" {
+			if compiled.jsonPath, err = compileJSONPath(f.JSONPath); err != nil {
+				return nil, fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		}
+		if f.Regexp != "" {
+			if compiled.regexp, err = regexp.Compile(f.Regexp); err != nil {
+				return nil, fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		}
+		ext.fields = append(ext.fields, compiled)
+	}
+	return ext, nil
+}
+
+// Parse 用于从响应中提取条目，通常作为分析器的路由规则中的Parser，
+// 这样只有符合条件的页面才会按模式提取。
+func (ext *Extractor) Parse(resp *module.Response) ([]module.Data, []error) {
+	items, errs := ext.Extract(resp)
+	dataList := make([]module.Data, 0, len(items))
+	for _, item := range items {
+		dataList = append(dataList, item)
+	}
+	return dataList, errs
+}
+
+// Extract 用于从响应中提取条目，响应的内容不是文本时返回nil。
+// 请求带有条目时，提取到的条目会以它为基础，字段同名时以提取到的值为准。
+func (ext *Extractor) Extract(resp *module.Response) ([]module.Item, []error) {
+	if resp == nil || resp.HTTPResp() == nil {
+		return nil, []error{errors.New("nil response")}
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.Body == nil || !resp.IsText() {
+		return nil, nil
+	}
+	// 按检测到的字符集转换为UTF-8。
+	text, err := resp.Text()
+	if err != nil {
+		return nil, []error{err}
+	}
+	body := []byte(text)
+	var pageURL string
+	if httpResp.Request != nil && httpResp.Request.URL != nil {
+		pageURL = httpResp.Request.URL.String()
+	}
+	var scopes []scope
+	if isJSON(httpResp.Header.Get("Content-Type"), body) {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, []error{fmt.Errorf("invalid JSON (URL: %s): %s", pageURL, err)}
+		}
+		scopes = ext.jsonScopes(doc, body)
+	} else {
+		doc, err := html.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, []error{fmt.Errorf("invalid HTML (URL: %s): %s", pageURL, err)}
+		}
+		scopes = ext.htmlScopes(doc, body)
+	}
+	var items []module.Item
+	var errs []error
+	for _, s := range scopes {
+		item, itemErrs := ext.extractItem(s, resp.Item())
+		for _, err := range itemErrs {
+			errs = append(errs, fmt.Errorf("%s (URL: %s)", err, pageURL))
+		}
+		if item == nil {
+			continue
+		}
+		if ext.urlField != "" {
+			item[ext.urlField] = pageURL
+		}
+		if ext.kind != "" {
+			item.SetKind(ext.kind)
+		}
+		items = append(items, item)
+	}
+	return items, errs
+}
+
+// isJSON 用于判断响应的内容是否为JSON。
+// 没有内容类型时，会根据内容的第一个非空白字符判断。
+func isJSON(contentType string, body []byte) bool {
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return false
+		}
+		return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	}
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+// scope 代表一个条目所在的内容，node和value有且只有一个不为空。
+type scope struct {
+	node  *html.Node
+	value interface{}
+	// raw 代表整段内容的文本，用于只设置了Regexp的字段。
+	raw string
+}
+
+// htmlScopes 用于找出HTML文档中条目所在的元素。
+func (ext *Extractor) htmlScopes(doc *html.Node, body []byte) []scope {
+	if ext.rootSelector == nil {
+		if ext.rootJSONPath != nil {
+			return nil
+		}
+		return []scope{{node: doc, raw: string(body)}}
+	}
+	var scopes []scope
+	for _, n := range ext.rootSelector.Select(doc) {
+		var buf bytes.Buffer
+		html.Render(&buf, n)
+		scopes = append(scopes, scope{node: n, raw: buf.String()})
+	}
+	return scopes
+}
+
+// jsonScopes 用于找出JSON文档中条目所在的值。
+func (ext *Extractor) jsonScopes(doc interface{}, body []byte) []scope {
+	if ext.rootJSONPath == nil {
+		if ext.rootSelector != nil {
+			return nil
+		}
+		return []scope{{value: doc, raw: string(body)}}
+	}
+	var scopes []scope
+	for _, value := range ext.rootJSONPath.eval(doc) {
+		scopes = append(scopes, scope{value: value, raw: toString(value)})
+	}
+	return scopes
+}
+
+// extractItem 用于从条目所在的内容中提取条目。
+// 缺少必需的字段时返回的条目为nil。
+func (ext *Extractor) extractItem(s scope, base module.Item) (module.Item, []error) {
+	item := module.Item{}
+	for key, value := range base {
+		item[key] = value
+	}
+	var errs []error
+	missing := false
+	for _, f := range ext.fields {
+		var values []interface{}
+		for _, raw := range f.rawValues(s) {
+			value, err := coerce(raw, f.Type, f.Layout)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("field %s: %s", f.Name, err))
+				continue
+			}
+			values = append(values, value)
+			if !f.Multiple {
+				break
+			}
+		}
+		switch {
+		case len(values) > 0 && f.Multiple:
+			item[f.Name] = values
+		case len(values) > 0:
+			item[f.Name] = values[0]
+		case f.Default != nil:
+			item[f.Name] = f.Default
+		case f.Required:
+			errs = append(errs, fmt.Errorf("field %s: missing required value", f.Name))
+			missing = true
+		}
+	}
+	if missing {
+		return nil, errs
+	}
+	return item, errs
+}
+
+// rawValues 用于获取字段未经类型转换的值。
+func (f *field) rawValues(s scope) []interface{} {
+	var values []interface{}
+	switch {
+	case s.node != nil && f.selector != nil:
+		for _, n := range f.selector.Select(s.node) {
+			if f.Attr == "" {
+				values = append(values, htmlquery.Text(n))
+			} else if value, ok := htmlquery.Attr(n, f.Attr); ok {
+				values = append(values, strings.TrimSpace(value))
+			}
+		}
+	case s.node == nil && f.jsonPath != nil:
+		values = f.jsonPath.eval(s.value)
+	case f.selector == nil && f.jsonPath == nil:
+		values = []interface{}{s.raw}
+	default:
+		// 规则不适用于这种内容。
+		return nil
+	}
+	if f.regexp == nil {
+		return values
+	}
+	var matched []interface{}
+	for _, value := range values {
+		for _, match := range f.regexp.FindAllStringSubmatch(toString(value), -1) {
+			if len(match) > 1 {
+				matched = append(matched, match[1])
+			} else {
+				matched = append(matched, match[0])
+			}
+		}
+	}
+	return matched
+}
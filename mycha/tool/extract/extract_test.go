@@ -0,0 +1,232 @@
+package extract
+
+import (
+	"mycha/internal/testutil"
+	"mycha/module"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHTML = `<html><body>
+<ul id="books">
+  <li class="book" data-id="1">
+    <a href="/book/1">Go in Action</a>
+    <span class="price">1,299.50</span>
+    <span class="tag">go</span><span class="tag">programming</span>
+    <time datetime="2016-01-02T03:04:05Z">Jan 2</time>
+  </li>
+  <li class="book" data-id="2">
+    <a href="/book/2">Untitled</a>
+    <span class="price">n/a</span>
+  </li>
+  <li class="book">
+    <span class="price">10</span>
+  </li>
+</ul>
+<script>var total = 42;</script>
+</body></html>`
+
+var testJSON = `{"data": {"total": 2, "items": [
+  {"id": 9007199254740993, "name": "a", "score": 1.5, "ok": true, "tags": ["x", "y"]},
+  {"id": 2, "name": "b", "score": 3, "ok": false}
+]}}`
+
+// newTestResponse 用于生成测试用的响应。
+func newTestResponse(t *testing.T, body string, contentType string) *module.Response {
+	return testutil.NewResponse(t, "http://example.com/list", 0, body, contentType)
+}
+
+func TestExtractHTML(t *testing.T) {
+	ext, err := New(Schema{
+		Root: "li.book",
+		Fields: []Field{
+			{Name: "first_tag", Selector: "/span[@class='tag'][1]"},
+			{Name: "title", Selector: "a", Required: true},
+			{Name: "link", Selector: "a", Attr: "href"},
+			{Name: "price", Selector: "span.price", Type: TYPE_FLOAT},
+			{Name: "tags", Selector: ".tag", Multiple: true},
+			{Name: "published", Selector: "time", Attr: "datetime", Type: TYPE_TIME},
+			{Name: "stock", Selector: ".stock", Default: "unknown"},
+		},
+		URLField: "url",
+		Kind:     "book",
+	})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	items, errs := ext.Extract(newTestResponse(t, testHTML, "text/html; charset=utf-8"))
+	// 第二个条目的价格无法转换，第三个条目缺少标题。
+	if len(errs) != 2 {
+		t.Fatalf("Inconsistent number of errors: expected: %d, actual: %d (%v)", 2, len(errs), errs)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Inconsistent number of items: expected: %d, actual: %d", 2, len(items))
+	}
+	expected := module.Item{
+		"title":     "Go in Action",
+		"link":      "/book/1",
+		"price":     1299.5,
+		"tags":      []interface{}{"go", "programming"},
+		"first_tag": "go",
+		"published": time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		"stock":     "unknown",
+		"url":       "http://example.com/list",
+		module.ITEM_KIND_KEY: "book",
+	}
+	if !reflect.DeepEqual(items[0], expected) {
+		t.Fatalf("Inconsistent item: expected: %v, actual: %v", expected, items[0])
+	}
+	if _, ok := items[1]["price"]; ok {
+		t.Fatalf("The invalid price is kept: %v", items[1]["price"])
+	}
+}
+
+func TestExtractRegexp(t *testing.T) {
+	ext, err := New(Schema{Fields: []Field{
+		{Name: "total", Regexp: `var total = (\d+);`, Type: TYPE_INT},
+		{Name: "ids", Selector: "li.book", Attr: "data-id", Regexp: `\d`, Multiple: true},
+	}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	items, errs := ext.Extract(newTestResponse(t, testHTML, ""))
+	if len(errs) != 0 || len(items) != 1 {
+		t.Fatalf("Inconsistent result: items: %v, errors: %v", items, errs)
+	}
+	if items[0]["total"] != int64(42) {
+		t.Fatalf("Inconsistent total: expected: %d, actual: %v", 42, items[0]["total"])
+	}
+	if !reflect.DeepEqual(items[0]["ids"], []interface{}{"1", "2"}) {
+		t.Fatalf("Inconsistent ids: %v", items[0]["ids"])
+	}
+}
+
+func TestExtractGBK(t *testing.T) {
+	ext, err := New(Schema{Fields: []Field{{Name: "title", Selector: "title"}}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	// "中文标题"的GBK编码。
+	title := "\xd6\xd0\xce\xc4\xb1\xea\xcc\xe2"
+	pages := []struct {
+		body        string
+		contentType string
+	}{
+		{"<html><head><meta charset=\"gb2312\"><title>" + title + "</title></head></html>", ""},
+		{"<html><head><meta http-equiv=\"Content-Type\" content=\"text/html; charset=gbk\">" +
+			"<title>" + title + "</title></head></html>", "text/html"},
+		{"<html><head><title>" + title + "</title></head></html>", "text/html; charset=GBK"},
+	}
+	for i, page := range pages {
+		resp := newTestResponse(t, page.body, page.contentType)
+		items, errs := ext.Extract(resp)
+		if len(errs) != 0 || len(items) != 1 {
+			t.Fatalf("Inconsistent result of page[%d]: items: %v, errors: %v", i, items, errs)
+		}
+		if items[0]["title"] != "中文标题" {
+			t.Fatalf("Inconsistent title of page[%d]: expected: %s, actual: %v",
+				i, "中文标题", items[0]["title"])
+		}
+		if resp.Charset() != "gbk" || resp.MIMEType() != "text/html" {
+			t.Fatalf("Inconsistent charset or MIME type of page[%d]: %s, %s",
+				i, resp.Charset(), resp.MIMEType())
+		}
+	}
+	// 不是文本的内容会被忽略。
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 16)
+	items, errs := ext.Extract(newTestResponse(t, png, ""))
+	if len(items) != 0 || len(errs) != 0 {
+		t.Fatalf("Inconsistent result of the image: items: %v, errors: %v", items, errs)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	ext, err := New(Schema{
+		Root: "$.data.items[*]",
+		Fields: []Field{
+			{Name: "id", JSONPath: "$.id"},
+			{Name: "name", JSONPath: "$['name']", Type: TYPE_STRING},
+			{Name: "score", JSONPath: "$.score", Type: TYPE_FLOAT},
+			{Name: "ok", JSONPath: "$.ok", Type: TYPE_BOOL},
+			{Name: "first_tag", JSONPath: "$.tags[0]"},
+			{Name: "last_tag", JSONPath: "$.tags[-1]"},
+			{Name: "title", Selector: "h1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	items, errs := ext.Extract(newTestResponse(t, testJSON, "application/json"))
+	if len(errs) != 0 || len(items) != 2 {
+		t.Fatalf("Inconsistent result: items: %v, errors: %v", items, errs)
+	}
+	expected := module.Item{
+		"id": int64(9007199254740993), "name": "a", "score": 1.5, "ok": true,
+		"first_tag": "x", "last_tag": "y",
+	}
+	if !reflect.DeepEqual(items[0], expected) {
+		t.Fatalf("Inconsistent item: expected: %v, actual: %v", expected, items[0])
+	}
+	if items[1]["score"] != float64(3) || items[1]["ok"] != false {
+		t.Fatalf("Inconsistent item: %v", items[1])
+	}
+}
+
+func TestExtractRecursiveJSONPath(t *testing.T) {
+	ext, err := New(Schema{Fields: []Field{
+		{Name: "names", JSONPath: "$..name", Multiple: true},
+		{Name: "total", JSONPath: "$.data.total", Type: TYPE_INT},
+		{Name: "second", JSONPath: "$..items[1].name"},
+	}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	items, errs := ext.Extract(newTestResponse(t, testJSON, ""))
+	if len(errs) != 0 || len(items) != 1 {
+		t.Fatalf("Inconsistent result: items: %v, errors: %v", items, errs)
+	}
+	expected := module.Item{
+		"names": []interface{}{"a", "b"}, "total": int64(2), "second": "b",
+	}
+	if !reflect.DeepEqual(items[0], expected) {
+		t.Fatalf("Inconsistent item: expected: %v, actual: %v", expected, items[0])
+	}
+}
+
+func TestExtractWithRequestItem(t *testing.T) {
+	ext, err := New(Schema{Fields: []Field{{Name: "title", Selector: "a"}}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	resp := newTestResponse(t, testHTML, "text/html")
+	resp.Request().SetItem(module.Item{"category": "books", "title": "old"})
+	items, errs := ext.Extract(resp)
+	if len(errs) != 0 || len(items) != 1 {
+		t.Fatalf("Inconsistent result: items: %v, errors: %v", items, errs)
+	}
+	expected := module.Item{"category": "books", "title": "Go in Action"}
+	if !reflect.DeepEqual(items[0], expected) {
+		t.Fatalf("Inconsistent item: expected: %v, actual: %v", expected, items[0])
+	}
+}
+
+func TestInvalidSchema(t *testing.T) {
+	for _, schema := range []Schema{
+		{},
+		{Fields: []Field{{Selector: "a"}}},
+		{Fields: []Field{{Name: "a", Selector: "a"}, {Name: "a", Selector: "b"}}},
+		{Fields: []Field{{Name: "a"}}},
+		{Fields: []Field{{Name: "a", Selector: "a", Type: "decimal"}}},
+		{Fields: []Field{{Name: "a", Selector: "a:hover"}}},
+		{Fields: []Field{{Name: "a", JSONPath: "data.a"}}},
+		{Fields: []Field{{Name: "a", JSONPath: "$.a[x]"}}},
+		{Fields: []Field{{Name: "a", Regexp: "("}}},
+		{Root: "$.a[", Fields: []Field{{Name: "a", Regexp: "a"}}},
+	} {
+		if _, err := New(schema); err == nil {
+			t.Fatalf("No error when creating an extractor with invalid schema %+v", schema)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonStep 代表JSON路径中的一个步骤。
+type jsonStep struct {
+	// recursive 代表是否为".."，即在所有层级中查找。
+	recursive bool
+	// key 代表对象的键，wildcard和index都未设置时使用。
+	key string
+	// wildcard 代表是否为"*"，即取所有的元素或值。
+	wildcard bool
+	// index 代表数组的下标，为nil时表示不是下标，负数表示从末尾开始。
+	index *int
+}
+
+// jsonPath 代表编译后的JSON路径。
+type jsonPath struct {
+	expr  string
+	steps []jsonStep
+}
+
+// compileJSONPath 用于编译JSON路径。
+// 支持$、.key、['key']、[n]、[*]、.*和..key。
+func compileJSONPath(expr string) (*jsonPath, error) {
+	path := &jsonPath{expr: expr}
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("invalid JSON path %q: must start with '$'", expr)
+	}
+	s = s[1:]
+	for s != "" {
+		var step jsonStep
+		var err error
+		switch s[0] {
+		case '[':
+			if s, err = parseBracket(expr, s, &step); err != nil {
+				return nil, err
+			}
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, ".") {
+				step.recursive = true
+				s = s[1:]
+				if strings.HasPrefix(s, "[") {
+					if s, err = parseBracket(expr, s, &step); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSON path %q: missing key", expr)
+			}
+			if name == "*" {
+				step.wildcard = true
+			} else {
+				step.key = name
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected %q", expr, s[0])
+		}
+		path.steps = append(path.steps, step)
+	}
+	return path, nil
+}
+
+// parseBracket 用于解析以"["开头的步骤，并返回剩余的部分。
+func parseBracket(expr string, s string, step *jsonStep) (string, error) {
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", fmt.Errorf("invalid JSON path %q: unterminated '['", expr)
+	}
+	inner := strings.TrimSpace(s[1:end])
+	switch {
+	case inner == "*":
+		step.wildcard = true
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		step.key = inner[1 : len(inner)-1]
+	default:
+		index, err := strconv.Atoi(inner)
+		if err != nil {
+			return "", fmt.Errorf("invalid JSON path %q: bad index %q", expr, inner)
+		}
+		step.index = &index
+	}
+	return s[end+1:], nil
+}
+
+// eval 用于在JSON值中查找所有匹配的值。
+func (path *jsonPath) eval(root interface{}) []interface{} {
+	values := []interface{}{root}
+	for _, step := range path.steps {
+		var next []interface{}
+		for _, value := range values {
+			if step.recursive {
+				descend(value, func(v interface{}) {
+					next = append(next, step.apply(v)...)
+				})
+			} else {
+				next = append(next, step.apply(value)...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// apply 用于在单个JSON值上执行步骤。
+func (step *jsonStep) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			keys := sortedKeys(v)
+			values := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				values = append(values, v[key])
+			}
+			return values
+		}
+		if step.index == nil {
+			if child, ok := v[step.key]; ok {
+				return []interface{}{child}
+			}
+		}
+	case []interface{}:
+		if step.wildcard {
+			return append([]interface{}(nil), v...)
+		}
+		if step.index != nil {
+			index := *step.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []interface{}{v[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// descend 用于按深度优先的顺序访问JSON值本身及其所有后代。
+func descend(value interface{}, fn func(v interface{})) {
+	fn(value)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			descend(v[key], fn)
+		}
+	case []interface{}:
+		for _, child := range v {
+			descend(child, fn)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package htmlquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// compound 代表不含组合符的简单选择器序列，比如"a.link[href]"。
+type compound struct {
+	// tag 代表元素名，为空时匹配任意元素。
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrMatcher
+}
+
+func (c *compound) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" {
+		if id, _ := Attr(n, "id"); id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		classAttr, _ := Attr(n, "class")
+		classes := strings.Fields(classAttr)
+		for _, want := range c.classes {
+			found := false
+			for _, class := range classes {
+				if class == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, m := range c.attrs {
+		if !m.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// complexSelector 代表用组合符连接起来的选择器，比如"div.list > a"。
+type complexSelector struct {
+	parts []*compound
+	// combinators 中的第i个代表parts[i]和parts[i+1]之间的组合符，
+	// 值为' '（后代）或'>'（子）。
+	combinators []byte
+}
+
+// match 用于判断节点是否匹配前i+1个部分组成的选择器。
+func (sel *complexSelector) match(n *html.Node, i int) bool {
+	if !sel.parts[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if sel.combinators[i-1] == '>' {
+		p := n.Parent
+		return p != nil && sel.match(p, i-1)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if sel.match(p, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// cssSelector 代表CSS选择器。
+type cssSelector struct {
+	expr   string
+	groups []*complexSelector
+}
+
+func (sel *cssSelector) Select(root *html.Node) []*html.Node {
+	var nodes []*html.Node
+	walk(root, func(n *html.Node) {
+		for _, group := range sel.groups {
+			if group.match(n, len(group.parts)-1) {
+				nodes = append(nodes, n)
+				return
+			}
+		}
+	})
+	return nodes
+}
+
+func (sel *cssSelector) String() string {
+	return sel.expr
+}
+
+// compileCSS 用于编译CSS选择器。
+func compileCSS(expr string) (Selector, error) {
+	p := &parser{s: expr}
+	sel := &cssSelector{expr: expr}
+	for {
+		group, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		sel.groups = append(sel.groups, group)
+		p.skipSpace()
+		if p.eof() {
+			return sel, nil
+		}
+		if p.s[p.pos] != ',' {
+			return nil, p.errorf("unexpected %q", p.s[p.pos])
+		}
+		p.pos++
+	}
+}
+
+// parser 代表选择器表达式的解析器。
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid selector %q at %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace 用于跳过空白，并返回是否跳过了空白。
+func (p *parser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n\f", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *parser) parseComplex() (*complexSelector, error) {
+	p.skipSpace()
+	sel := &complexSelector{}
+	for {
+		part, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		sel.parts = append(sel.parts, part)
+		spaced := p.skipSpace()
+		if p.eof() || p.s[p.pos] == ',' {
+			return sel, nil
+		}
+		combinator := byte(' ')
+		if p.s[p.pos] == '>' {
+			combinator = '>'
+			p.pos++
+			p.skipSpace()
+		} else if !spaced {
+			return nil, p.errorf("unexpected %q", p.s[p.pos])
+		}
+		sel.combinators = append(sel.combinators, combinator)
+	}
+}
+
+func (p *parser) parseCompound() (*compound, error) {
+	c := &compound{}
+	start := p.pos
+	if !p.eof() && p.s[p.pos] == '*' {
+		p.pos++
+	} else if tag := p.parseIdent(); tag != "" {
+		c.tag = strings.ToLower(tag)
+	}
+	for !p.eof() {
+		switch p.s[p.pos] {
+		case '#':
+			p.pos++
+			if c.id = p.parseIdent(); c.id == "" {
+				return nil, p.errorf("missing id")
+			}
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return nil, p.errorf("missing class")
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			p.pos++
+			m, err := p.parseAttr()
+			if err != nil {
+				return nil, err
+			}
+			c.attrs = append(c.attrs, m)
+		case ':':
+			return nil, p.errorf("pseudo-classes are not supported")
+		default:
+			if p.pos == start {
+				return nil, p.errorf("unexpected %q", p.s[p.pos])
+			}
+			return c, nil
+		}
+	}
+	if p.pos == start {
+		return nil, p.errorf("missing selector")
+	}
+	return c, nil
+}
+
+// parseAttr 用于解析"["之后的属性条件。
+func (p *parser) parseAttr() (attrMatcher, error) {
+	var m attrMatcher
+	p.skipSpace()
+	if m.name = strings.ToLower(p.parseIdent()); m.name == "" {
+		return m, p.errorf("missing attribute name")
+	}
+	p.skipSpace()
+	if p.eof() {
+		return m, p.errorf("unterminated attribute selector")
+	}
+	if p.s[p.pos] == ']' {
+		p.pos++
+		return m, nil
+	}
+	for _, op := range []string{"=", "~=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			m.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if m.op == "" {
+		return m, p.errorf("unknown attribute operator")
+	}
+	p.skipSpace()
+	if !p.eof() && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		value, err := p.parseString()
+		if err != nil {
+			return m, err
+		}
+		m.value = value
+	} else if m.value = p.parseIdent(); m.value == "" {
+		return m, p.errorf("missing attribute value")
+	}
+	p.skipSpace()
+	if p.eof() || p.s[p.pos] != ']' {
+		return m, p.errorf("unterminated attribute selector")
+	}
+	p.pos++
+	return m, nil
+}
+
+// parseString 用于解析引号括起来的字符串。
+func (p *parser) parseString() (string, error) {
+	quote := p.s[p.pos]
+	end := strings.IndexByte(p.s[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	value := p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return value, nil
+}
+
+// parseIdent 用于解析标识符，没有标识符时返回空字符串。
+func (p *parser) parseIdent() string {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !isIdentRune(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.s[start:p.pos]
+}
+
+// isIdentRune 用于判断字符能否出现在标识符中。
+func isIdentRune(r rune) bool {
+	return r == '-' || r == '_' || r >= 0x80 ||
+		('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')
+}
@@ -0,0 +1,131 @@
+package htmlquery
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector 代表编译后的选择器。
+type Selector interface {
+	// Select 用于找出root之下（不含root自身）所有匹配的元素节点，按文档顺序排列。
+	Select(root *html.Node) []*html.Node
+	// String 用于获取选择器的表达式。
+	String() string
+}
+
+// Compile 用于编译选择器表达式。
+// 以"/"开头的表达式会被当作XPath的子集，否则会被当作CSS选择器的子集。
+//
+// 支持的CSS选择器：元素名、*、#id、.class、[attr]、[attr=v]、[attr~=v]、
+// [attr^=v]、[attr$=v]、[attr*=v]，后代组合符（空格）、子组合符（>）以及逗号分隔的多个选择器。
+//
+// 支持的XPath：/和//分隔的步骤，步骤为元素名或*，
+// 谓词为[@attr]、[@attr='v']、[contains(@attr,'v')]和表示位置的[n]。
+func Compile(expr string) (Selector, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, errors.New("empty selector")
+	}
+	if strings.HasPrefix(expr, "/") {
+		return compileXPath(expr)
+	}
+	return compileCSS(expr)
+}
+
+// MustCompile 和Compile相同，但会在表达式有误时引发运行时恐慌。
+func MustCompile(expr string) Selector {
+	sel, err := Compile(expr)
+	if err != nil {
+		panic("htmlquery: " + expr + ": " + err.Error())
+	}
+	return sel
+}
+
+// Attr 用于获取元素节点的属性值。
+func Attr(n *html.Node, name string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && strings.EqualFold(attr.Key, name) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// Text 用于获取节点之下所有文本的拼接结果，空白会被合并，首尾的空白会被去掉。
+// 脚本和样式中的文本会被忽略。
+func Text(n *html.Node) string {
+	var buf bytes.Buffer
+	collectText(n, &buf)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// collectText 用于收集节点之下的文本。
+func collectText(n *html.Node, buf *bytes.Buffer) {
+	if n == nil {
+		return
+	}
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(n.Data)
+		buf.WriteByte(' ')
+		return
+	case html.ElementNode:
+		if n.Data == "script" || n.Data == "style" {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectText(c, buf)
+	}
+}
+
+// walk 用于按文档顺序遍历root之下的所有元素节点（不含root自身）。
+func walk(root *html.Node, fn func(n *html.Node)) {
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			fn(c)
+		}
+		walk(c, fn)
+	}
+}
+
+// attrMatcher 代表属性条件。
+type attrMatcher struct {
+	name string
+	// op 代表比较方式，为空时只要求属性存在。
+	op    string
+	value string
+}
+
+func (m attrMatcher) match(n *html.Node) bool {
+	val, ok := Attr(n, m.name)
+	if !ok {
+		return false
+	}
+	switch m.op {
+	case "":
+		return true
+	case "=":
+		return val == m.value
+	case "~=":
+		for _, field := range strings.Fields(val) {
+			if field == m.value {
+				return true
+			}
+		}
+		return false
+	case "^=":
+		return m.value != "" && strings.HasPrefix(val, m.value)
+	case "$=":
+		return m.value != "" && strings.HasSuffix(val, m.value)
+	case "*=":
+		return m.value != "" && strings.Contains(val, m.value)
+	}
+	return false
+}
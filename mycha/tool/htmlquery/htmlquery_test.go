@@ -0,0 +1,117 @@
+package htmlquery
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var testDoc = `<html><head><title>T</title></head><body>
+<div id="nav" class="menu top"><a href="/a">A</a><a href="/b" rel="nofollow">B</a></div>
+<div class="list">
+  <ul>
+    <li><a class="item" href="/item/1">One</a></li>
+    <li><a class="item hot" href="/item/2">Two</a></li>
+    <li><span><a href="/other">Other</a></span></li>
+  </ul>
+</div>
+<p>Hello <b>world</b><script>var x = 1;</script></p>
+</body></html>`
+
+func parseTestDoc(t *testing.T) *html.Node {
+	doc, err := html.Parse(strings.NewReader(testDoc))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing the document: %s", err)
+	}
+	return doc
+}
+
+// hrefs 用于获取节点的href属性列表。
+func hrefs(nodes []*html.Node) string {
+	var values []string
+	for _, n := range nodes {
+		href, _ := Attr(n, "href")
+		values = append(values, href)
+	}
+	return strings.Join(values, ",")
+}
+
+func TestSelect(t *testing.T) {
+	doc := parseTestDoc(t)
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{"a", "/a,/b,/item/1,/item/2,/other"},
+		{"#nav a", "/a,/b"},
+		{"div.menu.top > a", "/a,/b"},
+		{"a.item", "/item/1,/item/2"},
+		{"a.item.hot", "/item/2"},
+		{"li > a", "/item/1,/item/2"},
+		{"div.list a", "/item/1,/item/2,/other"},
+		{"a[rel=nofollow]", "/b"},
+		{"a[href^='/item/']", "/item/1,/item/2"},
+		{"a[href$=\"2\"]", "/item/2"},
+		{"a[href*=the]", "/other"},
+		{"a[class~=hot]", "/item/2"},
+		{"#nav > a, li > a", "/a,/b,/item/1,/item/2"},
+		{"*[rel]", "/b"},
+		{"//a", "/a,/b,/item/1,/item/2,/other"},
+		{"//div[@id='nav']/a", "/a,/b"},
+		{"//div[@id='nav']/a[2]", "/b"},
+		{"//li/a[@class]", "/item/1,/item/2"},
+		{"//a[contains(@class,'hot')]", "/item/2"},
+		{"//ul//a", "/item/1,/item/2,/other"},
+		{"//a[text()='Two']", "/item/2"},
+		{"/html/body/div[2]//li[3]//a", "/other"},
+		{"//*[@rel]", "/b"},
+	}
+	for _, c := range cases {
+		sel, err := Compile(c.expr)
+		if err != nil {
+			t.Fatalf("An error occurs when compiling %q: %s", c.expr, err)
+		}
+		if got := hrefs(sel.Select(doc)); got != c.want {
+			t.Fatalf("Inconsistent result for %q: expected: %q, actual: %q",
+				c.expr, c.want, got)
+		}
+	}
+}
+
+func TestSelectScoped(t *testing.T) {
+	doc := parseTestDoc(t)
+	nav := MustCompile("#nav").Select(doc)
+	if len(nav) != 1 {
+		t.Fatalf("Inconsistent number of nodes: expected: %d, actual: %d", 1, len(nav))
+	}
+	if got := hrefs(MustCompile("a").Select(nav[0])); got != "/a,/b" {
+		t.Fatalf("Inconsistent result: expected: %q, actual: %q", "/a,/b", got)
+	}
+	if got := hrefs(MustCompile("/a[1]").Select(nav[0])); got != "/a" {
+		t.Fatalf("Inconsistent result: expected: %q, actual: %q", "/a", got)
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for _, expr := range []string{
+		"", "a:hover", "a[href", "a[href=]", "a[href=='x']", "a >", ", a",
+		"#", ".", "a[href='x]", "//", "//a[", "//a[@]", "//a[text()]",
+		"//a[0]", "//a[contains(@href)]",
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Fatalf("No error when compiling invalid selector %q", expr)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	doc := parseTestDoc(t)
+	p := MustCompile("p").Select(doc)
+	if len(p) != 1 {
+		t.Fatalf("Inconsistent number of nodes: expected: %d, actual: %d", 1, len(p))
+	}
+	if got := Text(p[0]); got != "Hello world" {
+		t.Fatalf("Inconsistent text: expected: %q, actual: %q", "Hello world", got)
+	}
+}
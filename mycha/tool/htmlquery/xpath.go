@@ -0,0 +1,250 @@
+package htmlquery
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// xpathStep 代表XPath中的一个步骤。
+type xpathStep struct {
+	// descendant 代表步骤前的分隔符是否为"//"。
+	descendant bool
+	// tag 代表元素名，为空时匹配任意元素。
+	tag   string
+	preds []xpathPred
+}
+
+// xpathPred 代表步骤中的谓词。
+type xpathPred struct {
+	// position 代表位置谓词中的位置，从1开始，为0时代表不是位置谓词。
+	position int
+	// text 代表比较的对象是否为文本，否则为属性attr。
+	text bool
+	attr string
+	// op 代表比较方式，可以是""（只要求属性存在）、"="或"contains"。
+	op    string
+	value string
+}
+
+func (pred *xpathPred) match(n *html.Node) bool {
+	var val string
+	if pred.text {
+		val = Text(n)
+	} else {
+		var ok bool
+		if val, ok = Attr(n, pred.attr); !ok {
+			return false
+		}
+	}
+	switch pred.op {
+	case "=":
+		return val == pred.value
+	case "contains":
+		return strings.Contains(val, pred.value)
+	}
+	return true
+}
+
+// xpathSelector 代表XPath选择器。
+type xpathSelector struct {
+	expr  string
+	steps []*xpathStep
+}
+
+func (sel *xpathSelector) Select(root *html.Node) []*html.Node {
+	context := []*html.Node{root}
+	for _, step := range sel.steps {
+		var next []*html.Node
+		seen := map[*html.Node]bool{}
+		for _, n := range context {
+			parents := []*html.Node{n}
+			if step.descendant {
+				walk(n, func(d *html.Node) {
+					parents = append(parents, d)
+				})
+			}
+			for _, parent := range parents {
+				for _, c := range step.apply(parent) {
+					if !seen[c] {
+						seen[c] = true
+						next = append(next, c)
+					}
+				}
+			}
+		}
+		context = next
+	}
+	sortByDocumentOrder(root, context)
+	return context
+}
+
+// apply 用于找出父节点下符合步骤的子元素。
+func (step *xpathStep) apply(parent *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (step.tag == "" || c.Data == step.tag) {
+			nodes = append(nodes, c)
+		}
+	}
+	for i := range step.preds {
+		pred := &step.preds[i]
+		if pred.position > 0 {
+			if pred.position > len(nodes) {
+				return nil
+			}
+			nodes = nodes[pred.position-1 : pred.position]
+			continue
+		}
+		var filtered []*html.Node
+		for _, n := range nodes {
+			if pred.match(n) {
+				filtered = append(filtered, n)
+			}
+		}
+		nodes = filtered
+	}
+	return nodes
+}
+
+func (sel *xpathSelector) String() string {
+	return sel.expr
+}
+
+// sortByDocumentOrder 用于把节点按文档顺序排列。
+func sortByDocumentOrder(root *html.Node, nodes []*html.Node) {
+	if len(nodes) < 2 {
+		return
+	}
+	order := map[*html.Node]int{}
+	walk(root, func(n *html.Node) {
+		order[n] = len(order)
+	})
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return order[nodes[i]] < order[nodes[j]]
+	})
+}
+
+// compileXPath 用于编译XPath选择器。
+func compileXPath(expr string) (Selector, error) {
+	p := &parser{s: expr}
+	sel := &xpathSelector{expr: expr}
+	for !p.eof() {
+		step := &xpathStep{}
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], "//"):
+			step.descendant = true
+			p.pos += 2
+		case p.s[p.pos] == '/':
+			p.pos++
+		default:
+			return nil, p.errorf("expected '/'")
+		}
+		if !p.eof() && p.s[p.pos] == '*' {
+			p.pos++
+		} else if step.tag = strings.ToLower(p.parseIdent()); step.tag == "" {
+			return nil, p.errorf("missing element name")
+		}
+		for !p.eof() && p.s[p.pos] == '[' {
+			p.pos++
+			pred, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			step.preds = append(step.preds, pred)
+		}
+		sel.steps = append(sel.steps, step)
+	}
+	return sel, nil
+}
+
+// parsePredicate 用于解析"["之后的谓词。
+func (p *parser) parsePredicate() (xpathPred, error) {
+	var pred xpathPred
+	p.skipSpace()
+	end := strings.IndexByte(p.s[p.pos:], ']')
+	if end < 0 {
+		return pred, p.errorf("unterminated predicate")
+	}
+	if position, err := strconv.Atoi(strings.TrimSpace(p.s[p.pos : p.pos+end])); err == nil {
+		if position < 1 {
+			return pred, p.errorf("invalid position %d", position)
+		}
+		pred.position = position
+		p.pos += end + 1
+		return pred, nil
+	}
+	if strings.HasPrefix(p.s[p.pos:], "contains(") {
+		p.pos += len("contains(")
+		if err := p.parseOperand(&pred); err != nil {
+			return pred, err
+		}
+		if err := p.expect(','); err != nil {
+			return pred, err
+		}
+		p.skipSpace()
+		value, err := p.parseQuoted()
+		if err != nil {
+			return pred, err
+		}
+		pred.op, pred.value = "contains", value
+		if err = p.expect(')'); err != nil {
+			return pred, err
+		}
+	} else {
+		if err := p.parseOperand(&pred); err != nil {
+			return pred, err
+		}
+		p.skipSpace()
+		if !p.eof() && p.s[p.pos] == '=' {
+			p.pos++
+			p.skipSpace()
+			value, err := p.parseQuoted()
+			if err != nil {
+				return pred, err
+			}
+			pred.op, pred.value = "=", value
+		} else if pred.text {
+			return pred, p.errorf("text() needs a comparison")
+		}
+	}
+	return pred, p.expect(']')
+}
+
+// parseOperand 用于解析谓词中的"@attr"或"text()"。
+func (p *parser) parseOperand(pred *xpathPred) error {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], "text()") {
+		pred.text = true
+		p.pos += len("text()")
+		return nil
+	}
+	if p.eof() || p.s[p.pos] != '@' {
+		return p.errorf("expected '@attr' or 'text()'")
+	}
+	p.pos++
+	if pred.attr = strings.ToLower(p.parseIdent()); pred.attr == "" {
+		return p.errorf("missing attribute name")
+	}
+	return nil
+}
+
+// parseQuoted 用于解析引号括起来的字符串。
+func (p *parser) parseQuoted() (string, error) {
+	if p.eof() || (p.s[p.pos] != '"' && p.s[p.pos] != '\'') {
+		return "", p.errorf("expected quoted string")
+	}
+	return p.parseString()
+}
+
+// expect 用于跳过空白后读取指定的字符。
+func (p *parser) expect(c byte) error {
+	p.skipSpace()
+	if p.eof() || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
@@ -0,0 +1,301 @@
+package links
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mycha/module"
+	"mycha/tool/htmlquery"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 用于查找页面中的特殊元素的选择器。
+var (
+	baseSelector = htmlquery.MustCompile("base[href]")
+	metaSelector = htmlquery.MustCompile("meta[name]")
+)
+
+// Rules 代表链接提取的规则。
+type Rules struct {
+	// Allow 代表链接需要匹配的正则表达式，满足其一即可，为空时允许所有链接。
+	Allow []string
+	// Deny 代表需要排除的链接的正则表达式，优先于Allow。
+	Deny []string
+	// Restrict 代表选择器（CSS选择器或XPath）列表，
+	// 只会在匹配的元素之内提取链接，为空时在整个页面中提取。
+	Restrict []string
+	// Tags 代表需要提取链接的元素名，为空时为a和area。
+	Tags []string
+	// Attrs 代表链接所在的属性名，为空时为href。
+	Attrs []string
+	// FollowNofollow 代表是否提取带有rel="nofollow"的链接。
+	// 为false时，页面的robots元标签中含有nofollow时也不会提取任何链接。
+	FollowNofollow bool
+	// Callback 代表生成的请求的解析函数名称。
+	Callback string
+}
+
+// Extractor 代表链接提取器。
+type Extractor struct {
+	allow          []*regexp.Regexp
+	deny           []*regexp.Regexp
+	restrict       []htmlquery.Selector
+	tags           map[string]bool
+	attrs          []string
+	followNofollow bool
+	callback       string
+}
+
+// New 用于根据规则创建一个链接提取器。
+func New(rules Rules) (*Extractor, error) {
+	ext := &Extractor{
+		tags:           map[string]bool{},
+		attrs:          rules.Attrs,
+		followNofollow: rules.FollowNofollow,
+		callback:       rules.Callback,
+	}
+	var err error
+	if ext.allow, err = compileAll(rules.Allow); err != nil {
+		return nil, err
+	}
+	if ext.deny, err = compileAll(rules.Deny); err != nil {
+		return nil, err
+	}
+	for _, expr := range rules.Restrict {
+		sel, err := htmlquery.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		ext.restrict = append(ext.restrict, sel)
+	}
+	tags := rules.Tags
+	if len(tags) == 0 {
+		tags = []string{"a", "area"}
+	}
+	for _, tag := range tags {
+		ext.tags[strings.ToLower(tag)] = true
+	}
+	if len(ext.attrs) == 0 {
+		ext.attrs = []string{"href"}
+	}
+	return ext, nil
+}
+
+// compileAll 用于编译正则表达式列表。
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	var regexps []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link pattern %q: %s", expr, err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// Parse 用于从响应中提取链接并生成下一层的请求，可以和其他响应解析函数一起交给分析器。
+// 生成的请求的深度为响应的深度加1，并带有Referer头和父请求。
+func (ext *Extractor) Parse(resp *module.Response) ([]module.Data, []error) {
+	links, err := ext.Links(resp)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var dataList []module.Data
+	var errs []error
+	for _, link := range links {
+		httpReq, err := http.NewRequest(http.MethodGet, link.String(), nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		req := resp.Follow(httpReq)
+		if ext.callback != "" {
+			req.SetCallback(ext.callback)
+		}
+		dataList = append(dataList, req)
+	}
+	return dataList, errs
+}
+
+// Links 用于从响应中提取链接。
+// 响应的内容类型不是HTML时返回nil。
+func (ext *Extractor) Links(resp *module.Response) ([]*url.URL, error) {
+	if resp == nil || resp.HTTPResp() == nil {
+		return nil, errors.New("nil response")
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.Request == nil || httpResp.Request.URL == nil {
+		return nil, errors.New("nil HTTP request URL")
+	}
+	if !isHTML(httpResp.Header.Get("Content-Type")) || httpResp.Body == nil || !resp.IsText() {
+		return nil, nil
+	}
+	// 按检测到的字符集转换为UTF-8后再解析。
+	body, err := resp.TextReader()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return ext.Extract(doc, httpResp.Request.URL), nil
+}
+
+// isHTML 用于判断内容类型是否为HTML，未知的内容类型也被视为HTML。
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// Extract 用于从已经解析的文档中提取链接。
+// 参数pageURL代表页面的链接，文档中有<base href>时以其为准解析相对链接。
+// 返回的链接已经去掉了片段并去重，只包含http和https链接。
+func (ext *Extractor) Extract(doc *html.Node, pageURL *url.URL) []*url.URL {
+	if !ext.followNofollow && pageNofollow(doc) {
+		return nil
+	}
+	base := baseURL(doc, pageURL)
+	var links []*url.URL
+	seen := map[string]bool{}
+	for _, n := range ext.linkNodes(doc) {
+		if !ext.followNofollow && isNofollow(n) {
+			continue
+		}
+		for _, attr := range ext.attrs {
+			href, ok := htmlquery.Attr(n, attr)
+			if !ok {
+				continue
+			}
+			link := resolve(base, href)
+			if link == nil {
+				continue
+			}
+			s := link.String()
+			if seen[s] || !ext.accept(s) {
+				continue
+			}
+			seen[s] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// linkNodes 用于找出需要提取链接的元素，按文档顺序排列且不重复。
+func (ext *Extractor) linkNodes(doc *html.Node) []*html.Node {
+	regions := []*html.Node{doc}
+	if len(ext.restrict) > 0 {
+		regions = nil
+		for _, sel := range ext.restrict {
+			regions = append(regions, sel.Select(doc)...)
+		}
+	}
+	var nodes []*html.Node
+	seen := map[*html.Node]bool{}
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && ext.tags[n.Data] && !seen[n] {
+			seen[n] = true
+			nodes = append(nodes, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	for _, region := range regions {
+		visit(region)
+	}
+	return nodes
+}
+
+// accept 用于判断链接是否符合Allow和Deny规则。
+func (ext *Extractor) accept(link string) bool {
+	for _, re := range ext.deny {
+		if re.MatchString(link) {
+			return false
+		}
+	}
+	if len(ext.allow) == 0 {
+		return true
+	}
+	for _, re := range ext.allow {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseURL 用于获取解析相对链接时使用的基础链接。
+func baseURL(doc *html.Node, pageURL *url.URL) *url.URL {
+	nodes := baseSelector.Select(doc)
+	if len(nodes) == 0 {
+		return pageURL
+	}
+	href, _ := htmlquery.Attr(nodes[0], "href")
+	base, err := pageURL.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return pageURL
+	}
+	return base
+}
+
+// resolve 用于把链接解析为绝对链接，不是http或https链接时返回nil。
+func resolve(base *url.URL, href string) *url.URL {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return nil
+	}
+	link, err := base.Parse(href)
+	if err != nil {
+		return nil
+	}
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return nil
+	}
+	link.Fragment = ""
+	link.RawFragment = ""
+	return link
+}
+
+// isNofollow 用于判断链接元素是否带有rel="nofollow"。
+func isNofollow(n *html.Node) bool {
+	rel, _ := htmlquery.Attr(n, "rel")
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		if value == "nofollow" {
+			return true
+		}
+	}
+	return false
+}
+
+// pageNofollow 用于判断页面的robots元标签是否要求不跟踪链接。
+func pageNofollow(doc *html.Node) bool {
+	for _, n := range metaSelector.Select(doc) {
+		name, _ := htmlquery.Attr(n, "name")
+		if !strings.EqualFold(name, "robots") {
+			continue
+		}
+		content, _ := htmlquery.Attr(n, "content")
+		for _, value := range strings.Split(strings.ToLower(content), ",") {
+			value = strings.TrimSpace(value)
+			if value == "nofollow" || value == "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package links
+
+import (
+	"mycha/internal/testutil"
+	"mycha/module"
+	"strings"
+	"testing"
+)
+
+var testPage = `<html><head><base href="/shop/"></head><body>
+<div id="nav">
+  <a href="/">Home</a>
+  <a href="login" rel="nofollow">Login</a>
+  <a href="mailto:a@example.com">Mail</a>
+  <a href="javascript:void(0)">JS</a>
+  <a href="#top">Top</a>
+</div>
+<div class="list">
+  <a href="item/1">One</a>
+  <a href="item/1#reviews">One again</a>
+  <a href="https://other.example.com/item/2">Two</a>
+  <a href="item/3.pdf">PDF</a>
+  <map><area href="item/4"></map>
+</div>
+</body></html>`
+
+// newTestResponse 用于生成测试用的响应，返回的请求为产生该响应的请求。
+func newTestResponse(t *testing.T, body string, contentType string) (*module.Request, *module.Response) {
+	resp := testutil.NewResponse(t, "http://example.com/shop/list?page=1", 1, body, contentType)
+	return resp.Request(), resp
+}
+
+func extract(t *testing.T, rules Rules, body string) string {
+	ext, err := New(rules)
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	_, resp := newTestResponse(t, body, "text/html; charset=utf-8")
+	links, err := ext.Links(resp)
+	if err != nil {
+		t.Fatalf("An error occurs when extracting links: %s", err)
+	}
+	var values []string
+	for _, link := range links {
+		values = append(values, link.String())
+	}
+	return strings.Join(values, " ")
+}
+
+func TestLinks(t *testing.T) {
+	cases := []struct {
+		rules Rules
+		want  string
+	}{
+		{Rules{},
+			"http://example.com/ http://example.com/shop/item/1 " +
+				"https://other.example.com/item/2 http://example.com/shop/item/3.pdf " +
+				"http://example.com/shop/item/4"},
+		{Rules{FollowNofollow: true, Restrict: []string{"#nav"}},
+			"http://example.com/ http://example.com/shop/login"},
+		{Rules{Restrict: []string{"//div[@class='list']"}, Allow: []string{`/item/`}, Deny: []string{`\.pdf$`}},
+			"http://example.com/shop/item/1 https://other.example.com/item/2 " +
+				"http://example.com/shop/item/4"},
+		{Rules{Restrict: []string{"div.list"}, Tags: []string{"area"}},
+			"http://example.com/shop/item/4"},
+	}
+	for _, c := range cases {
+		if got := extract(t, c.rules, testPage); got != c.want {
+			t.Fatalf("Inconsistent links for %+v: expected: %q, actual: %q", c.rules, c.want, got)
+		}
+	}
+}
+
+func TestLinksWithoutBase(t *testing.T) {
+	got := extract(t, Rules{}, `<a href="detail?id=2">x</a><a href="../up">y</a>`)
+	want := "http://example.com/shop/detail?id=2 http://example.com/up"
+	if got != want {
+		t.Fatalf("Inconsistent links: expected: %q, actual: %q", want, got)
+	}
+}
+
+func TestPageNofollow(t *testing.T) {
+	page := `<head><meta name="ROBOTS" content="noindex, nofollow"></head><a href="/a">a</a>`
+	if got := extract(t, Rules{}, page); got != "" {
+		t.Fatalf("Links are extracted from a nofollow page: %q", got)
+	}
+	if got := extract(t, Rules{FollowNofollow: true}, page); got != "http://example.com/a" {
+		t.Fatalf("Inconsistent links: expected: %q, actual: %q", "http://example.com/a", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	ext, err := New(Rules{Restrict: []string{"#nav"}, Callback: "detail"})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	req, resp := newTestResponse(t, testPage, "text/html")
+	req.SetMeta("category", "books")
+	dataList, errs := ext.Parse(resp)
+	if len(errs) > 0 {
+		t.Fatalf("An error occurs when parsing the response: %s", errs[0])
+	}
+	if len(dataList) != 1 {
+		t.Fatalf("Inconsistent number of requests: expected: %d, actual: %d", 1, len(dataList))
+	}
+	child, ok := dataList[0].(*module.Request)
+	if !ok {
+		t.Fatalf("Inconsistent data type: expected: %T, actual: %T", child, dataList[0])
+	}
+	if child.Depth() != 2 {
+		t.Fatalf("Inconsistent depth: expected: %d, actual: %d", 2, child.Depth())
+	}
+	if child.Parent() != req {
+		t.Fatalf("Inconsistent parent request")
+	}
+	if child.Callback() != "detail" {
+		t.Fatalf("Inconsistent callback: expected: %q, actual: %q", "detail", child.Callback())
+	}
+	if referer := child.Referer(); referer != "http://example.com/shop/list?page=1" {
+		t.Fatalf("Inconsistent referer: %q", referer)
+	}
+}
+
+func TestNonHTML(t *testing.T) {
+	ext, err := New(Rules{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the extractor: %s", err)
+	}
+	_, resp := newTestResponse(t, testPage, "image/png")
+	links, err := ext.Links(resp)
+	if err != nil || links != nil {
+		t.Fatalf("Links are extracted from a non-HTML response: %v, %v", links, err)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, rules := range []Rules{
+		{Allow: []string{"("}},
+		{Deny: []string{"["}},
+		{Restrict: []string{"a:hover"}},
+	} {
+		if _, err := New(rules); err == nil {
+			t.Fatalf("No error when creating an extractor with invalid rules %+v", rules)
+		}
+	}
+}
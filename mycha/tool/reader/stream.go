@@ -0,0 +1,235 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// ErrBodyTooLarge 代表数据量超过了上限的错误。
+var ErrBodyTooLarge = errors.New("multiple reader: body too large")
+
+// 默认的流式读取选项。
+const (
+	DEFAULT_MEMORY_LIMIT  = 1 << 20
+	DEFAULT_MAX_BODY_SIZE = 64 << 20
+)
+
+// chunkSize 代表每次从底层读取器读取的最大字节数。
+const chunkSize = 32 << 10
+
+// maxEmptyReads 代表底层读取器连续不返回数据的最大次数，超过时视为出错。
+const maxEmptyReads = 100
+
+// StreamOptions 代表流式多重读取器的选项。
+type StreamOptions struct {
+	// MaxBodySize 代表数据量的上限，超过时读取会返回ErrBodyTooLarge，为0时不限制。
+	MaxBodySize int64
+	// MemoryLimit 代表在内存中缓存的数据量的上限，超过后数据会被转存到临时文件中，
+	// 为0时为DEFAULT_MEMORY_LIMIT。
+	MemoryLimit int64
+	// TempDir 代表临时文件所在的目录，为空时为os.TempDir()。
+	TempDir string
+}
+
+// DefaultStreamOptions 用于获取默认的流式读取选项。
+func DefaultStreamOptions() StreamOptions {
+	return StreamOptions{
+		MaxBodySize: DEFAULT_MAX_BODY_SIZE,
+		MemoryLimit: DEFAULT_MEMORY_LIMIT,
+	}
+}
+
+// StreamMultipleReader 代表流式多重读取器的接口。
+// 数据只在被读取时才会从底层读取器中读出，并被缓存以供之后获取的读取器重复读取。
+type StreamMultipleReader interface {
+	MultipleReader
+	// Size 用于获取已经缓存的数据量。
+	Size() int64
+	// Close 用于释放缓存，包括删除临时文件。
+	// 之后所有读取器的读取都会返回错误。
+	Close() error
+}
+
+// myStreamMultipleReader 代表流式多重读取器的实现类型。
+type myStreamMultipleReader struct {
+	src     io.Reader
+	options StreamOptions
+	// lock 用于保护以下所有字段。
+	lock sync.Mutex
+	// mem 代表在内存中缓存的数据，转存到临时文件后为nil。
+	mem []byte
+	// file 代表缓存数据的临时文件。
+	file *os.File
+	// size 代表已经缓存的数据量。
+	size int64
+	// err 代表从底层读取器读取时遇到的错误，包括io.EOF。
+	err error
+	// emptyReads 代表底层读取器连续没有返回数据的次数。
+	emptyReads int
+	closed     bool
+}
+
+// NewStreamMultipleReader 用于新建并返回一个流式多重读取器的实例。
+// 它不会在创建时读取数据。
+func NewStreamMultipleReader(reader io.Reader, options StreamOptions) (StreamMultipleReader, error) {
+	if options.MaxBodySize < 0 {
+		return nil, fmt.Errorf("multiple reader: invalid max body size: %d", options.MaxBodySize)
+	}
+	if options.MemoryLimit < 0 {
+		return nil, fmt.Errorf("multiple reader: invalid memory limit: %d", options.MemoryLimit)
+	}
+	if options.MemoryLimit == 0 {
+		options.MemoryLimit = DEFAULT_MEMORY_LIMIT
+	}
+	mr := &myStreamMultipleReader{src: reader, options: options}
+	if reader == nil {
+		mr.err = io.EOF
+	}
+	return mr, nil
+}
+
+func (mr *myStreamMultipleReader) Reader() io.ReadCloser {
+	return &streamReader{mr: mr}
+}
+
+func (mr *myStreamMultipleReader) Size() int64 {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	return mr.size
+}
+
+func (mr *myStreamMultipleReader) Close() error {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	if mr.closed {
+		return nil
+	}
+	mr.closed = true
+	mr.mem = nil
+	if mr.file == nil {
+		return nil
+	}
+	name := mr.file.Name()
+	err := mr.file.Close()
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	mr.file = nil
+	return err
+}
+
+// readAt 用于从偏移量off处读取数据，缓存的数据不足时会从底层读取器中读取。
+func (mr *myStreamMultipleReader) readAt(p []byte, off int64) (int, error) {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	if mr.closed {
+		return 0, errors.New("multiple reader: read after close")
+	}
+	for off >= mr.size {
+		if mr.err != nil {
+			return 0, mr.err
+		}
+		mr.fill(len(p))
+	}
+	if mr.file == nil {
+		return copy(p, mr.mem[off:]), nil
+	}
+	if max := mr.size - off; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := mr.file.ReadAt(p, off)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+// fill 用于从底层读取器中读取最多n个字节并缓存起来。
+// 遇到的错误会被记录在mr.err中。
+func (mr *myStreamMultipleReader) fill(n int) {
+	if n > chunkSize {
+		n = chunkSize
+	}
+	limit := mr.options.MaxBodySize
+	if limit > 0 && mr.size+int64(n) > limit+1 {
+		// 多读一个字节，用于判断数据量是否超过了上限。
+		n = int(limit + 1 - mr.size)
+	}
+	buf := make([]byte, n)
+	read, err := mr.src.Read(buf)
+	if read > 0 {
+		if limit > 0 && mr.size+int64(read) > limit {
+			mr.err = ErrBodyTooLarge
+			return
+		}
+		if storeErr := mr.store(buf[:read]); storeErr != nil {
+			mr.err = storeErr
+			return
+		}
+	}
+	if err != nil {
+		mr.err = err
+		return
+	}
+	if read > 0 {
+		mr.emptyReads = 0
+	} else if mr.emptyReads++; mr.emptyReads >= maxEmptyReads {
+		mr.err = io.ErrNoProgress
+	}
+}
+
+// store 用于缓存数据，内存中的数据超过上限时会被转存到临时文件中。
+func (mr *myStreamMultipleReader) store(data []byte) error {
+	if mr.file == nil && mr.size+int64(len(data)) <= mr.options.MemoryLimit {
+		mr.mem = append(mr.mem, data...)
+		mr.size += int64(len(data))
+		return nil
+	}
+	if mr.file == nil {
+		file, err := ioutil.TempFile(mr.options.TempDir, "multiple-reader-")
+		if err != nil {
+			return fmt.Errorf("multiple reader: couldn't create a temporary file: %s", err)
+		}
+		if _, err := file.Write(mr.mem); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return err
+		}
+		mr.file = file
+		mr.mem = nil
+	}
+	if _, err := mr.file.WriteAt(data, mr.size); err != nil {
+		return err
+	}
+	mr.size += int64(len(data))
+	return nil
+}
+
+// streamReader 代表流式多重读取器给出的读取器，各自维护读取的位置。
+type streamReader struct {
+	mr     *myStreamMultipleReader
+	off    int64
+	closed bool
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errors.New("multiple reader: read from closed reader")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.mr.readAt(p, r.off)
+	r.off += int64(n)
+	return n, err
+}
+
+// Close 用于关闭该读取器，不影响其他的读取器。
+func (r *streamReader) Close() error {
+	r.closed = true
+	return nil
+}
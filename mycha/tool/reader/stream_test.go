@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// countingReader 代表会统计被读取的字节数的读取器。
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func TestStreamReaderLazy(t *testing.T) {
+	data := strings.Repeat("0987dcba", 1024)
+	src := &countingReader{r: strings.NewReader(data)}
+	mr, err := NewStreamMultipleReader(src, StreamOptions{})
+	if err != nil {
+		t.Fatalf("An error occurs when new stream multiple reader: %s", err)
+	}
+	defer mr.Close()
+	if src.n != 0 {
+		t.Fatalf("Data is read before being needed: %d bytes", src.n)
+	}
+	// 第一个读取器只读取开头的部分。
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(mr.Reader(), head); err != nil {
+		t.Fatalf("An error occurs when reading data: %s", err)
+	}
+	if string(head) != data[:8] {
+		t.Fatalf("Inconsistent data: expected: %s, actual: %s", data[:8], head)
+	}
+	if src.n != 8 {
+		t.Fatalf("Inconsistent number of bytes read: expected: %d, actual: %d", 8, src.n)
+	}
+	for i := 0; i < 2; i++ {
+		content, err := ioutil.ReadAll(mr.Reader())
+		if err != nil {
+			t.Fatalf("An error occurs when reading data: %s", err)
+		}
+		if string(content) != data {
+			t.Fatalf("Inconsistent data in reader[%d]", i)
+		}
+	}
+	if src.n != len(data) || mr.Size() != int64(len(data)) {
+		t.Fatalf("Inconsistent size: expected: %d, actual: %d (read: %d)",
+			len(data), mr.Size(), src.n)
+	}
+}
+
+func TestStreamReaderSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reader")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	mr, err := NewStreamMultipleReader(bytes.NewReader(data),
+		StreamOptions{MemoryLimit: 1000, TempDir: dir})
+	if err != nil {
+		t.Fatalf("An error occurs when new stream multiple reader: %s", err)
+	}
+	// 交替读取两个读取器。
+	r1, r2 := mr.Reader(), mr.Reader()
+	var buf1, buf2 bytes.Buffer
+	chunk := make([]byte, 777)
+	for done1, done2 := false, false; !done1 || !done2; {
+		if !done1 {
+			n, err := r1.Read(chunk)
+			buf1.Write(chunk[:n])
+			done1 = err == io.EOF
+		}
+		if !done2 {
+			n, err := r2.Read(chunk[:333])
+			buf2.Write(chunk[:n])
+			done2 = err == io.EOF
+		}
+	}
+	if !bytes.Equal(buf1.Bytes(), data) || !bytes.Equal(buf2.Bytes(), data) {
+		t.Fatalf("Inconsistent data: expected: %d bytes, actual: %d and %d bytes",
+			len(data), buf1.Len(), buf2.Len())
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Inconsistent number of temporary files: expected: %d, actual: %d", 1, len(files))
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the multiple reader: %s", err)
+	}
+	files, _ = ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Fatalf("The temporary file is not removed")
+	}
+	if _, err := mr.Reader().Read(chunk); err == nil {
+		t.Fatalf("No error when reading after close")
+	}
+}
+
+func TestStreamReaderMaxBodySize(t *testing.T) {
+	data := strings.Repeat("a", 100)
+	for _, max := range []int64{99, 100} {
+		mr, err := NewStreamMultipleReader(strings.NewReader(data),
+			StreamOptions{MaxBodySize: max})
+		if err != nil {
+			t.Fatalf("An error occurs when new stream multiple reader: %s", err)
+		}
+		_, err = ioutil.ReadAll(mr.Reader())
+		if max < int64(len(data)) && err != ErrBodyTooLarge {
+			t.Fatalf("Inconsistent error: expected: %s, actual: %v", ErrBodyTooLarge, err)
+		}
+		if max >= int64(len(data)) && err != nil {
+			t.Fatalf("An error occurs when reading data: %s", err)
+		}
+		mr.Close()
+	}
+	if _, err := NewStreamMultipleReader(nil, StreamOptions{MaxBodySize: -1}); err == nil {
+		t.Fatalf("No error when new stream multiple reader with invalid options")
+	}
+	mr, _ := NewStreamMultipleReader(nil, StreamOptions{})
+	if content, err := ioutil.ReadAll(mr.Reader()); err != nil || len(content) != 0 {
+		t.Fatalf("Inconsistent data of nil reader: %q, %v", content, err)
+	}
+}
@@ -0,0 +1,163 @@
+package robots
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorTTL 代表获取robots.txt失败时结果的最长缓存时间。
+// 在此之前再次检查同一主机会得到相同的结果。
+const ErrorTTL = time.Minute
+
+// maxSize 代表robots.txt被读取的最大字节数，超出的部分会被忽略。
+const maxSize = 500 << 10
+
+// Cache 代表按主机缓存robots.txt规则的获取器。
+type Cache struct {
+	client    *http.Client
+	userAgent string
+	ttl       time.Duration
+	lock      sync.Mutex
+	entries   map[string]*entry
+}
+
+// entry 代表某个主机的缓存条目。
+type entry struct {
+	// ready 会在规则获取完成后被关闭。
+	ready   chan struct{}
+	rules   *Rules
+	expires time.Time
+}
+
+// NewCache 用于创建一个robots.txt缓存。
+// 参数ttl代表规则的缓存时长。
+func NewCache(client *http.Client, userAgent string, ttl time.Duration) *Cache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Cache{
+		client:    client,
+		userAgent: userAgent,
+		ttl:       ttl,
+		entries:   map[string]*entry{},
+	}
+}
+
+// UserAgent 用于获取评估规则时使用的用户代理。
+func (c *Cache) UserAgent() string {
+	return c.userAgent
+}
+
+// Rules 用于获取给定链接所在主机的规则，必要时会下载robots.txt并等待下载完成。
+// 同一主机的并发调用只会触发一次下载。
+func (c *Cache) Rules(u *url.URL) *Rules {
+	e, key, created := c.entry(u)
+	if created {
+		c.fill(e, key)
+	}
+	<-e.ready
+	return e.rules
+}
+
+// Lookup 用于在不等待下载的情况下获取给定链接所在主机的规则。
+// 规则已缓存时返回规则和nil；否则会在后台开始下载，
+// 并返回nil和一个在下载完成后被关闭的通道。
+func (c *Cache) Lookup(u *url.URL) (*Rules, <-chan struct{}) {
+	e, key, created := c.entry(u)
+	if created {
+		go c.fill(e, key)
+	}
+	select {
+	case <-e.ready:
+		return e.rules, nil
+	default:
+		return nil, e.ready
+	}
+}
+
+// entry 用于获取给定链接所在主机的缓存条目。
+// 条目不存在或已过期时会创建新的条目，此时created为true，调用方需负责填充它。
+func (c *Cache) entry(u *url.URL) (e *entry, key string, created bool) {
+	key = strings.ToLower(u.Scheme + "://" + u.Host)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[key]
+	if ok {
+		select {
+		case <-e.ready:
+			if time.Now().After(e.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		e = &entry{ready: make(chan struct{})}
+		c.entries[key] = e
+	}
+	return e, key, !ok
+}
+
+// fill 用于下载规则并填充缓存条目。
+func (c *Cache) fill(e *entry, key string) {
+	e.rules, e.expires = c.fetch(key)
+	close(e.ready)
+}
+
+// Allowed 用于判断给定的链接是否允许访问。
+func (c *Cache) Allowed(u *url.URL) bool {
+	return c.Rules(u).Allowed(RequestPath(u))
+}
+
+// fetch 用于下载并解析robots.txt。
+// 4xx视为没有限制，5xx和网络错误视为暂时全部禁止，即Unavailable。
+func (c *Cache) fetch(base string) (*Rules, time.Time) {
+	now := time.Now()
+	errExpires := now.Add(c.ttl)
+	if c.ttl > ErrorTTL {
+		errExpires = now.Add(ErrorTTL)
+	}
+	req, err := http.NewRequest(http.MethodGet, base+"/robots.txt", nil)
+	if err != nil {
+		return DisallowAll, errExpires
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Unavailable, errExpires
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return Unavailable, errExpires
+	case resp.StatusCode >= 400:
+		return AllowAll, now.Add(c.ttl)
+	case resp.StatusCode >= 300:
+		// 客户端会自动跟随重定向，到这里说明重定向过多。
+		return AllowAll, now.Add(c.ttl)
+	}
+	rules, err := Parse(io.LimitReader(resp.Body, maxSize), c.userAgent)
+	if err != nil {
+		// 解析只会因读取响应体出错而失败。
+		return Unavailable, errExpires
+	}
+	return rules, now.Add(c.ttl)
+}
+
+// RequestPath 用于获取链接中参与规则匹配的部分，即路径和查询。
+func RequestPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
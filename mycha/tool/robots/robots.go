@@ -0,0 +1,210 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules 代表robots.txt中适用于某个用户代理的规则。
+type Rules struct {
+	// rules 代表Allow和Disallow规则的列表。
+	rules []rule
+	// crawlDelay 代表Crawl-delay指定的爬取间隔。
+	crawlDelay time.Duration
+	// temporary 代表规则是否只是暂时的，见Temporary。
+	temporary bool
+}
+
+// rule 代表一条Allow或Disallow规则。
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll 代表允许访问全部路径的规则。
+var AllowAll = &Rules{}
+
+// DisallowAll 代表禁止访问全部路径的规则。
+var DisallowAll = &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+
+// Unavailable 代表robots.txt暂时无法获取时的规则，比如服务器返回5xx或网络错误。
+// 它和DisallowAll一样禁止访问全部路径，但在ErrorTTL之后重新获取时可能会改变。
+var Unavailable = &Rules{rules: DisallowAll.rules, temporary: true}
+
+// group 代表robots.txt中以User-agent开头的一组记录。
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+	hasDelay   bool
+}
+
+// Parse 用于解析robots.txt的内容，并返回适用于给定用户代理的规则。
+// 优先选用User-agent与用户代理的产品名相同（不区分大小写）的组，
+// 其次是“*”组，都没有时允许全部访问。
+func Parse(reader io.Reader, userAgent string) (*Rules, error) {
+	groups, err := parseGroups(reader)
+	if err != nil {
+		return nil, err
+	}
+	token := productToken(userAgent)
+	var selected []*group
+	var wildcard []*group
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				wildcard = append(wildcard, g)
+				continue
+			}
+			if token != "" && productToken(agent) == token {
+				selected = append(selected, g)
+				break
+			}
+		}
+	}
+	if selected == nil {
+		selected = wildcard
+	}
+	rules := &Rules{}
+	for _, g := range selected {
+		rules.rules = append(rules.rules, g.rules...)
+		if g.hasDelay && g.crawlDelay > rules.crawlDelay {
+			rules.crawlDelay = g.crawlDelay
+		}
+	}
+	return rules, nil
+}
+
+// parseGroups 用于把robots.txt拆分为若干组记录。
+func parseGroups(reader io.Reader) ([]*group, error) {
+	var groups []*group
+	var current *group
+	// inAgents 代表当前是否处于连续的User-agent行中。
+	inAgents := false
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:index]))
+		value := strings.TrimSpace(line[index+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents || current == nil {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			// 空的Disallow代表允许全部，不需要记录。
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules,
+				rule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			current.hasDelay = true
+		default:
+			inAgents = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// productToken 用于从用户代理字符串中取出产品名，如“mycha/1.0”取“mycha”。
+func productToken(userAgent string) string {
+	token := strings.ToLower(strings.TrimSpace(userAgent))
+	if index := strings.IndexAny(token, "/ "); index >= 0 {
+		token = token[:index]
+	}
+	return token
+}
+
+// Allowed 用于判断给定的路径是否允许访问。
+// 参数path应包含查询部分，如“/search?q=go”。
+// 匹配最长的规则生效，长度相同时Allow优先。
+func (r *Rules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed := true
+	matchedLen := -1
+	for _, rl := range r.rules {
+		if !match(rl.pattern, path) {
+			continue
+		}
+		length := len(rl.pattern)
+		if length > matchedLen || (length == matchedLen && rl.allow) {
+			allowed = rl.allow
+			matchedLen = length
+		}
+	}
+	return allowed
+}
+
+// Temporary 用于判断规则是否只是暂时的，即robots.txt暂时无法获取。
+// 被这样的规则禁止的请求应被推迟，而不是丢弃。
+func (r *Rules) Temporary() bool {
+	return r.temporary
+}
+
+// CrawlDelay 用于获取爬取间隔，未指定时为0。
+func (r *Rules) CrawlDelay() time.Duration {
+	return r.crawlDelay
+}
+
+// match 用于判断路径是否匹配规则，支持“*”通配符和表示结尾的“$”。
+func match(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		index := strings.Index(path[pos:], part)
+		if index < 0 {
+			return false
+		}
+		pos += index + len(part)
+	}
+	if anchored {
+		return pos == len(path)
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var robotsTxt = `
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public.html
+Disallow: /*.pdf$
+Crawl-delay: 1
+
+User-agent: mycha
+User-agent: otherbot
+Disallow: /tmp
+Allow: /tmp/ok
+Crawl-delay: 2.5
+`
+
+func TestParseWildcardGroup(t *testing.T) {
+	rules, err := Parse(strings.NewReader(robotsTxt), "somebot/1.0")
+	if err != nil {
+		t.Fatalf("An error occurs when parsing robots.txt: %s", err)
+	}
+	cases := map[string]bool{
+		"/":                    true,
+		"/private/":            false,
+		"/private/secret.html": false,
+		"/private/public.html": true,
+		"/files/a.pdf":         false,
+		"/files/a.pdf?x=1":     true,
+		"/robots.txt":          true,
+	}
+	for path, expected := range cases {
+		if actual := rules.Allowed(path); actual != expected {
+			t.Fatalf("Inconsistent result for path %q: expected: %v, actual: %v",
+				path, expected, actual)
+		}
+	}
+	if rules.CrawlDelay() != time.Second {
+		t.Fatalf("Inconsistent crawl delay: expected: %s, actual: %s",
+			time.Second, rules.CrawlDelay())
+	}
+}
+
+func TestParseSpecificGroup(t *testing.T) {
+	rules, err := Parse(strings.NewReader(robotsTxt), "Mycha/1.0 (+http://example.com)")
+	if err != nil {
+		t.Fatalf("An error occurs when parsing robots.txt: %s", err)
+	}
+	cases := map[string]bool{
+		"/private/":   true,
+		"/tmp":        false,
+		"/tmp/file":   false,
+		"/tmp/ok":     true,
+		"/tmp/ok/sub": true,
+	}
+	for path, expected := range cases {
+		if actual := rules.Allowed(path); actual != expected {
+			t.Fatalf("Inconsistent result for path %q: expected: %v, actual: %v",
+				path, expected, actual)
+		}
+	}
+	expectedDelay := 2500 * time.Millisecond
+	if rules.CrawlDelay() != expectedDelay {
+		t.Fatalf("Inconsistent crawl delay: expected: %s, actual: %s",
+			expectedDelay, rules.CrawlDelay())
+	}
+}
+
+func TestCache(t *testing.T) {
+	var fetched int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/robots.txt" {
+				http.NotFound(w, r)
+				return
+			}
+			fetched++
+			w.Write([]byte("User-agent: *\nDisallow: /no\n"))
+		}))
+	defer server.Close()
+	cache := NewCache(server.Client(), "mycha", time.Hour)
+	for i := 0; i < 3; i++ {
+		u, _ := url.Parse(server.URL + "/no/page?id=1")
+		if cache.Allowed(u) {
+			t.Fatalf("Disallowed URL %s is allowed!", u)
+		}
+		u, _ = url.Parse(server.URL + "/yes")
+		if !cache.Allowed(u) {
+			t.Fatalf("Allowed URL %s is disallowed!", u)
+		}
+	}
+	if fetched != 1 {
+		t.Fatalf("Inconsistent fetch count: expected: %d, actual: %d", 1, fetched)
+	}
+}
+
+func TestCacheNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	cache := NewCache(server.Client(), "mycha", time.Hour)
+	u, _ := url.Parse(server.URL + "/anything")
+	if !cache.Allowed(u) {
+		t.Fatalf("URL %s should be allowed when robots.txt is missing!", u)
+	}
+}
+
+func TestCacheUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	defer server.Close()
+	cache := NewCache(server.Client(), "mycha", time.Hour)
+	// 5xx和网络错误只是暂时禁止访问。
+	for _, base := range []string{server.URL, closed.URL} {
+		u, _ := url.Parse(base + "/page")
+		rules := cache.Rules(u)
+		if rules.Allowed(RequestPath(u)) || !rules.Temporary() {
+			t.Fatalf("Inconsistent rules of %s: allowed: %v, temporary: %v",
+				u, rules.Allowed(RequestPath(u)), rules.Temporary())
+		}
+	}
+	if DisallowAll.Temporary() || AllowAll.Temporary() {
+		t.Fatalf("Fixed rules are temporary")
+	}
+}
+
+func TestParseAgentMatch(t *testing.T) {
+	txt := `
+User-agent: *
+Disallow: /all
+
+User-agent: MyCha
+Disallow: /mycha
+
+User-agent: mychabot
+Disallow: /mychabot
+`
+	cases := []struct {
+		userAgent string
+		path      string
+	}{
+		{"mycha/1.0", "/mycha"},
+		{"MYCHA", "/mycha"},
+		{"mychabot/2.0 (+http://example.com)", "/mychabot"},
+		// 只包含某个组的名字的用户代理不会匹配该组。
+		{"mych", "/all"},
+		{"supermycha/1.0", "/all"},
+		{"", "/all"},
+	}
+	for _, c := range cases {
+		rules, err := Parse(strings.NewReader(txt), c.userAgent)
+		if err != nil {
+			t.Fatalf("An error occurs when parsing robots.txt: %s", err)
+		}
+		if rules.Allowed(c.path) {
+			t.Fatalf("Inconsistent group for user agent %q: expected path %q to be disallowed",
+				c.userAgent, c.path)
+		}
+	}
+}
+
+func TestCacheSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("User-agent: *\nDisallow: /head\n"))
+			w.Write([]byte(strings.Repeat("# padding\n", maxSize/10)))
+			w.Write([]byte("Disallow: /tail\n"))
+		}))
+	defer server.Close()
+	cache := NewCache(server.Client(), "mycha", time.Hour)
+	cases := map[string]bool{
+		"/head": false,
+		"/tail": true,
+	}
+	for path, expected := range cases {
+		u, _ := url.Parse(server.URL + path)
+		if actual := cache.Allowed(u); actual != expected {
+			t.Fatalf("Inconsistent result for path %q: expected: %v, actual: %v",
+				path, expected, actual)
+		}
+	}
+}
+
+func TestCacheLookup(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+			w.Write([]byte("User-agent: *\nDisallow: /no\n"))
+		}))
+	defer server.Close()
+	cache := NewCache(server.Client(), "mycha", time.Hour)
+	u, _ := url.Parse(server.URL + "/no")
+	rules, ready := cache.Lookup(u)
+	if rules != nil || ready == nil {
+		t.Fatalf("Lookup returns rules before robots.txt is fetched")
+	}
+	if _, again := cache.Lookup(u); again != ready {
+		t.Fatalf("Lookup starts another fetch for the same host")
+	}
+	close(unblock)
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The ready channel is not closed after fetching")
+	}
+	rules, ready = cache.Lookup(u)
+	if rules == nil || ready != nil {
+		t.Fatalf("Lookup does not return the cached rules")
+	}
+	if rules.Allowed(RequestPath(u)) {
+		t.Fatalf("Disallowed URL %s is allowed!", u)
+	}
+}
@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// database 代表打开着的数据库。
+// 路径相同的存储器共用同一个实例，写入时会加锁，以免出现SQLITE_BUSY错误。
+type database struct {
+	path string
+	db   *sql.DB
+	// lock 用于保证同一时刻只有一个写事务，同时保护tables。
+	lock sync.Mutex
+	// tables 代表已知的表及其列，值为列名的集合。
+	tables map[string]map[string]bool
+	// refs 代表共用该实例的存储器的数量。
+	refs int
+}
+
+// databases 代表所有打开着的数据库，键为绝对路径。
+var (
+	databases     = map[string]*database{}
+	databasesLock sync.Mutex
+)
+
+// acquireDatabase 用于打开或共用数据库。
+func acquireDatabase(path string) (*database, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty database path")
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	databasesLock.Lock()
+	defer databasesLock.Unlock()
+	if d, ok := databases[absPath]; ok {
+		d.refs++
+		return d, nil
+	}
+	db, err := sql.Open("sqlite3", "file:"+absPath+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// 所有的写入都已串行化，只用一个连接可以避免事务之间的锁竞争。
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	d := &database{
+		path:   absPath,
+		db:     db,
+		tables: map[string]map[string]bool{},
+		refs:   1,
+	}
+	databases[absPath] = d
+	return d, nil
+}
+
+// release 用于释放对数据库的引用，最后一个引用释放时数据库会被关闭。
+func (d *database) release() error {
+	databasesLock.Lock()
+	defer databasesLock.Unlock()
+	d.refs--
+	if d.refs > 0 {
+		return nil
+	}
+	delete(databases, d.path)
+	return d.db.Close()
+}
+
+// columns 用于获取表中已有的列，表不存在时返回nil。
+// 调用方需持有d.lock。
+func (d *database) columns(tx *sql.Tx, table string) (map[string]bool, error) {
+	if columns, ok := d.tables[table]; ok {
+		return columns, nil
+	}
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns map[string]bool
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if columns == nil {
+			columns = map[string]bool{}
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if columns != nil {
+		d.tables[table] = columns
+	}
+	return columns, nil
+}
+
+// ensureTable 用于确保表存在且包含指定的列，需要时会建表或添加列。
+// 设置了键时，会为键建立唯一索引。调用方需持有d.lock。
+func (d *database) ensureTable(tx *sql.Tx, table string, key string, columns []column) error {
+	existing, err := d.columns(tx, table)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		defs := make([]string, len(columns))
+		for i, c := range columns {
+			defs[i] = strings.TrimSpace(quote(c.name) + " " + c.affinity)
+		}
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+			quote(table), strings.Join(defs, ", "))
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+		existing = map[string]bool{}
+		for _, c := range columns {
+			existing[c.name] = true
+		}
+	} else {
+		for _, c := range columns {
+			if existing[c.name] {
+				continue
+			}
+			stmt := strings.TrimSpace(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+				quote(table), quote(c.name), c.affinity))
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+			existing[c.name] = true
+		}
+	}
+	if key != "" {
+		index := quote("uniq_" + table + "_" + key)
+		stmt := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)",
+			index, quote(table), quote(key))
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	d.tables[table] = existing
+	return nil
+}
+
+// forget 用于在事务回滚后丢弃缓存的表结构。调用方需持有d.lock。
+func (d *database) forget(table string) {
+	delete(d.tables, table)
+}
+
+// quote 用于给表名或列名加上引号。
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
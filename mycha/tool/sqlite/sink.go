@@ -0,0 +1,336 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mycha/module"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的选项。
+const (
+	defaultTable         = "items"
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// Options 代表存储器的选项。
+type Options struct {
+	// Path 代表数据库文件的路径，不存在时会被创建。
+	// 路径相同的存储器会共用同一个数据库，可以在多个条目处理管道中同时使用。
+	Path string
+	// Table 代表存放条目的表名，为空时为items。
+	// 表不存在时会根据条目的键自动创建，条目中出现新的键时会自动添加列。
+	Table string
+	// Key 代表条目的唯一键，不为空时会按它更新已有的记录，
+	// 且条目中必须有该键；为空时总是插入新的记录。
+	Key string
+	// BatchSize 代表每个事务写入的条目数，为0时为100。
+	BatchSize int
+	// FlushInterval 代表把未满一批的条目写入数据库的间隔，为0时为1秒。
+	FlushInterval time.Duration
+}
+
+// Sink 代表把条目存入SQLite数据库的存储器。
+// 列的类型由第一次出现时的值决定：整数和布尔值为INTEGER，浮点数为REAL，
+// 字符串和时间为TEXT，字节切片为BLOB，列表和映射会被转换为JSON并存为TEXT。
+type Sink struct {
+	db        *database
+	table     string
+	key       string
+	batchSize int
+	// lock 用于保护pending和closed。
+	lock sync.Mutex
+	// pending 代表待写入的条目，包括之前写入失败的条目。
+	pending []module.Item
+	closed  bool
+	stop    chan struct{}
+	// flushLock 用于保证同一时刻只有一批条目在写入，以免打乱写入的顺序。
+	flushLock sync.Mutex
+}
+
+// New 用于创建一个存储器。
+func New(options Options) (*Sink, error) {
+	table := options.Table
+	if table == "" {
+		table = defaultTable
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	interval := options.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	db, err := acquireDatabase(options.Path)
+	if err != nil {
+		return nil, err
+	}
+	sink := &Sink{
+		db:        db,
+		table:     table,
+		key:       options.Key,
+		batchSize: batchSize,
+		stop:      make(chan struct{}),
+	}
+	go sink.flushPeriodically(interval)
+	return sink, nil
+}
+
+// Process 用于把条目加入待写入的批次，批次满了时会立即写入。
+// 条目中的值在加入批次前就会被转换，无法转换的条目会被拒绝，不会影响同一批次中的其他条目。
+// 条目会被原样返回。写入失败时会返回错误，但整批条目，包括这个条目，
+// 都会留在待写入的条目中，在下一次写入时重试。
+func (sink *Sink) Process(item module.Item) (module.Item, error) {
+	if len(item) == 0 {
+		return nil, fmt.Errorf("empty item")
+	}
+	if sink.key != "" && item[sink.key] == nil {
+		return nil, fmt.Errorf("missing key %s", sink.key)
+	}
+	// 转换后的是一份副本，以免后续的条目处理器修改条目。
+	converted := make(module.Item, len(item))
+	for name, value := range item {
+		v, err := sqlValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %s", name, err)
+		}
+		converted[name] = v
+	}
+	sink.lock.Lock()
+	if sink.closed {
+		sink.lock.Unlock()
+		return nil, fmt.Errorf("sink is closed")
+	}
+	sink.pending = append(sink.pending, converted)
+	full := len(sink.pending) >= sink.batchSize
+	sink.lock.Unlock()
+	if full {
+		if err := sink.flush(); err != nil {
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+// Flush 用于把待写入的条目写入数据库，写入失败的条目会留到下一次写入时重试。
+func (sink *Sink) Flush() error {
+	return sink.flush()
+}
+
+// Close 用于写入剩余的条目并关闭存储器。
+// 此时仍然写入失败的条目会被丢弃，返回的错误中带有它们的数量。
+// 共用同一个数据库的存储器都关闭后，数据库才会被关闭。
+func (sink *Sink) Close() error {
+	sink.lock.Lock()
+	if sink.closed {
+		sink.lock.Unlock()
+		return nil
+	}
+	sink.closed = true
+	close(sink.stop)
+	sink.lock.Unlock()
+	err := sink.Flush()
+	if releaseErr := sink.db.release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// flushPeriodically 用于定时写入未满一批的条目，直到存储器被关闭。
+// 写入失败的条目会留到下一次写入时重试，错误由之后的Process、Flush或Close返回。
+func (sink *Sink) flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sink.stop:
+			return
+		case <-ticker.C:
+			sink.flush()
+		}
+	}
+}
+
+// flush 用于在一个事务中写入待写入的条目。
+// 写入失败时整批条目都不会被写入，它们会被放回待写入的条目的最前面，以保持写入的顺序。
+func (sink *Sink) flush() error {
+	sink.flushLock.Lock()
+	defer sink.flushLock.Unlock()
+	sink.lock.Lock()
+	items := sink.pending
+	sink.pending = nil
+	sink.lock.Unlock()
+	if len(items) == 0 {
+		return nil
+	}
+	if err := sink.write(items); err != nil {
+		sink.lock.Lock()
+		sink.pending = append(items, sink.pending...)
+		sink.lock.Unlock()
+		return fmt.Errorf("failed to write %d items into %s: %s", len(items), sink.table, err)
+	}
+	return nil
+}
+
+// write 用于在一个事务中建表并写入条目。
+func (sink *Sink) write(items []module.Item) (err error) {
+	d := sink.db
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			d.forget(sink.table)
+		}
+	}()
+	if err = d.ensureTable(tx, sink.table, sink.key, sink.columnsOf(items)); err != nil {
+		return err
+	}
+	stmts := map[string]*sql.Stmt{}
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	for _, item := range items {
+		names := make([]string, 0, len(item))
+		for name := range item {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		signature := strings.Join(names, "\x00")
+		stmt, ok := stmts[signature]
+		if !ok {
+			if stmt, err = tx.Prepare(sink.insertSQL(names)); err != nil {
+				return err
+			}
+			stmts[signature] = stmt
+		}
+		// 条目中的值已经在Process中转换过了。
+		args := make([]interface{}, len(names))
+		for i, name := range names {
+			args[i] = item[name]
+		}
+		if _, err = stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// column 代表表中的一列。
+type column struct {
+	name     string
+	affinity string
+}
+
+// columnsOf 用于获取条目中出现的所有列，唯一键排在最前面，其余按名称排序。
+// 列的类型由第一个不为nil的值决定。
+func (sink *Sink) columnsOf(items []module.Item) []column {
+	affinities := map[string]string{}
+	for _, item := range items {
+		for name, value := range item {
+			if affinities[name] == "" {
+				affinities[name] = affinityOf(value)
+			}
+		}
+	}
+	names := make([]string, 0, len(affinities))
+	for name := range affinities {
+		if name != sink.key {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if sink.key != "" {
+		names = append([]string{sink.key}, names...)
+	}
+	columns := make([]column, len(names))
+	for i, name := range names {
+		columns[i] = column{name: name, affinity: affinities[name]}
+	}
+	return columns
+}
+
+// insertSQL 用于生成插入记录的语句。
+// 设置了唯一键时，键已存在的记录中条目带有的列会被更新，其余的列保持不变。
+func (sink *Sink) insertSQL(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quote(name)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quote(sink.table), strings.Join(quoted, ", "), placeholders)
+	if sink.key == "" {
+		return stmt
+	}
+	var updates []string
+	for i, name := range names {
+		if name != sink.key {
+			updates = append(updates, quoted[i]+" = excluded."+quoted[i])
+		}
+	}
+	if len(updates) == 0 {
+		return stmt + fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", quote(sink.key))
+	}
+	return stmt + fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s",
+		quote(sink.key), strings.Join(updates, ", "))
+}
+
+// affinityOf 用于根据值确定列的类型，值为nil时返回空字符串。
+func affinityOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "INTEGER"
+	case float32, float64:
+		return "REAL"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "INTEGER"
+		}
+		return "REAL"
+	case []byte:
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// sqlValue 用于把条目中的值转换为可以存入数据库的值。
+// 时间会被转换为RFC3339格式，列表和映射等其他类型会被转换为JSON。
+func sqlValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32,
+		float32, float64, string, []byte:
+		return v, nil
+	case uint64:
+		if v > 1<<63-1 {
+			return fmt.Sprint(v), nil
+		}
+		return int64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,252 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"mycha/module"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tempDB 用于生成一个临时的数据库路径，返回的函数用于清理。
+func tempDB(t *testing.T) (string, func()) {
+	dir, err := os.MkdirTemp("", "sqlite")
+	if err != nil {
+		t.Fatalf("An error occurs when creating a temporary directory: %s", err)
+	}
+	return filepath.Join(dir, "items.db"), func() { os.RemoveAll(dir) }
+}
+
+// openDB 用于另外打开数据库以检查写入的结果。
+func openDB(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("An error occurs when opening the database: %s", err)
+	}
+	return db
+}
+
+func TestUpsert(t *testing.T) {
+	path, cleanup := tempDB(t)
+	defer cleanup()
+	sink, err := New(Options{Path: path, Table: "books", Key: "url", BatchSize: 2})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the sink: %s", err)
+	}
+	published := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := []module.Item{
+		{"url": "http://a.com/1", "title": "First", "price": 10},
+		{"url": "http://a.com/2", "title": "Second", "price": 12.5},
+		// 更新第一条记录，并带有新的列。
+		{"url": "http://a.com/1", "title": "First, 2nd edition",
+			"tags": []string{"go"}, "published": published},
+	}
+	for _, item := range items {
+		if _, err := sink.Process(item); err != nil {
+			t.Fatalf("An error occurs when processing the item: %s", err)
+		}
+	}
+	if _, err := sink.Process(module.Item{"title": "No URL"}); err == nil {
+		t.Fatalf("No error when processing an item without key")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the sink: %s", err)
+	}
+	if _, err := sink.Process(items[0]); err == nil {
+		t.Fatalf("No error when processing with a closed sink")
+	}
+
+	db := openDB(t, path)
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM books`).Scan(&count); err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("Inconsistent number of rows: expected: %d, actual: %d", 2, count)
+	}
+	var title, tags, publishedText string
+	var price int
+	err = db.QueryRow(`SELECT title, price, tags, published FROM books WHERE url = ?`,
+		"http://a.com/1").Scan(&title, &price, &tags, &publishedText)
+	if err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	// 没有出现在新条目中的列保持不变。
+	if title != "First, 2nd edition" || price != 10 || tags != `["go"]` ||
+		publishedText != "2016-01-02T03:04:05Z" {
+		t.Fatalf("Inconsistent row: %q, %d, %q, %q", title, price, tags, publishedText)
+	}
+	var realPrice float64
+	err = db.QueryRow(`SELECT price FROM books WHERE url = ?`, "http://a.com/2").Scan(&realPrice)
+	if err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	if realPrice != 12.5 {
+		t.Fatalf("Inconsistent price: expected: %v, actual: %v", 12.5, realPrice)
+	}
+}
+
+func TestConcurrentSinks(t *testing.T) {
+	path, cleanup := tempDB(t)
+	defer cleanup()
+	// 模拟多个条目处理管道各自创建存储器的情况。
+	sinks := make([]*Sink, 4)
+	for i := range sinks {
+		var err error
+		sinks[i], err = New(Options{
+			Path:          path,
+			Key:           "id",
+			BatchSize:     7,
+			FlushInterval: 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("An error occurs when creating the sink: %s", err)
+		}
+	}
+	number := 50
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		wg.Add(1)
+		go func(i int, sink *Sink) {
+			defer wg.Done()
+			for j := 0; j < number; j++ {
+				item := module.Item{"id": fmt.Sprintf("%d-%d", i, j), "seq": j}
+				if _, err := sink.Process(item); err != nil {
+					t.Errorf("An error occurs when processing the item: %s", err)
+				}
+			}
+		}(i, sink)
+	}
+	wg.Wait()
+	// 等待定时写入，之后的数据应该已经可以查询到。
+	time.Sleep(50 * time.Millisecond)
+	db := openDB(t, path)
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	if count != number*len(sinks) {
+		t.Fatalf("Inconsistent number of rows: expected: %d, actual: %d",
+			number*len(sinks), count)
+	}
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			t.Fatalf("An error occurs when closing the sink: %s", err)
+		}
+	}
+}
+
+func TestInsertWithoutKey(t *testing.T) {
+	path, cleanup := tempDB(t)
+	defer cleanup()
+	sink, err := New(Options{Path: path})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the sink: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := sink.Process(module.Item{"name": "same", "empty": nil}); err != nil {
+			t.Fatalf("An error occurs when processing the item: %s", err)
+		}
+	}
+	if _, err := sink.Process(module.Item{}); err == nil {
+		t.Fatalf("No error when processing an empty item")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the sink: %s", err)
+	}
+	db := openDB(t, path)
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items WHERE name = 'same'`).Scan(&count); err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	if count != 3 {
+		t.Fatalf("Inconsistent number of rows: expected: %d, actual: %d", 3, count)
+	}
+	if _, err := New(Options{}); err == nil {
+		t.Fatalf("No error when creating a sink without path")
+	}
+}
+
+func TestUnconvertibleItem(t *testing.T) {
+	path, cleanup := tempDB(t)
+	defer cleanup()
+	sink, err := New(Options{Path: path, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the sink: %s", err)
+	}
+	if _, err := sink.Process(module.Item{"name": "first"}); err != nil {
+		t.Fatalf("An error occurs when processing the item: %s", err)
+	}
+	// 无法转换的条目在加入批次之前就被拒绝，不会导致整批条目写入失败。
+	if _, err := sink.Process(module.Item{"name": "bad", "ch": make(chan int)}); err == nil {
+		t.Fatalf("No error when processing an item with an unconvertible value")
+	}
+	if _, err := sink.Process(module.Item{"name": "second"}); err != nil {
+		t.Fatalf("An error occurs when processing the item: %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the sink: %s", err)
+	}
+	db := openDB(t, path)
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("Inconsistent number of rows: expected: %d, actual: %d", 2, count)
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	path, cleanup := tempDB(t)
+	defer cleanup()
+	db := openDB(t, path)
+	defer db.Close()
+	// 用触发器让写入失败，删除触发器之后写入恢复正常。
+	if _, err := db.Exec(`CREATE TABLE items (name TEXT);
+		CREATE TRIGGER fail BEFORE INSERT ON items BEGIN SELECT RAISE(ABORT, 'forced'); END;`); err != nil {
+		t.Fatalf("An error occurs when preparing the database: %s", err)
+	}
+	sink, err := New(Options{Path: path, BatchSize: 2, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("An error occurs when creating the sink: %s", err)
+	}
+	names := []string{"a", "b", "c"}
+	for i, name := range names {
+		_, err := sink.Process(module.Item{"name": name})
+		if (i > 0) != (err != nil) {
+			t.Fatalf("Inconsistent result of processing item %s: %v", name, err)
+		}
+	}
+	if err := sink.Flush(); err == nil {
+		t.Fatalf("No error when the write fails")
+	}
+	if _, err := db.Exec(`DROP TRIGGER fail`); err != nil {
+		t.Fatalf("An error occurs when dropping the trigger: %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the sink: %s", err)
+	}
+	// 写入失败的条目都没有丢失，并且保持原来的顺序。
+	rows, err := db.Query(`SELECT name FROM items ORDER BY rowid`)
+	if err != nil {
+		t.Fatalf("An error occurs when querying: %s", err)
+	}
+	defer rows.Close()
+	var written []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		written = append(written, name)
+	}
+	if fmt.Sprint(written) != fmt.Sprint(names) {
+		t.Fatalf("Inconsistent written items: expected: %v, actual: %v", names, written)
+	}
+}